@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// ============================================================================
+// ADMIN ENDPOINTS
+// ============================================================================
+
+var adminToken string
+
+// requireAdmin guards a handler with the ADMIN_TOKEN bearer token. Admin
+// endpoints are disabled entirely when no token is configured.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authorizeAdmin(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// authorizeAdmin reports whether the request carries the admin token, and
+// responds with an error if it doesn't.
+func authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if adminToken == "" {
+		http.Error(w, "ADMIN_TOKEN not configured", http.StatusServiceUnavailable)
+		return false
+	}
+	if !isAdmin(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// isAdmin reports whether the request carries the admin token.
+func isAdmin(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+func handleSchedulerStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeSchedulerStatus(w)
+}
+
+// handleSchedulerPause suspends scheduled runs until a date:
+// POST /admin/scheduler/pause?until=2024-12-27[&job=name]. Without a job name
+// every job is paused.
+func handleSchedulerPause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	until := r.URL.Query().Get("until")
+	if _, err := time.Parse(dateLayout, until); err != nil {
+		http.Error(w, "until must be a date in YYYY-MM-DD format", http.StatusBadRequest)
+		return
+	}
+
+	job, ok := schedulerJobParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := scheduler.pause(job, until); err != nil {
+		slog.ErrorContext(r.Context(), "Error pausing scheduler", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeSchedulerStatus(w)
+}
+
+// handleSchedulerResume lifts a pause: POST /admin/scheduler/resume[?job=name].
+func handleSchedulerResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job, ok := schedulerJobParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := scheduler.resume(job); err != nil {
+		slog.ErrorContext(r.Context(), "Error resuming scheduler", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeSchedulerStatus(w)
+}
+
+func schedulerJobParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	job := r.URL.Query().Get("job")
+	if job == "" {
+		return allJobs, true
+	}
+	if !scheduler.hasJob(job) {
+		http.Error(w, fmt.Sprintf("unknown job %q", job), http.StatusNotFound)
+		return "", false
+	}
+	return job, true
+}
+
+func writeSchedulerStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"paused_until": scheduler.pausedUntil(allJobs),
+		"holidays":     scheduler.holidays.size(),
+		"jobs":         scheduler.status(),
+	})
+}
+
+// handleLinearStatus reports the Linear rate limit budget and metadata cache
+// of LINEAR_API_KEY and of every installed workspace.
+func handleLinearStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type linearStatus struct {
+		RateLimit linear.RateLimit `json:"rate_limit"`
+		Metadata  MetadataStats    `json:"metadata"`
+	}
+	installed := map[string]linearStatus{}
+	for _, ws := range workspaces.list() {
+		installed[ws.Key] = linearStatus{RateLimit: ws.client.RateLimit(), Metadata: ws.metadata.stats()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rate_limit": linearClient.RateLimit(),
+		"metadata":   metadata.stats(),
+		"workspaces": installed,
+	})
+}
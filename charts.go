@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// ============================================================================
+// CHARTS
+// ============================================================================
+
+// Chart is the data of one PNG chart. Labels name the x-axis points (the rows
+// of a bar chart); every series has at most one value per label.
+type Chart struct {
+	Title  string
+	Labels []string
+	Series []ChartSeries
+}
+
+type ChartSeries struct {
+	Name   string
+	Color  color.RGBA
+	Values []float64
+	// Dashed draws the series as a dashed line (line charts only).
+	Dashed bool
+}
+
+const (
+	chartWidth  = 800
+	chartHeight = 400
+	chartMargin = 20
+	chartScale  = 2 // font pixel size
+	chartRowGap = 28
+)
+
+var (
+	chartBackground = rgb(0xFFFFFF)
+	chartInk        = rgb(0x374151)
+	chartGrid       = rgb(0xE5E7EB)
+	chartMuted      = rgb(0x9CA3AF)
+)
+
+func rgb(hex int) color.RGBA {
+	return color.RGBA{R: uint8(hex >> 16), G: uint8(hex >> 8), B: uint8(hex), A: 0xFF}
+}
+
+// renderLines draws the series as lines across the labels. Series shorter
+// than the labels stop early, e.g. a burndown in the middle of a cycle.
+func (ch *Chart) renderLines() ([]byte, error) {
+	if len(ch.Labels) < 2 {
+		return nil, fmt.Errorf("line chart needs at least two points")
+	}
+
+	max := 0.0
+	for _, s := range ch.Series {
+		for _, v := range s.Values {
+			max = math.Max(max, v)
+		}
+	}
+
+	c := newCanvas(chartWidth, chartHeight)
+	plot, y := c.axes(ch, niceMax(max))
+	x := func(i int) int { return plot.Min.X + i*plot.Dx()/(len(ch.Labels)-1) }
+	c.xLabels(plot, ch.Labels, x)
+
+	for _, s := range ch.Series {
+		width, dash := 3, 0
+		if s.Dashed {
+			width, dash = 2, 6
+		}
+		for i := 1; i < len(s.Values) && i < len(ch.Labels); i++ {
+			c.line(x(i-1), y(s.Values[i-1]), x(i), y(s.Values[i]), width, s.Color, dash)
+		}
+	}
+
+	return c.png()
+}
+
+// renderColumns draws one column per label, stacking the series bottom-up.
+func (ch *Chart) renderColumns() ([]byte, error) {
+	if len(ch.Labels) == 0 {
+		return nil, fmt.Errorf("column chart has no data")
+	}
+
+	totals := ch.totals()
+	max := 0.0
+	for _, t := range totals {
+		max = math.Max(max, t)
+	}
+
+	c := newCanvas(chartWidth, chartHeight)
+	plot, y := c.axes(ch, niceMax(max))
+	slot := plot.Dx() / len(ch.Labels)
+	x := func(i int) int { return plot.Min.X + slot*i + slot/2 }
+	c.xLabels(plot, ch.Labels, x)
+
+	barWidth := slot * 2 / 3
+	for i := range ch.Labels {
+		base := 0.0
+		for _, s := range ch.Series {
+			if i >= len(s.Values) || s.Values[i] <= 0 {
+				continue
+			}
+			top, bottom := y(base+s.Values[i]), y(base)
+			c.rect(x(i)-barWidth/2, top, barWidth, bottom-top, s.Color)
+			base += s.Values[i]
+		}
+	}
+
+	return c.png()
+}
+
+// renderBars draws one horizontal bar per label, stacking the series left to
+// right, with the total at the end of each bar. The image grows with the
+// number of rows.
+func (ch *Chart) renderBars() ([]byte, error) {
+	if len(ch.Labels) == 0 {
+		return nil, fmt.Errorf("bar chart has no data")
+	}
+
+	totals := ch.totals()
+	max := 0.0
+	labelWidth := 0
+	for i, label := range ch.Labels {
+		max = math.Max(max, totals[i])
+		labelWidth = maxInt(labelWidth, textWidth(truncate(label, 16), chartScale))
+	}
+	max = niceMax(max)
+
+	textHeight := glyphHeight * chartScale
+	height := 56 + len(ch.Labels)*chartRowGap + 16 + textHeight + chartMargin
+	c := newCanvas(chartWidth, height)
+	c.text(chartMargin, chartMargin, ch.Title, chartScale, chartInk)
+
+	left := chartMargin + labelWidth + 12
+	right := chartWidth - chartMargin - textWidth("9999", chartScale) - 8
+	for i, label := range ch.Labels {
+		top := 56 + i*chartRowGap
+		c.text(chartMargin, top+2, truncate(label, 16), chartScale, chartInk)
+
+		base := 0.0
+		for _, s := range ch.Series {
+			if i >= len(s.Values) || s.Values[i] <= 0 {
+				continue
+			}
+			x0 := left + int(base/max*float64(right-left))
+			x1 := left + int((base+s.Values[i])/max*float64(right-left))
+			c.rect(x0, top, maxInt(x1-x0, 1), chartRowGap-10, s.Color)
+			base += s.Values[i]
+		}
+		end := left + int(base/max*float64(right-left))
+		c.text(end+8, top+2, formatPoints(totals[i]), chartScale, chartInk)
+	}
+
+	c.legend(left, height-chartMargin-textHeight, ch.Series)
+	return c.png()
+}
+
+func (ch *Chart) totals() []float64 {
+	totals := make([]float64, len(ch.Labels))
+	for _, s := range ch.Series {
+		for i, v := range s.Values {
+			if i < len(totals) {
+				totals[i] += v
+			}
+		}
+	}
+	return totals
+}
+
+// niceMax rounds v up to 1, 2 or 5 times a power of ten, and to at least 5 so
+// small counts still get whole-number ticks.
+func niceMax(v float64) float64 {
+	if v < 5 {
+		return 5
+	}
+	p := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5} {
+		if v <= m*p {
+			return m * p
+		}
+	}
+	return 10 * p
+}
+
+type canvas struct {
+	img *image.RGBA
+}
+
+func newCanvas(w, h int) *canvas {
+	c := &canvas{img: image.NewRGBA(image.Rect(0, 0, w, h))}
+	c.rect(0, 0, w, h, chartBackground)
+	return c
+}
+
+func (c *canvas) rect(x, y, w, h int, col color.RGBA) {
+	draw.Draw(c.img, image.Rect(x, y, x+w, y+h), &image.Uniform{C: col}, image.Point{}, draw.Src)
+}
+
+// line draws a line of the given width. A non-zero dash alternates drawn and
+// skipped runs of that many pixels.
+func (c *canvas) line(x0, y0, x1, y1, width int, col color.RGBA, dash int) {
+	dx, dy := absInt(x1-x0), -absInt(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for step := 0; ; step++ {
+		if dash == 0 || (step/dash)%2 == 0 {
+			c.rect(x0-width/2, y0-width/2, width, width, col)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// axes draws the title, legend and y-axis of a vertical chart. It returns the
+// plot area and the function mapping a value to its y coordinate.
+func (c *canvas) axes(ch *Chart, max float64) (image.Rectangle, func(float64) int) {
+	textHeight := glyphHeight * chartScale
+	c.text(chartMargin, chartMargin, ch.Title, chartScale, chartInk)
+
+	left := chartMargin + textWidth(formatPoints(max), chartScale) + 12
+	plot := image.Rect(left, 56, chartWidth-chartMargin, chartHeight-chartMargin-textHeight-36)
+	y := func(v float64) int { return plot.Max.Y - int(v/max*float64(plot.Dy())) }
+
+	const ticks = 5
+	for i := 0; i <= ticks; i++ {
+		v := max * float64(i) / ticks
+		label := formatPoints(v)
+		c.rect(plot.Min.X, y(v), plot.Dx(), 1, chartGrid)
+		c.text(left-8-textWidth(label, chartScale), y(v)-textHeight/2, label, chartScale, chartMuted)
+	}
+	c.rect(plot.Min.X, plot.Min.Y, 1, plot.Dy(), chartMuted)
+	c.rect(plot.Min.X, plot.Max.Y, plot.Dx(), 1, chartMuted)
+
+	c.legend(left, chartHeight-chartMargin-textHeight, ch.Series)
+	return plot, y
+}
+
+// xLabels writes the labels under the plot, centred on x(i), skipping labels
+// as needed so that they don't overlap.
+func (c *canvas) xLabels(plot image.Rectangle, labels []string, x func(int) int) {
+	widest := 0
+	for _, label := range labels {
+		widest = maxInt(widest, textWidth(label, chartScale))
+	}
+
+	step := 1
+	for len(labels) > 1 && (x(step)-x(0)) < widest+12 {
+		step++
+		if step >= len(labels) {
+			break
+		}
+	}
+
+	end := -chartWidth
+	for i := 0; i < len(labels); i += step {
+		w := textWidth(labels[i], chartScale)
+		left := x(i) - w/2
+		if edge := c.img.Bounds().Dx() - chartMargin/2; left+w > edge {
+			left = edge - w
+		}
+		if left < end+12 {
+			continue
+		}
+		c.text(left, plot.Max.Y+10, labels[i], chartScale, chartMuted)
+		end = left + w
+	}
+}
+
+func (c *canvas) legend(x, y int, series []ChartSeries) {
+	size := glyphHeight * chartScale
+	for _, s := range series {
+		if s.Name == "" {
+			continue
+		}
+		c.rect(x, y, size, size, s.Color)
+		c.text(x+size+6, y, s.Name, chartScale, chartInk)
+		x += size + 6 + textWidth(s.Name, chartScale) + 20
+	}
+}
+
+func (c *canvas) png() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, fmt.Errorf("failed to encode chart: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ============================================================================
+// REPORT CHARTS
+// ============================================================================
+
+// chartStateTypes are the open workflow state types, bottom of the stack
+// first, with their chart colours.
+var chartStateTypes = []struct {
+	Type  string
+	Name  string
+	Color color.RGBA
+}{
+	{"started", "In Progress", rgb(ColorYellow)},
+	{"unstarted", "Todo", rgb(ColorBlue)},
+	{"backlog", "Backlog", rgb(ColorGray)},
+	{"triage", "Triage", rgb(ColorPurple)},
+}
+
+// stateTypeSeries turns per-label counts by state type into chart series,
+// leaving out state types that never occur.
+func stateTypeSeries(counts []map[string]int) []ChartSeries {
+	var series []ChartSeries
+	for _, st := range chartStateTypes {
+		s := ChartSeries{Name: st.Name, Color: st.Color, Values: make([]float64, len(counts))}
+		found := false
+		for i, byType := range counts {
+			s.Values[i] = float64(byType[st.Type])
+			found = found || byType[st.Type] > 0
+		}
+		if found {
+			series = append(series, s)
+		}
+	}
+	return series
+}
+
+// statusHistoryChart shows the open issues per state type, one column per
+// daily snapshot.
+func statusHistoryChart(snaps []*Snapshot) *Chart {
+	chart := &Chart{Title: "Open issues by status"}
+	counts := make([]map[string]int, len(snaps))
+	for i, snap := range snaps {
+		chart.Labels = append(chart.Labels, snap.TakenAt.Format("Jan 2"))
+		counts[i] = map[string]int{}
+		for _, issue := range snap.Issues {
+			counts[i][issue.StateType]++
+		}
+	}
+	chart.Series = stateTypeSeries(counts)
+	return chart
+}
+
+// assigneeChart shows the open issues per assignee, split by state type.
+func assigneeChart(issues []Issue) *Chart {
+	const maxRows = 12
+	chart := &Chart{Title: "Open issues by assignee"}
+	var counts []map[string]int
+	for i, group := range groupByAssignee(issues) {
+		if i == maxRows {
+			break
+		}
+		chart.Labels = append(chart.Labels, group.Name)
+		byType := map[string]int{}
+		for _, issue := range group.Issues {
+			byType[issue.State.Type]++
+		}
+		counts = append(counts, byType)
+	}
+	chart.Series = stateTypeSeries(counts)
+	return chart
+}
+
+// burndownChart plots what is left of the cycle's scope each day against an
+// ideal straight line. It uses estimate points when the cycle has any and
+// issue counts otherwise. It returns nil if Linear has no history yet.
+func burndownChart(c Cycle) *Chart {
+	scope, completed, unit := c.ScopeHistory, c.CompletedScopeHistory, "points"
+	if len(scope) == 0 || scope[len(scope)-1] == 0 {
+		scope, completed, unit = c.IssueCountHistory, c.CompletedIssueCountHistory, "issues"
+	}
+	if len(scope) == 0 {
+		return nil
+	}
+
+	days := int(math.Ceil(c.EndsAt.Sub(c.StartsAt).Hours() / 24))
+	if days < 1 {
+		return nil
+	}
+
+	chart := &Chart{Title: fmt.Sprintf("Cycle %d burndown (%s)", c.Number, unit)}
+	ideal := make([]float64, days+1)
+	for i := 0; i <= days; i++ {
+		chart.Labels = append(chart.Labels, c.StartsAt.AddDate(0, 0, i).Format("Jan 2"))
+		ideal[i] = scope[0] * float64(days-i) / float64(days)
+	}
+
+	remaining := make([]float64, len(scope))
+	for i := range scope {
+		remaining[i] = scope[i]
+		if i < len(completed) {
+			remaining[i] -= completed[i]
+		}
+	}
+
+	chart.Series = []ChartSeries{
+		{Name: "Ideal", Color: chartMuted, Values: ideal, Dashed: true},
+		{Name: "Scope", Color: rgb(ColorGray), Values: scope},
+		{Name: "Remaining", Color: rgb(ColorBlue), Values: remaining},
+	}
+	return chart
+}
+
+// attachChart renders a chart and attaches it to the payload, returning the
+// embed image that shows it. Failures are logged and return nil so the
+// message still goes out without the chart.
+func attachChart(payload *DiscordWebhook, name string, chart *Chart, render func(*Chart) ([]byte, error)) *DiscordImage {
+	if chart == nil || len(chart.Series) == 0 {
+		return nil
+	}
+	data, err := render(chart)
+	if err != nil {
+		slog.Warn("Charts: Could not render chart", "chart", name, "error", err)
+		return nil
+	}
+	return &DiscordImage{URL: payload.attach(name, data)}
+}
+
+// attachDigestCharts adds the status history and assignee charts to the
+// digest as image embeds.
+func attachDigestCharts(payload *DiscordWebhook, opts ReportOptions, issues []Issue, now time.Time) {
+	if store != nil {
+		snaps, err := dailySnapshots(opts.snapshotScope(), now.AddDate(0, 0, -14))
+		if err != nil {
+			slog.WarnContext(opts.context(), "Charts: Could not load snapshots", "error", err)
+		} else if len(snaps) >= 2 {
+			if img := attachChart(payload, "status-history.png", statusHistoryChart(snaps), (*Chart).renderColumns); img != nil {
+				payload.Embeds = append(payload.Embeds, DiscordEmbed{Title: "📈 Open Issues by Status", Color: ColorBlue, Image: img})
+			}
+		}
+	}
+
+	if img := attachChart(payload, "assignees.png", assigneeChart(issues), (*Chart).renderBars); img != nil {
+		payload.Embeds = append(payload.Embeds, DiscordEmbed{Title: "👥 Open Issues by Assignee", Color: ColorBlue, Image: img})
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+// Config is the optional JSON file pointed to by CONFIG_FILE. Every section has
+// a default, so the service still runs from environment variables alone.
+type Config struct {
+	// Destinations maps a name to a Discord webhook. "default" always exists
+	// and points at DISCORD_WEBHOOK_URL unless it is overridden here.
+	Destinations map[string]*Destination `json:"destinations,omitempty"`
+
+	// RelayTo lists the destinations that receive Linear webhook events.
+	RelayTo []string `json:"relay_to,omitempty"`
+
+	// Templates overrides the built-in message layout for every destination,
+	// keyed by "<Type>.<action>" (e.g. "Issue.update") or "<Type>.*".
+	Templates map[string]*EventTemplate `json:"templates,omitempty"`
+
+	// Workspaces routes Linear workspaces installed through OAuth, keyed by
+	// URL key.
+	Workspaces map[string]*WorkspaceConfig `json:"workspaces,omitempty"`
+
+	// Jobs are the scheduled reports. Defaults to the per-user report at
+	// 9 AM UTC on weekdays.
+	Jobs []*JobConfig `json:"jobs,omitempty"`
+
+	// CatchUpGrace is how late a run missed during downtime may still be
+	// sent after a restart, e.g. "2h". Defaults to 1h; "0s" disables it.
+	CatchUpGrace string `json:"catch_up_grace,omitempty"`
+
+	// MetadataTTL is how long cached Linear teams, users, workflow states
+	// and labels are used, e.g. "15m". Defaults to 1h.
+	MetadataTTL string `json:"metadata_ttl,omitempty"`
+
+	// Stale sets the thresholds of the stale issue report.
+	Stale *StaleConfig `json:"stale,omitempty"`
+
+	// DM maps Linear users to Discord users for personal DM digests.
+	DM *DMConfig `json:"dm,omitempty"`
+
+	// Projects sets when the project health report flags a project.
+	Projects *ProjectHealthConfig `json:"projects,omitempty"`
+
+	// SLA sets how early due date and SLA breach alerts are sent.
+	SLA *SLAConfig `json:"sla,omitempty"`
+
+	// Holidays are days on which no scheduled job runs.
+	Holidays *HolidayConfig `json:"holidays,omitempty"`
+
+	// Charts attaches PNG charts to digests and cycle reports. Defaults to
+	// true.
+	Charts *bool `json:"charts,omitempty"`
+
+	holidays    *Calendar
+	metadataTTL time.Duration
+	// version identifies the loaded config file: the start of its SHA-256,
+	// or "default" without one.
+	version string
+}
+
+type Destination struct {
+	Name       string                    `json:"-"`
+	WebhookURL string                    `json:"webhook_url"`
+	Templates  map[string]*EventTemplate `json:"templates,omitempty"`
+
+	templates *TemplateSet
+}
+
+const defaultDestination = "default"
+
+var appConfig *Config
+
+// loadConfig reads the config file at path (if any), fills in defaults and
+// compiles every template so mistakes surface at startup rather than on the
+// first webhook.
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{version: "default"}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		expanded := os.ExpandEnv(string(data))
+		sum := sha256.Sum256([]byte(expanded))
+		cfg.version = hex.EncodeToString(sum[:6])
+		dec := json.NewDecoder(strings.NewReader(expanded))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+
+	if cfg.Destinations == nil {
+		cfg.Destinations = map[string]*Destination{}
+	}
+	if cfg.Destinations[defaultDestination] == nil {
+		cfg.Destinations[defaultDestination] = &Destination{}
+	}
+	if cfg.Destinations[defaultDestination].WebhookURL == "" {
+		cfg.Destinations[defaultDestination].WebhookURL = discordWebhookURL
+	}
+	if len(cfg.RelayTo) == 0 {
+		cfg.RelayTo = []string{defaultDestination}
+	}
+
+	if err := cfg.loadWorkspaceDestinations(); err != nil {
+		return nil, err
+	}
+
+	defaults, err := compileTemplates(defaultTemplates(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("built-in templates: %w", err)
+	}
+	shared, err := compileTemplates(cfg.Templates, defaults)
+	if err != nil {
+		return nil, fmt.Errorf("templates: %w", err)
+	}
+
+	for name, dest := range cfg.Destinations {
+		dest.Name = name
+		if dest.WebhookURL == "" {
+			return nil, fmt.Errorf("destination %q has no webhook_url", name)
+		}
+		if len(dest.Templates) == 0 {
+			dest.templates = shared
+			continue
+		}
+		set, err := compileTemplates(dest.Templates, shared)
+		if err != nil {
+			return nil, fmt.Errorf("destination %q templates: %w", name, err)
+		}
+		dest.templates = set
+	}
+
+	for _, name := range cfg.RelayTo {
+		if cfg.destination(name) == nil {
+			return nil, fmt.Errorf("relay_to references unknown destination %q", name)
+		}
+	}
+	if err := cfg.validateWorkspaceRoutes(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.validateJobs(); err != nil {
+		return nil, err
+	}
+
+	cfg.metadataTTL = defaultMetadataTTL
+	if cfg.MetadataTTL != "" {
+		if cfg.metadataTTL, err = time.ParseDuration(cfg.MetadataTTL); err != nil || cfg.metadataTTL <= 0 {
+			return nil, fmt.Errorf("invalid metadata_ttl %q: must be a positive duration", cfg.MetadataTTL)
+		}
+	}
+
+	if cfg.holidays, err = loadCalendar(cfg.Holidays); err != nil {
+		return nil, fmt.Errorf("holidays: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// destination returns the named global destination, or nil if it isn't
+// configured. Workspace destinations ("<workspace>/<name>") are only reached
+// through workspaceDestination, so that global data never goes to them.
+func (c *Config) destination(name string) *Destination {
+	if name == "" {
+		name = defaultDestination
+	}
+	if strings.Contains(name, "/") {
+		return nil
+	}
+	return c.Destinations[name]
+}
+
+func (c *Config) chartsEnabled() bool {
+	return c == nil || c.Charts == nil || *c.Charts
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// CRON EXPRESSIONS
+// ============================================================================
+
+// CronSchedule is a standard five-field cron expression (minute hour
+// day-of-month month day-of-week) evaluated in a fixed time zone.
+//
+// Times are matched against the local wall clock, so "0 9 * * *" fires at 9 AM
+// local time on both sides of a DST change. A time skipped by spring-forward
+// is shifted by the length of the gap (02:30 becomes 03:30); a time repeated by
+// fall-back fires only once, on its first occurrence.
+type CronSchedule struct {
+	expr    string
+	loc     *time.Location
+	minute  [60]bool
+	hour    [24]bool
+	dom     [32]bool
+	month   [13]bool
+	dow     [7]bool
+	domStar bool
+	dowStar bool
+}
+
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// parseCron parses expr for the given IANA time zone ("" means UTC).
+func parseCron(expr, timezone string) (*CronSchedule, error) {
+	loc := time.UTC
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+	}
+
+	spec := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: want 5 fields, got %d", expr, len(parts))
+	}
+
+	s := &CronSchedule{expr: expr, loc: loc}
+	fields := []struct {
+		name     string
+		set      []bool
+		min, max int
+		names    map[string]int
+	}{
+		{"minute", s.minute[:], 0, 59, nil},
+		{"hour", s.hour[:], 0, 23, nil},
+		{"day-of-month", s.dom[:], 1, 31, nil},
+		{"month", s.month[:], 1, 12, monthNames},
+		{"day-of-week", nil, 0, 7, dayNames},
+	}
+
+	for i, f := range fields {
+		set := f.set
+		if set == nil {
+			set = make([]bool, 8) // day-of-week accepts 7 as Sunday
+		}
+		if err := parseCronField(parts[i], set, f.min, f.max, f.names); err != nil {
+			return nil, fmt.Errorf("invalid cron %s field %q: %w", f.name, parts[i], err)
+		}
+		if f.set == nil {
+			copy(s.dow[:], set[:7])
+			s.dow[0] = s.dow[0] || set[7]
+		}
+	}
+
+	s.domStar = parts[2] == "*" || parts[2] == "?"
+	s.dowStar = parts[4] == "*" || parts[4] == "?"
+
+	return s, nil
+}
+
+func parseCronField(field string, set []bool, min, max int, names map[string]int) error {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = cronValue(a, names); err != nil {
+				return err
+			}
+			if hi, err = cronValue(b, names); err != nil {
+				return err
+			}
+		default:
+			v, err := cronValue(rangePart, names)
+			if err != nil {
+				return err
+			}
+			lo = v
+			if hasStep {
+				hi = max
+			} else {
+				hi = v
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return fmt.Errorf("value out of range %d-%d", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+func cronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+// Location returns the time zone the schedule is evaluated in.
+func (s *CronSchedule) Location() *time.Location {
+	return s.loc
+}
+
+func (s *CronSchedule) String() string {
+	return fmt.Sprintf("%s (%s)", s.expr, s.loc)
+}
+
+// Next returns the first scheduled instant strictly after t, or the zero time
+// if nothing matches within the next five years.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	local := t.In(s.loc)
+	for day := 0; day < 5*366; day++ {
+		d := time.Date(local.Year(), local.Month(), local.Day()+day, 12, 0, 0, 0, s.loc)
+		if !s.month[d.Month()] || !s.dayMatches(d) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if !s.hour[h] {
+				continue
+			}
+			for m := 0; m < 60; m++ {
+				if !s.minute[m] {
+					continue
+				}
+				candidate := s.resolve(d, h, m)
+				if candidate.After(t) {
+					return candidate
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// resolve returns the instant of the wall time h:m on day d, with the DST
+// rules of CronSchedule: a time in a spring-forward gap is shifted past it,
+// and a time repeated by fall-back is its first occurrence. time.Date leaves
+// both choices unspecified.
+func (s *CronSchedule) resolve(d time.Time, h, m int) time.Time {
+	t := time.Date(d.Year(), d.Month(), d.Day(), h, m, 0, 0, s.loc)
+	if t.Hour() != h || t.Minute() != m {
+		// In a gap; if t landed before it, move it forward by its length.
+		if gap := (h*60 + m) - (t.Hour()*60 + t.Minute()); gap > 0 {
+			t = t.Add(time.Duration(gap) * time.Minute)
+		}
+		return t
+	}
+
+	// Repeated: the same wall time a DST shift earlier is the first one.
+	_, offset := t.Zone()
+	_, before := t.Add(-3 * time.Hour).Zone()
+	if before > offset {
+		if earlier := t.Add(-time.Duration(before-offset) * time.Second); earlier.Hour() == h && earlier.Minute() == m {
+			return earlier
+		}
+	}
+	return t
+}
+
+// dayMatches applies cron's day rule: when both day-of-month and day-of-week
+// are restricted, either one matching is enough.
+func (s *CronSchedule) dayMatches(d time.Time) bool {
+	domOK := s.dom[d.Day()]
+	dowOK := s.dow[d.Weekday()]
+	switch {
+	case s.domStar && s.dowStar:
+		return true
+	case s.domStar:
+		return dowOK
+	case s.dowStar:
+		return domOK
+	default:
+		return domOK || dowOK
+	}
+}
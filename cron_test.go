@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr     string
+		timezone string
+		wantErr  bool
+	}{
+		{"0 9 * * 1-5", "", false},
+		{"*/15 * * * *", "Europe/Berlin", false},
+		{"0 9 1,15 jan-jun mon", "", false},
+		{"30 8 * * 7", "", false},
+		{"@daily", "America/New_York", false},
+		{"@WEEKLY", "", false},
+		{"0 9 ? * ?", "", false},
+		{"5/10 * * * *", "", false},
+		{"0 9 * *", "", true},
+		{"0 9 * * * *", "", true},
+		{"60 * * * *", "", true},
+		{"0 24 * * *", "", true},
+		{"0 0 0 * *", "", true},
+		{"0 0 * 13 *", "", true},
+		{"0 0 * * 8", "", true},
+		{"0 0 * * fri-mon", "", true},
+		{"*/0 * * * *", "", true},
+		{"a * * * *", "", true},
+		{"0 9 * * *", "Mars/Olympus", true},
+	}
+
+	for _, tt := range tests {
+		_, err := parseCron(tt.expr, tt.timezone)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCron(%q, %q) error = %v, wantErr %v", tt.expr, tt.timezone, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		timezone string
+		after    string // RFC 3339
+		want     string // RFC 3339
+	}{
+		{"later today", "0 9 * * *", "", "2024-06-03T08:00:00Z", "2024-06-03T09:00:00Z"},
+		{"strictly after", "0 9 * * *", "", "2024-06-03T09:00:00Z", "2024-06-04T09:00:00Z"},
+		{"weekdays skip the weekend", "0 9 * * 1-5", "", "2024-06-07T10:00:00Z", "2024-06-10T09:00:00Z"},
+		{"step", "*/15 * * * *", "", "2024-06-03T08:16:00Z", "2024-06-03T08:30:00Z"},
+		{"sunday as 7", "0 0 * * 7", "", "2024-06-03T00:00:00Z", "2024-06-09T00:00:00Z"},
+		{"day of month or week", "0 0 15 * mon", "", "2024-06-11T00:00:00Z", "2024-06-15T00:00:00Z"},
+		{"leap day", "0 0 29 2 *", "", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"time zone", "0 9 * * *", "Europe/Berlin", "2024-06-03T00:00:00Z", "2024-06-03T07:00:00Z"},
+
+		// Europe/Berlin springs forward from 02:00 to 03:00 on 2024-03-31
+		// and falls back from 03:00 to 02:00 on 2024-10-27.
+		{"local time before spring forward", "0 9 * * *", "Europe/Berlin", "2024-03-30T09:00:00Z", "2024-03-31T07:00:00Z"},
+		{"local time before fall back", "0 9 * * *", "Europe/Berlin", "2024-10-26T08:00:00Z", "2024-10-27T08:00:00Z"},
+		{"skipped time is shifted past the gap", "30 2 * * *", "Europe/Berlin", "2024-03-30T12:00:00Z", "2024-03-31T01:30:00Z"},
+		{"repeated time fires on its first occurrence", "30 2 * * *", "Europe/Berlin", "2024-10-26T12:00:00Z", "2024-10-27T00:30:00Z"},
+		{"repeated time fires once", "30 2 * * *", "Europe/Berlin", "2024-10-27T00:30:00Z", "2024-10-28T01:30:00Z"},
+		{"hourly skips the repeated hour", "0 * * * *", "Europe/Berlin", "2024-10-27T00:00:00Z", "2024-10-27T02:00:00Z"},
+		{"hourly across spring forward", "0 * * * *", "Europe/Berlin", "2024-03-31T00:00:00Z", "2024-03-31T01:00:00Z"},
+		{"US spring forward", "0 2 * * *", "America/New_York", "2024-03-09T12:00:00Z", "2024-03-10T07:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseCron(tt.expr, tt.timezone)
+			if err != nil {
+				t.Fatalf("parseCron: %v", err)
+			}
+			after, _ := time.Parse(time.RFC3339, tt.after)
+			want, _ := time.Parse(time.RFC3339, tt.want)
+			if got := s.Next(after); !got.Equal(want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got.UTC().Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNextNeverMatches(t *testing.T) {
+	s, err := parseCron("0 0 31 2 *", "")
+	if err != nil {
+		t.Fatalf("parseCron: %v", err)
+	}
+	if got := s.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next = %s, want the zero time for February 31st", got)
+	}
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// CYCLE REPORTS
+// ============================================================================
+
+// cycleReportKind selects which cycles a report covers and how it reads.
+type cycleReportKind string
+
+const (
+	cycleCheckin cycleReportKind = "checkin" // active cycles past their midpoint
+	cycleSummary cycleReportKind = "summary" // cycles that just ended
+)
+
+const (
+	// cycleSummaryWindow is how long after a cycle ends its summary is still
+	// posted, so a fresh deployment doesn't summarise long-finished cycles.
+	cycleSummaryWindow = 7 * 24 * time.Hour
+
+	// cyclePostRetention is how long the record of a posted check-in or
+	// summary is kept.
+	cyclePostRetention = 90 * 24 * time.Hour
+)
+
+func cycleTitle(c Cycle) string {
+	title := fmt.Sprintf("%s Cycle %d", c.Team.Name, c.Number)
+	if c.Name != "" {
+		title += ": " + c.Name
+	}
+	return title
+}
+
+// CycleProgress summarises the issues in a cycle.
+type CycleProgress struct {
+	Scope     []Issue
+	Completed []Issue
+	Canceled  []Issue
+	Remaining []Issue
+	// Added are the issues added to the cycle after it started.
+	Added []Issue
+}
+
+// cycleProgress classifies the issues of a cycle by their current state,
+// except that issues a closed cycle left uncompleted count as remaining even
+// if they were finished in a later cycle.
+func cycleProgress(c Cycle) CycleProgress {
+	var p CycleProgress
+	seen := map[string]bool{}
+	carriedOver := map[string]bool{}
+	for _, issue := range c.UncompletedIssuesUponClose.Nodes {
+		carriedOver[issue.ID] = true
+	}
+
+	issues := append(append([]Issue(nil), c.Issues.Nodes...), c.UncompletedIssuesUponClose.Nodes...)
+	for _, issue := range issues {
+		if seen[issue.ID] {
+			continue
+		}
+		seen[issue.ID] = true
+
+		p.Scope = append(p.Scope, issue)
+		switch {
+		case carriedOver[issue.ID]:
+			p.Remaining = append(p.Remaining, issue)
+		case issue.State.Type == "completed":
+			p.Completed = append(p.Completed, issue)
+		case issue.State.Type == "canceled":
+			p.Canceled = append(p.Canceled, issue)
+		default:
+			p.Remaining = append(p.Remaining, issue)
+		}
+		if issue.AddedToCycleAt != nil && issue.AddedToCycleAt.After(c.StartsAt) {
+			p.Added = append(p.Added, issue)
+		}
+	}
+	return p
+}
+
+// sumEstimates adds up the estimate points of the issues.
+func sumEstimates(issues []Issue) float64 {
+	var total float64
+	for _, issue := range issues {
+		if issue.Estimate != nil {
+			total += *issue.Estimate
+		}
+	}
+	return total
+}
+
+// fetchCycles returns the cycles matching a Linear CycleFilter in the report
+// filter's teams, without their issues; see loadCycleIssues.
+func fetchCycles(opts ReportOptions, cycleFilter map[string]interface{}) ([]Cycle, error) {
+	if opts.Filter != nil && len(opts.Filter.Teams) > 0 {
+		scoped := object("team", anyOf("key", "eqIgnoreCase", opts.Filter.Teams))
+		for key, value := range cycleFilter {
+			scoped[key] = value
+		}
+		cycleFilter = scoped
+	}
+	cycles, err := opts.linear().Cycles(opts.context(), cycleFilter)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i].Team.Name < cycles[j].Team.Name })
+	return cycles, nil
+}
+
+// loadCycleIssues fetches the issues of a cycle that match the report filter
+// and, once the cycle is closed, those it left uncompleted. Each is paged
+// separately to keep every query's complexity low.
+func loadCycleIssues(opts ReportOptions, c *Cycle) error {
+	issues, err := opts.linear().CycleIssues(opts.context(), c.ID, opts.Filter.graphQL())
+	if err != nil {
+		return fmt.Errorf("failed to fetch issues of %s: %w", cycleTitle(*c), err)
+	}
+	c.Issues.Nodes = issues
+
+	if c.CompletedAt != nil {
+		uncompleted, err := opts.linear().UncompletedIssuesUponClose(opts.context(), c.ID, opts.Filter.graphQL())
+		if err != nil {
+			return fmt.Errorf("failed to fetch carried over issues of %s: %w", cycleTitle(*c), err)
+		}
+		c.UncompletedIssuesUponClose.Nodes = uncompleted
+	}
+	return nil
+}
+
+// handleReportCycles posts the progress of every active cycle, or with
+// ?summary=true the summary of every team's previous cycle.
+func handleReportCycles(w http.ResponseWriter, r *http.Request) {
+	kind := cycleCheckin
+	if r.URL.Query().Get("summary") == "true" {
+		kind = cycleSummary
+	}
+
+	opts, ok := reportOptions(w, r)
+	if !ok {
+		return
+	}
+	if err := generateCycleReport(opts, kind, true); err != nil {
+		slog.ErrorContext(opts.context(), "Error generating cycle report", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if opts.dryRun() {
+		writePreview(w, r, opts.Preview)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "cycle_report_sent"})
+}
+
+// generateCycleCheckin posts a check-in once per active cycle, as soon as the
+// job runs after the cycle's midpoint.
+func generateCycleCheckin(opts ReportOptions) error {
+	return generateCycleReport(opts, cycleCheckin, false)
+}
+
+// generateCycleSummary posts a summary once per cycle, as soon as the job runs
+// after the cycle has ended.
+func generateCycleSummary(opts ReportOptions) error {
+	return generateCycleReport(opts, cycleSummary, false)
+}
+
+// generateCycleReport posts one message per cycle. Unless force is set, cycles
+// that aren't due yet or were already posted to this destination are skipped.
+func generateCycleReport(opts ReportOptions, kind cycleReportKind, force bool) error {
+	cycleFilter := object("isActive", object("eq", true))
+	if kind == cycleSummary {
+		cycleFilter = object("isPrevious", object("eq", true))
+	}
+
+	slog.InfoContext(opts.context(), "Fetching cycles...", "report", kind)
+	cycles, err := fetchCycles(opts, cycleFilter)
+	if err != nil {
+		return fmt.Errorf("failed to fetch cycles: %w", err)
+	}
+
+	now := time.Now()
+	posted := 0
+	for _, c := range cycles {
+		if !force {
+			if !cycleDue(c, kind, now) {
+				continue
+			}
+			done, err := cyclePosted(opts, c, kind)
+			if err != nil {
+				return err
+			}
+			if done {
+				continue
+			}
+		}
+
+		if err := loadCycleIssues(opts, &c); err != nil {
+			return err
+		}
+
+		var payload *DiscordWebhook
+		if kind == cycleSummary {
+			payload = cycleSummaryMessage(c, now)
+		} else {
+			payload = cycleCheckinMessage(c, now)
+		}
+		if err := opts.send(payload); err != nil {
+			return err
+		}
+		posted++
+
+		if err := markCyclePosted(opts, c, kind, now); err != nil {
+			slog.WarnContext(opts.context(), "Cycles: Could not record posted cycle", "report", kind, "cycle", cycleTitle(c), "error", err)
+		}
+	}
+
+	slog.InfoContext(opts.context(), "Cycles: Posted cycle reports", "report", kind, "posted", posted, "cycles", len(cycles))
+
+	if posted == 0 && force {
+		description := "No team has an active cycle."
+		if kind == cycleSummary {
+			description = "No team has a finished cycle."
+		}
+		return opts.send(&DiscordWebhook{
+			Username:  "Linear Cycles",
+			AvatarURL: linearAvatarURL,
+			Embeds: []DiscordEmbed{{
+				Title:       "🔄 Cycles",
+				Description: description,
+				Color:       ColorGray,
+				Timestamp:   now.UTC().Format(time.RFC3339),
+			}},
+		})
+	}
+	return nil
+}
+
+func cycleDue(c Cycle, kind cycleReportKind, now time.Time) bool {
+	if kind == cycleSummary {
+		return now.After(c.EndsAt) && now.Sub(c.EndsAt) < cycleSummaryWindow
+	}
+	midpoint := c.StartsAt.Add(c.EndsAt.Sub(c.StartsAt) / 2)
+	return !now.Before(midpoint) && now.Before(c.EndsAt)
+}
+
+func cyclePostKey(opts ReportOptions, c Cycle, kind cycleReportKind) string {
+	return fmt.Sprintf("%s:%s@%s", kind, c.ID, opts.destinationName())
+}
+
+func cyclePosted(opts ReportOptions, c Cycle, kind cycleReportKind) (bool, error) {
+	if store == nil {
+		return false, nil
+	}
+	posts := map[string]time.Time{}
+	if err := store.load("cycle-posts", &posts); err != nil {
+		return false, err
+	}
+	_, ok := posts[cyclePostKey(opts, c, kind)]
+	return ok, nil
+}
+
+// markCyclePosted records that a cycle's check-in or summary went out, and
+// drops expired records.
+func markCyclePosted(opts ReportOptions, c Cycle, kind cycleReportKind, now time.Time) error {
+	if store == nil || opts.dryRun() {
+		return nil
+	}
+	posts := map[string]time.Time{}
+	return store.update("cycle-posts", &posts, func() error {
+		posts[cyclePostKey(opts, c, kind)] = now.UTC()
+		for key, at := range posts {
+			if now.Sub(at) > cyclePostRetention {
+				delete(posts, key)
+			}
+		}
+		return nil
+	})
+}
+
+func cycleCheckinMessage(c Cycle, now time.Time) *DiscordWebhook {
+	p := cycleProgress(c)
+
+	elapsed := percent(int(now.Sub(c.StartsAt)/time.Hour), int(c.EndsAt.Sub(c.StartsAt)/time.Hour))
+	daysLeft := int(math.Ceil(c.EndsAt.Sub(now).Hours() / 24))
+
+	description := fmt.Sprintf("%s **%d%%** complete · %d%% of the cycle elapsed\n⏳ **%d days left** (ends %s)",
+		progressBar(len(p.Completed), len(p.Scope)-len(p.Canceled)),
+		percent(len(p.Completed), len(p.Scope)-len(p.Canceled)),
+		elapsed, daysLeft, c.EndsAt.UTC().Format("Mon Jan 2"))
+
+	fields := []DiscordField{
+		{Name: "📦 Scope", Value: formatIssueCount(p.Scope), Inline: true},
+		{Name: "✅ Completed", Value: formatIssueCount(p.Completed), Inline: true},
+		{Name: "⏳ Remaining", Value: formatIssueCount(p.Remaining), Inline: true},
+	}
+	if len(p.Added) > 0 {
+		fields = append(fields, DiscordField{Name: "➕ Added Mid-Cycle", Value: formatIssueCount(p.Added), Inline: true})
+	}
+	if len(p.Remaining) > 0 {
+		var lines []string
+		for _, group := range groupByAssignee(p.Remaining) {
+			lines = append(lines, fmt.Sprintf("• %s: %s", group.Name, formatIssueCount(group.Issues)))
+		}
+		fields = append(fields, DiscordField{Name: "👥 Remaining by Assignee", Value: strings.Join(lines, "\n")})
+	}
+
+	embeds := []DiscordEmbed{{
+		Title:       "🔄 " + cycleTitle(c) + " - Check-in",
+		Description: description,
+		Color:       ColorBlue,
+		Fields:      fields,
+		Timestamp:   now.UTC().Format(time.RFC3339),
+	}}
+
+	if len(p.Added) > 0 {
+		embeds = append(embeds, DiscordEmbed{
+			Title: "➕ Added Since Cycle Start",
+			Description: formatIssuesByAssignee(p.Added, func(issue Issue) string {
+				return issue.State.Name
+			}),
+			Color: ColorYellow,
+		})
+	}
+
+	payload := &DiscordWebhook{Username: "Linear Cycles", AvatarURL: linearAvatarURL, Embeds: embeds}
+	if appConfig.chartsEnabled() {
+		payload.Embeds[0].Image = attachChart(payload, "burndown.png", burndownChart(c), (*Chart).renderLines)
+	}
+	return payload
+}
+
+func cycleSummaryMessage(c Cycle, now time.Time) *DiscordWebhook {
+	p := cycleProgress(c)
+
+	description := fmt.Sprintf("%s – %s\n%s **%d%%** complete · **%d** of %d issues done",
+		c.StartsAt.UTC().Format("Jan 2"), c.EndsAt.UTC().Format("Jan 2, 2006"),
+		progressBar(len(p.Completed), len(p.Scope)-len(p.Canceled)),
+		percent(len(p.Completed), len(p.Scope)-len(p.Canceled)),
+		len(p.Completed), len(p.Scope)-len(p.Canceled))
+
+	fields := []DiscordField{
+		{Name: "📦 Scope", Value: formatIssueCount(p.Scope), Inline: true},
+		{Name: "✅ Completed", Value: formatIssueCount(p.Completed), Inline: true},
+		{Name: "↪️ Carried Over", Value: formatIssueCount(p.Remaining), Inline: true},
+	}
+	if len(p.Added) > 0 {
+		fields = append(fields, DiscordField{Name: "➕ Added Mid-Cycle", Value: formatIssueCount(p.Added), Inline: true})
+	}
+	if len(p.Canceled) > 0 {
+		fields = append(fields, DiscordField{Name: "❌ Canceled", Value: formatIssueCount(p.Canceled), Inline: true})
+	}
+	if len(p.Completed) > 0 {
+		var lines []string
+		for _, group := range groupByAssignee(p.Completed) {
+			lines = append(lines, fmt.Sprintf("• %s: %s", group.Name, formatIssueCount(group.Issues)))
+		}
+		fields = append(fields, DiscordField{Name: "👥 Completed by Assignee", Value: strings.Join(lines, "\n")})
+	}
+
+	color := ColorGreen
+	if len(p.Remaining) > 0 {
+		color = ColorYellow
+	}
+
+	embeds := []DiscordEmbed{{
+		Title:       "🏁 " + cycleTitle(c) + " - Summary",
+		Description: description,
+		Color:       color,
+		Fields:      fields,
+		Timestamp:   now.UTC().Format(time.RFC3339),
+	}}
+
+	if len(p.Remaining) > 0 {
+		embeds = append(embeds, DiscordEmbed{
+			Title: "↪️ Carried Over",
+			Description: formatIssuesByAssignee(p.Remaining, func(issue Issue) string {
+				return issue.State.Name
+			}),
+			Color: ColorGray,
+		})
+	}
+
+	payload := &DiscordWebhook{Username: "Linear Cycles", AvatarURL: linearAvatarURL, Embeds: embeds}
+	if appConfig.chartsEnabled() {
+		payload.Embeds[0].Image = attachChart(payload, "burndown.png", burndownChart(c), (*Chart).renderLines)
+	}
+	return payload
+}
+
+// formatIssueCount renders "5 issues · 13 pts", leaving out points when no
+// issue is estimated.
+func formatIssueCount(issues []Issue) string {
+	s := fmt.Sprintf("%d issues", len(issues))
+	if len(issues) == 1 {
+		s = "1 issue"
+	}
+	if points := sumEstimates(issues); points > 0 {
+		s += fmt.Sprintf(" · %s pts", formatPoints(points))
+	}
+	return s
+}
+
+func percent(n, total int) int {
+	if total <= 0 {
+		return 0
+	}
+	return n * 100 / total
+}
+
+// progressBar renders a ten-cell bar such as "▓▓▓▓░░░░░░".
+func progressBar(n, total int) string {
+	filled := percent(n, total) / 10
+	if filled > 10 {
+		filled = 10
+	}
+	return strings.Repeat("▓", filled) + strings.Repeat("░", 10-filled)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCycleProgress(t *testing.T) {
+	start := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	before, during := start.Add(-time.Hour), start.Add(48*time.Hour)
+	issue := func(id, stateType string, addedAt time.Time) Issue {
+		added := addedAt
+		return Issue{ID: id, Identifier: "ENG-" + id, State: State{Type: stateType}, AddedToCycleAt: &added}
+	}
+
+	var c Cycle
+	c.StartsAt = start
+	c.EndsAt = start.Add(14 * 24 * time.Hour)
+	c.Issues.Nodes = []Issue{
+		issue("1", "completed", before),
+		issue("2", "canceled", before),
+		issue("3", "started", before),
+		issue("4", "completed", during), // added mid-cycle and finished
+		issue("5", "completed", before), // carried over, finished in the next cycle
+		{ID: "6", Identifier: "ENG-6", State: State{Type: "unstarted"}},
+	}
+	// Issues a closed cycle left uncompleted; ENG-7 has since moved to the
+	// next cycle and is no longer in Issues.
+	c.UncompletedIssuesUponClose.Nodes = []Issue{
+		issue("5", "completed", before),
+		issue("7", "started", during),
+	}
+
+	p := cycleProgress(c)
+
+	ids := func(issues []Issue) []string {
+		var ids []string
+		for _, i := range issues {
+			ids = append(ids, i.ID)
+		}
+		return ids
+	}
+	tests := []struct {
+		name string
+		got  []Issue
+		want []string
+	}{
+		{"scope", p.Scope, []string{"1", "2", "3", "4", "5", "6", "7"}},
+		{"completed", p.Completed, []string{"1", "4"}},
+		{"canceled", p.Canceled, []string{"2"}},
+		{"remaining", p.Remaining, []string{"3", "5", "6", "7"}},
+		{"added", p.Added, []string{"4", "7"}},
+	}
+	for _, tt := range tests {
+		if got := ids(tt.got); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCycleDue(t *testing.T) {
+	var c Cycle
+	c.StartsAt = time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	c.EndsAt = c.StartsAt.Add(14 * 24 * time.Hour)
+	midpoint := c.StartsAt.Add(7 * 24 * time.Hour)
+
+	tests := []struct {
+		kind cycleReportKind
+		now  time.Time
+		want bool
+	}{
+		{cycleCheckin, c.StartsAt, false},
+		{cycleCheckin, midpoint.Add(-time.Second), false},
+		{cycleCheckin, midpoint, true},
+		{cycleCheckin, c.EndsAt.Add(-time.Second), true},
+		{cycleCheckin, c.EndsAt, false},
+		{cycleSummary, c.EndsAt.Add(-time.Second), false},
+		{cycleSummary, c.EndsAt.Add(time.Second), true},
+		{cycleSummary, c.EndsAt.Add(cycleSummaryWindow), false},
+	}
+	for _, tt := range tests {
+		if got := cycleDue(c, tt.kind, tt.now); got != tt.want {
+			t.Errorf("cycleDue(%s, %s) = %v, want %v", tt.kind, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		n, total int
+		want     string
+	}{
+		{0, 0, "░░░░░░░░░░"},
+		{0, 10, "░░░░░░░░░░"},
+		{3, 10, "▓▓▓░░░░░░░"},
+		{19, 20, "▓▓▓▓▓▓▓▓▓░"},
+		{10, 10, "▓▓▓▓▓▓▓▓▓▓"},
+		{12, 10, "▓▓▓▓▓▓▓▓▓▓"},
+	}
+	for _, tt := range tests {
+		if got := progressBar(tt.n, tt.total); got != tt.want {
+			t.Errorf("progressBar(%d, %d) = %s, want %s", tt.n, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestFormatIssueCount(t *testing.T) {
+	pts := func(f float64) *float64 { return &f }
+	tests := []struct {
+		issues []Issue
+		want   string
+	}{
+		{nil, "0 issues"},
+		{[]Issue{{}}, "1 issue"},
+		{[]Issue{{Estimate: pts(3)}, {Estimate: pts(2)}, {}}, "3 issues · 5 pts"},
+	}
+	for _, tt := range tests {
+		if got := formatIssueCount(tt.issues); got != tt.want {
+			t.Errorf("formatIssueCount = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ============================================================================
+// DISCORD BOT
+// ============================================================================
+
+const discordAPIURL = "https://discord.com/api/v10"
+
+// Bot credentials. Webhooks can only post to one channel, so direct messages
+// and slash commands need a bot.
+var (
+	discordBotToken      string
+	discordApplicationID string
+	discordPublicKey     ed25519.PublicKey
+)
+
+func loadDiscordBot(token, applicationID, publicKey string) error {
+	discordBotToken = token
+	discordApplicationID = applicationID
+	if publicKey == "" {
+		return nil
+	}
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("DISCORD_PUBLIC_KEY must be a %d-byte hex key", ed25519.PublicKeySize)
+	}
+	discordPublicKey = key
+	return nil
+}
+
+// discordAPI calls the Discord REST API as the bot and decodes the response
+// into out, if given.
+func discordAPI(ctx context.Context, method, path string, body, out interface{}) (err error) {
+	if discordBotToken == "" {
+		return fmt.Errorf("DISCORD_BOT_TOKEN not configured")
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal discord request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, discordAPIURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bot "+discordBotToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	_, span := startSpan(ctx, "discord.api", spanKindClient, "http.request.method", method)
+	defer func() { span.end(err) }()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	start := time.Now()
+	resp, err := client.Do(req)
+	observeDiscordRequest("bot", start, resp)
+	if resp != nil {
+		span.set("http.response.status_code", resp.StatusCode)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to call discord: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read discord response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("discord returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse discord response: %w", err)
+		}
+	}
+	return nil
+}
+
+// sendDirectMessage opens (or reuses) the DM channel with a user and posts
+// the message there. Like sendToWebhook, it spools the message during
+// shutdown, unless Discord rejected it.
+func sendDirectMessage(ctx context.Context, userID string, payload *DiscordWebhook) error {
+	if spooling() {
+		return spool(ctx, &OutboxMessage{DiscordUser: userID, Payload: payload})
+	}
+	outboxDepth.Add(1)
+	defer outboxDepth.Add(-1)
+
+	sendCtx, cancel := deliveryContext(ctx)
+	defer cancel()
+	err := postDirectMessage(sendCtx, userID, payload)
+	if err != nil && spooling() && interrupted(err) {
+		return spool(ctx, &OutboxMessage{DiscordUser: userID, Payload: payload})
+	}
+	return err
+}
+
+func postDirectMessage(ctx context.Context, userID string, payload *DiscordWebhook) error {
+	var channel struct {
+		ID string `json:"id"`
+	}
+	if err := discordAPI(ctx, "POST", "/users/@me/channels", map[string]string{"recipient_id": userID}, &channel); err != nil {
+		return fmt.Errorf("failed to open DM channel: %w", err)
+	}
+
+	message := map[string]interface{}{"content": payload.Content, "embeds": payload.Embeds}
+	return discordAPI(ctx, "POST", "/channels/"+channel.ID+"/messages", message, nil)
+}
+
+// SlashCommand is an application command definition.
+type SlashCommand struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Options     []SlashCommandOption `json:"options,omitempty"`
+}
+
+type SlashCommandOption struct {
+	Type        int                  `json:"type"` // 3 = string
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Required    bool                 `json:"required,omitempty"`
+	Choices     []SlashCommandChoice `json:"choices,omitempty"`
+}
+
+type SlashCommandChoice struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// slashCommands are the commands this relay answers, keyed by name. Handle
+// returns the reply, which only the invoking user sees.
+var slashCommands = map[string]struct {
+	Command SlashCommand
+	Handle  func(Interaction) string
+}{
+	digestCommand.Name: {Command: digestCommand, Handle: handleDigestCommand},
+}
+
+// registerSlashCommands creates or updates every command. Discord matches
+// commands by name, so other commands of the application are left alone.
+func registerSlashCommands() {
+	if discordBotToken == "" || discordApplicationID == "" {
+		return
+	}
+	for name, cmd := range slashCommands {
+		if err := discordAPI(context.Background(), "POST", "/applications/"+discordApplicationID+"/commands", cmd.Command, nil); err != nil {
+			slog.Warn("Discord: Could not register command", "command", name, "error", err)
+			continue
+		}
+		slog.Info("Discord: Registered command", "command", name)
+	}
+}
+
+// Interaction is the part of a Discord interaction the relay uses.
+type Interaction struct {
+	Type int `json:"type"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string          `json:"name"`
+			Value json.RawMessage `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+	// Member is set in guilds, User in DMs.
+	Member *struct {
+		User DiscordUser `json:"user"`
+	} `json:"member"`
+	User *DiscordUser `json:"user"`
+}
+
+type DiscordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+const (
+	interactionPing    = 1
+	interactionCommand = 2
+
+	responsePong    = 1
+	responseMessage = 4
+
+	messageEphemeral = 1 << 6
+)
+
+func (i Interaction) userID() string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// option returns a string option of the command, or "".
+func (i Interaction) option(name string) string {
+	for _, opt := range i.Data.Options {
+		if opt.Name == name {
+			var s string
+			json.Unmarshal(opt.Value, &s)
+			return s
+		}
+	}
+	return ""
+}
+
+// handleDiscordInteractions is the bot's interactions endpoint URL. Discord
+// signs every request; unsigned or tampered requests are rejected.
+func handleDiscordInteractions(w http.ResponseWriter, r *http.Request) {
+	if discordPublicKey == nil {
+		http.Error(w, "DISCORD_PUBLIC_KEY not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	signature, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	timestamp := r.Header.Get("X-Signature-Timestamp")
+	if err != nil || !ed25519.Verify(discordPublicKey, append([]byte(timestamp), body...), signature) {
+		http.Error(w, "Invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	var interaction Interaction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch interaction.Type {
+	case interactionPing:
+		json.NewEncoder(w).Encode(map[string]int{"type": responsePong})
+	case interactionCommand:
+		reply := "Unknown command."
+		if cmd, ok := slashCommands[interaction.Data.Name]; ok {
+			reply = cmd.Handle(interaction)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"type": responseMessage,
+			"data": map[string]interface{}{"content": reply, "flags": messageEphemeral},
+		})
+	default:
+		http.Error(w, "Unsupported interaction type", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// ============================================================================
+// DIRECT MESSAGE DIGESTS
+// ============================================================================
+
+// DMConfig is the "dm" section of the config.
+type DMConfig struct {
+	// Users maps Linear users, by email or display name, to Discord user IDs.
+	// Only mapped users can receive a DM digest.
+	Users map[string]string `json:"users"`
+	// OptInByDefault sends the digest to mapped users who never ran the
+	// slash command. Otherwise they must opt in first.
+	OptInByDefault bool `json:"opt_in_by_default,omitempty"`
+}
+
+// DMSubscription is one Discord user's digest settings, stored in
+// STATE_DIR/dm-subscriptions.json keyed by Discord user ID.
+type DMSubscription struct {
+	Enabled    bool      `json:"enabled"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	LastDigest time.Time `json:"last_digest"`
+}
+
+// dmMentionWindow is how far back mentions go for a user's first digest.
+const dmMentionWindow = 24 * time.Hour
+
+// dmRecipient is a mapped user, identified on both sides.
+type dmRecipient struct {
+	Linear    string // email or display name from the config
+	DiscordID string
+}
+
+func (c *DMConfig) recipients() []dmRecipient {
+	if c == nil {
+		return nil
+	}
+	var list []dmRecipient
+	for linear, discordID := range c.Users {
+		list = append(list, dmRecipient{Linear: linear, DiscordID: discordID})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Linear < list[j].Linear })
+	return list
+}
+
+// linearUserFor returns the Linear user mapped to a Discord user, or "".
+func (c *DMConfig) linearUserFor(discordID string) string {
+	for _, r := range c.recipients() {
+		if r.DiscordID == discordID {
+			return r.Linear
+		}
+	}
+	return ""
+}
+
+// is reports whether a Linear user is the one this recipient is mapped from.
+func (r dmRecipient) is(user *User) bool {
+	return user != nil && (strings.EqualFold(user.Email, r.Linear) || strings.EqualFold(user.DisplayName, r.Linear))
+}
+
+func loadDMSubscriptions() (map[string]*DMSubscription, error) {
+	subs := map[string]*DMSubscription{}
+	if store == nil {
+		return subs, nil
+	}
+	err := store.load("dm-subscriptions", &subs)
+	return subs, err
+}
+
+func (c *DMConfig) subscribed(subs map[string]*DMSubscription, discordID string) bool {
+	if sub := subs[discordID]; sub != nil {
+		return sub.Enabled
+	}
+	return c != nil && c.OptInByDefault
+}
+
+func updateDMSubscription(discordID string, fn func(sub *DMSubscription)) error {
+	subs := map[string]*DMSubscription{}
+	return store.update("dm-subscriptions", &subs, func() error {
+		sub := subs[discordID]
+		if sub == nil {
+			sub = &DMSubscription{Enabled: appConfig.DM != nil && appConfig.DM.OptInByDefault}
+			subs[discordID] = sub
+		}
+		fn(sub)
+		return nil
+	})
+}
+
+// ============================================================================
+// SLASH COMMAND
+// ============================================================================
+
+var digestCommand = SlashCommand{
+	Name:        "linear-digest",
+	Description: "Turn your daily Linear DM digest on or off",
+	Options: []SlashCommandOption{{
+		Type:        3,
+		Name:        "action",
+		Description: "What to do",
+		Required:    true,
+		Choices: []SlashCommandChoice{
+			{Name: "on", Value: "on"},
+			{Name: "off", Value: "off"},
+			{Name: "status", Value: "status"},
+		},
+	}},
+}
+
+func handleDigestCommand(i Interaction) string {
+	discordID := i.userID()
+	linearUser := appConfig.DM.linearUserFor(discordID)
+	if linearUser == "" {
+		return "Your Discord account isn't linked to a Linear user. Ask an admin to add you to `dm.users` in the relay config."
+	}
+	if store == nil {
+		return "The digest is unavailable right now."
+	}
+
+	action := i.option("action")
+	switch action {
+	case "on", "off":
+		err := updateDMSubscription(discordID, func(sub *DMSubscription) {
+			sub.Enabled = action == "on"
+			sub.UpdatedAt = time.Now().UTC()
+		})
+		if err != nil {
+			slog.Error("DM: Could not update subscription", "user", redactString(linearUser), "error", err)
+			return "Something went wrong, please try again."
+		}
+		slog.Info("DM: Digest toggled", "user", redactString(linearUser), "enabled", action == "on")
+		if action == "on" {
+			return fmt.Sprintf("✅ You'll get your Linear digest (as **%s**) by DM.", linearUser)
+		}
+		return "🔕 Your Linear DM digest is off. Run `/linear-digest on` to turn it back on."
+	default:
+		subs, err := loadDMSubscriptions()
+		if err != nil {
+			slog.Error("DM: Could not load subscriptions", "error", err)
+			return "Something went wrong, please try again."
+		}
+		if appConfig.DM.subscribed(subs, discordID) {
+			return fmt.Sprintf("✅ Your Linear DM digest is on (as **%s**).", linearUser)
+		}
+		return "🔕 Your Linear DM digest is off."
+	}
+}
+
+// ============================================================================
+// DIGEST
+// ============================================================================
+
+// generateDMDigests sends each subscribed user their own issues by DM. A
+// failure for one user is logged and doesn't stop the others.
+func generateDMDigests(opts ReportOptions) error {
+	if discordBotToken == "" {
+		return fmt.Errorf("DISCORD_BOT_TOKEN not configured")
+	}
+
+	subs, err := loadDMSubscriptions()
+	if err != nil {
+		return err
+	}
+	var recipients []dmRecipient
+	for _, r := range appConfig.DM.recipients() {
+		if appConfig.DM.subscribed(subs, r.DiscordID) {
+			recipients = append(recipients, r)
+		}
+	}
+	if len(recipients) == 0 {
+		slog.InfoContext(opts.context(), "DM digest: No subscribed users")
+		return nil
+	}
+
+	slog.InfoContext(opts.context(), "Fetching issues for DM digests...")
+	issues, err := fetchAllOpenIssues(opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch issues: %w", err)
+	}
+	reviews, err := fetchReviewIssues(opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch review issues: %w", err)
+	}
+
+	now := time.Now()
+	since := now.Add(-dmMentionWindow)
+	for _, r := range recipients {
+		if sub := subs[r.DiscordID]; sub != nil && !sub.LastDigest.IsZero() && sub.LastDigest.Before(since) {
+			since = sub.LastDigest
+		}
+	}
+	comments, err := fetchCommentsSince(opts, since)
+	if err != nil {
+		return fmt.Errorf("failed to fetch comments: %w", err)
+	}
+
+	sent, failed := 0, 0
+	for _, r := range recipients {
+		mentionsSince := now.Add(-dmMentionWindow)
+		if sub := subs[r.DiscordID]; sub != nil && !sub.LastDigest.IsZero() {
+			mentionsSince = sub.LastDigest
+		}
+
+		digest := buildDMDigest(r, issues, reviews, comments, mentionsSince, now)
+		if digest == nil {
+			slog.DebugContext(opts.context(), "DM digest: Nothing to send", "user", redactString(r.Linear))
+			continue
+		}
+		if opts.dryRun() {
+			opts.Preview.add("dm:"+r.Linear, digest)
+			continue
+		}
+		if err := sendDirectMessage(opts.context(), r.DiscordID, digest); err != nil {
+			slog.ErrorContext(opts.context(), "DM digest: Could not send", "user", redactString(r.Linear), "error", err)
+			failed++
+			continue
+		}
+		sent++
+
+		if err := updateDMSubscription(r.DiscordID, func(sub *DMSubscription) { sub.LastDigest = now.UTC() }); err != nil {
+			slog.WarnContext(opts.context(), "DM digest: Could not record digest", "user", redactString(r.Linear), "error", err)
+		}
+	}
+
+	slog.InfoContext(opts.context(), "DM digest: Done", "sent", sent, "failed", failed)
+	if failed > 0 && sent == 0 {
+		return fmt.Errorf("failed to send %d DM digests", failed)
+	}
+	return nil
+}
+
+// ReviewIssue is an open issue waiting in a review state, with the people
+// subscribed to it.
+type ReviewIssue struct {
+	Issue
+	Subscribers struct {
+		Nodes []User `json:"nodes"`
+	} `json:"subscribers"`
+}
+
+// fetchReviewIssues returns the open issues whose state name contains
+// "review". Subscribers other than the assignee count as requested reviewers.
+func fetchReviewIssues(opts ReportOptions) ([]ReviewIssue, error) {
+	query := `
+		query($cursor: String, $filter: IssueFilter) {
+			issues(filter: $filter, first: 100, after: $cursor) {
+				nodes {` + linear.IssueFields + `
+					subscribers {
+						nodes {
+							id
+							name
+							displayName
+							email
+						}
+					}
+				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+
+	inReview := object("state", map[string]interface{}{
+		"name": object("containsIgnoreCase", "review"),
+		"type": object("nin", []string{"completed", "canceled"}),
+	})
+	variables := map[string]interface{}{"filter": issueFilterVariable(inReview, opts.Filter)}
+
+	return linear.Paginate[ReviewIssue](opts.context(), opts.linear(), query, variables, "issues")
+}
+
+// fetchCommentsSince returns the comments created after the given time.
+func fetchCommentsSince(opts ReportOptions, since time.Time) ([]IssueComment, error) {
+	return opts.linear().CommentsSince(opts.context(), since)
+}
+
+// mentionPattern matches a mention of the user in a comment body, either as
+// "@displayname" or as a link to their Linear profile. It is nil if the user
+// has no display name. The name must end at a non-word character rather than
+// at \b, which doesn't work for names ending in punctuation.
+func mentionPattern(user *User) *regexp.Regexp {
+	if user == nil || user.DisplayName == "" {
+		return nil
+	}
+	name := regexp.QuoteMeta(user.DisplayName)
+	return regexp.MustCompile(`(?i)(?:@|/profiles/)` + name + `(?:$|[^\w])`)
+}
+
+// buildDMDigest returns the DM for one user, or nil if there is nothing to
+// tell them.
+func buildDMDigest(r dmRecipient, issues []Issue, reviews []ReviewIssue, comments []IssueComment, since, now time.Time) *DiscordWebhook {
+	var user *User
+	var inProgress, todo, overdue []Issue
+	for _, issue := range issues {
+		if !r.is(issue.Assignee) {
+			continue
+		}
+		user = issue.Assignee
+		if isOverdue(issue, now) {
+			overdue = append(overdue, issue)
+		}
+		switch issue.State.Type {
+		case "started":
+			inProgress = append(inProgress, issue)
+		case "unstarted":
+			todo = append(todo, issue)
+		}
+	}
+
+	var reviewRequests []Issue
+	for _, review := range reviews {
+		if r.is(review.Assignee) {
+			continue
+		}
+		for i := range review.Subscribers.Nodes {
+			if sub := &review.Subscribers.Nodes[i]; r.is(sub) {
+				user = sub
+				reviewRequests = append(reviewRequests, review.Issue)
+				break
+			}
+		}
+	}
+
+	var mentioned []IssueComment
+	if user == nil {
+		// Without an issue we can't know the display name; fall back to
+		// the configured one.
+		user = &User{DisplayName: r.Linear}
+	}
+	mention := mentionPattern(user)
+	for _, c := range comments {
+		if mention == nil || c.Issue == nil || c.CreatedAt.Before(since) || r.is(c.User) {
+			continue
+		}
+		if mention.MatchString(c.Body) {
+			mentioned = append(mentioned, c)
+		}
+	}
+
+	if len(inProgress)+len(todo)+len(overdue)+len(reviewRequests)+len(mentioned) == 0 {
+		return nil
+	}
+
+	embeds := []DiscordEmbed{{
+		Title: "☀️ Your Linear Digest",
+		Description: fmt.Sprintf("🔵 **%d** in progress | ⚪ **%d** todo | ⏰ **%d** overdue | 👀 **%d** to review | 💬 **%d** mentions",
+			len(inProgress), len(todo), len(overdue), len(reviewRequests), len(mentioned)),
+		Color:     ColorBlue,
+		Timestamp: now.UTC().Format(time.RFC3339),
+		Footer:    &DiscordFooter{Text: "Run /linear-digest off to stop these messages"},
+	}}
+
+	list := func(title string, color int, issues []Issue, detail func(Issue) string) {
+		if len(issues) == 0 {
+			return
+		}
+		const maxLines = 10
+		var lines []string
+		for i, issue := range issues {
+			if i == maxLines {
+				lines = append(lines, fmt.Sprintf("*... and %d more*", len(issues)-maxLines))
+				break
+			}
+			line := fmt.Sprintf("%s [%s](%s) - %s", getPriorityEmoji(issue.Priority), issue.Identifier, issue.URL, truncate(issue.Title, 50))
+			if detail != nil {
+				line += " · " + detail(issue)
+			}
+			lines = append(lines, line)
+		}
+		embeds = append(embeds, DiscordEmbed{Title: title, Description: strings.Join(lines, "\n"), Color: color})
+	}
+
+	list("⏰ Overdue", ColorRed, overdue, func(issue Issue) string { return "due " + issue.DueDate })
+	list("🔵 In Progress", ColorYellow, inProgress, nil)
+	list("⚪ Todo", ColorGray, todo, nil)
+	list("👀 Review Requests", ColorPurple, reviewRequests, func(issue Issue) string { return assigneeName(issue.Assignee) })
+
+	if len(mentioned) > 0 {
+		const maxLines = 10
+		var lines []string
+		for i, c := range mentioned {
+			if i == maxLines {
+				lines = append(lines, fmt.Sprintf("*... and %d more*", len(mentioned)-maxLines))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("• [%s](%s) %s: %s",
+				c.Issue.Identifier, c.URL, assigneeName(c.User), truncate(strings.ReplaceAll(c.Body, "\n", " "), 60)))
+		}
+		embeds = append(embeds, DiscordEmbed{
+			Title:       "💬 Mentions",
+			Description: strings.Join(lines, "\n"),
+			Color:       ColorPurple,
+		})
+	}
+
+	return &DiscordWebhook{Embeds: embeds}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestMentionPattern(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"jane", "thanks @jane", true},
+		{"jane", "@Jane can you look?", true},
+		{"jane", "see https://linear.app/acme/profiles/jane for details", true},
+		{"jane", "@janet please", false},
+		{"jane", "jane@example.com", false},
+		{"jane", "no mention here", false},
+		{"j.doe.", "cc @j.doe.", true},
+		{"j.doe.", "cc @j.doe., thanks", true},
+		{"j.doe.", "cc @j.doe.x", false},
+		{"a+b", "@a+b!", true},
+		{"a+b", "@aab", false},
+	}
+	for _, tt := range tests {
+		re := mentionPattern(&User{DisplayName: tt.name})
+		if got := re.MatchString(tt.body); got != tt.want {
+			t.Errorf("mentionPattern(%q).MatchString(%q) = %v, want %v", tt.name, tt.body, got, tt.want)
+		}
+	}
+
+	if mentionPattern(nil) != nil || mentionPattern(&User{}) != nil {
+		t.Error("mentionPattern returned a pattern for a user without a display name")
+	}
+}
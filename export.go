@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// EXPORT
+// ============================================================================
+
+// ExportRow is one issue in an export.
+type ExportRow struct {
+	Identifier string   `json:"identifier"`
+	Title      string   `json:"title"`
+	Status     string   `json:"status"`
+	Priority   string   `json:"priority"`
+	Assignee   string   `json:"assignee"`
+	Team       string   `json:"team"`
+	Labels     []string `json:"labels"`
+	CreatedAt  string   `json:"created_at"`
+	UpdatedAt  string   `json:"updated_at"`
+	URL        string   `json:"url"`
+}
+
+// ExportGroup is a status or assignee group of an export, as in the digest.
+type ExportGroup struct {
+	Name   string      `json:"name"`
+	Count  int         `json:"count"`
+	Issues []ExportRow `json:"issues"`
+}
+
+var exportColumns = []string{"identifier", "title", "status", "priority", "assignee", "team", "labels", "created_at", "updated_at", "url"}
+
+func newExportRow(issue Issue) ExportRow {
+	labels := make([]string, len(issue.Labels.Nodes))
+	for i, label := range issue.Labels.Nodes {
+		labels[i] = label.Name
+	}
+	return ExportRow{
+		Identifier: issue.Identifier,
+		Title:      issue.Title,
+		Status:     issue.State.Name,
+		Priority:   issue.PriorityLabel,
+		Assignee:   assigneeName(issue.Assignee),
+		Team:       issue.Team.Name,
+		Labels:     labels,
+		CreatedAt:  issue.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:  issue.UpdatedAt.UTC().Format(time.RFC3339),
+		URL:        issue.URL,
+	}
+}
+
+func (r ExportRow) values() []string {
+	return []string{r.Identifier, r.Title, r.Status, r.Priority, r.Assignee, r.Team, strings.Join(r.Labels, ", "), r.CreatedAt, r.UpdatedAt, r.URL}
+}
+
+// exportGroups groups the issues the way the digest does, by status or by
+// assignee.
+func exportGroups(issues []Issue, by string) []ExportGroup {
+	var groups []ExportGroup
+	add := func(name string, issues []Issue) {
+		g := ExportGroup{Name: name, Count: len(issues), Issues: make([]ExportRow, len(issues))}
+		for i, issue := range issues {
+			g.Issues[i] = newExportRow(issue)
+		}
+		groups = append(groups, g)
+	}
+
+	if by == "assignee" {
+		for _, group := range groupByAssignee(issues) {
+			add(group.Name, group.Issues)
+		}
+	} else {
+		for _, group := range groupByStatus(issues) {
+			add(group.Name, group.Issues)
+		}
+	}
+	return groups
+}
+
+// exportFilter reads a report filter given directly in the query, as
+// comma-separated lists (?teams=ENG,OPS&priority=high).
+func exportFilter(q url.Values) (*IssueFilter, error) {
+	list := func(key string) []string {
+		var values []string
+		for _, v := range strings.Split(q.Get(key), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+	filter := &IssueFilter{
+		Teams:         list("teams"),
+		Labels:        list("labels"),
+		ExcludeLabels: list("exclude_labels"),
+		Projects:      list("projects"),
+		Assignees:     list("assignees"),
+		Priority:      q.Get("priority"),
+		StateTypes:    list("state_types"),
+	}
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// exportOptions returns the options of an export: ?job=name uses a scheduled
+// job's filter and workspace, otherwise the filter is read from the query.
+func exportOptions(w http.ResponseWriter, r *http.Request) (ReportOptions, bool) {
+	q := r.URL.Query()
+	name := q.Get("job")
+	if name == "" {
+		opts, ok := reportOptions(w, r)
+		if !ok {
+			return opts, false
+		}
+		filter, err := exportFilter(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return opts, false
+		}
+		opts.Filter = filter
+		return opts, true
+	}
+
+	job := jobByName(name)
+	if job == nil {
+		http.Error(w, fmt.Sprintf("Unknown job %q", name), http.StatusNotFound)
+		return ReportOptions{}, false
+	}
+	if key := q.Get("workspace"); key != "" && key != job.Workspace {
+		http.Error(w, fmt.Sprintf("Job %q does not run against workspace %q", name, key), http.StatusBadRequest)
+		return ReportOptions{}, false
+	}
+	if job.Workspace == "" && linearAPIKey == "" {
+		http.Error(w, "LINEAR_API_KEY not configured", http.StatusServiceUnavailable)
+		return ReportOptions{}, false
+	}
+	opts, err := jobOptions(withLogAttrs(context.WithoutCancel(r.Context()), "job", job.Name), job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return opts, false
+	}
+	return opts, true
+}
+
+// handleReportExport returns the open issues as CSV, JSON or Markdown:
+// /report/export?format=csv|json|md[&group=status|assignee][&<filter>].
+func handleReportExport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" && format != "md" {
+		http.Error(w, "format must be csv, json or md", http.StatusBadRequest)
+		return
+	}
+	by := q.Get("group")
+	if by == "" {
+		by = "status"
+	}
+	if by != "status" && by != "assignee" {
+		http.Error(w, "group must be status or assignee", http.StatusBadRequest)
+		return
+	}
+
+	opts, ok := exportOptions(w, r)
+	if !ok {
+		return
+	}
+
+	issues, err := fetchAllOpenIssues(opts)
+	if err != nil {
+		slog.ErrorContext(opts.context(), "Error exporting issues", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	groups := exportGroups(issues, by)
+	slog.InfoContext(opts.context(), "Export: Exporting issues", "count", len(issues), "format", format)
+
+	filename := "linear-issues-" + time.Now().UTC().Format(dateLayout) + "." + format
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = writeExportCSV(w, groups, by)
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		err = writeExportJSON(w, groups)
+	case "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		err = writeExportMarkdown(w, groups, by)
+	}
+	if err != nil {
+		slog.WarnContext(opts.context(), "Export: Could not write response", "error", err)
+	}
+}
+
+// writeExportCSV writes one row per issue, with the group in the first
+// column, flushing after each group.
+func writeExportCSV(w http.ResponseWriter, groups []ExportGroup, by string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"group_" + by}, exportColumns...)); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		for _, row := range g.Issues {
+			if err := cw.Write(append([]string{g.Name}, row.values()...)); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		flush(w)
+	}
+	return nil
+}
+
+// writeExportJSON writes {"groups": [...]} one group at a time.
+func writeExportJSON(w http.ResponseWriter, groups []ExportGroup) error {
+	if _, err := fmt.Fprint(w, `{"groups":[`); err != nil {
+		return err
+	}
+	for i, g := range groups {
+		if i > 0 {
+			if _, err := fmt.Fprint(w, ","); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(g)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		flush(w)
+	}
+	_, err := fmt.Fprintln(w, "]}")
+	return err
+}
+
+// writeExportMarkdown writes a heading and a table per group.
+func writeExportMarkdown(w http.ResponseWriter, groups []ExportGroup, by string) error {
+	total := 0
+	for _, g := range groups {
+		total += g.Count
+	}
+	if _, err := fmt.Fprintf(w, "# Open Linear issues by %s\n\n%d issues · exported %s\n", by, total, time.Now().UTC().Format("Mon Jan 2 15:04 MST")); err != nil {
+		return err
+	}
+
+	cell := func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+	}
+	for _, g := range groups {
+		var b strings.Builder
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n", cell(g.Name), g.Count)
+		b.WriteString("| Issue | Title | Status | Priority | Assignee | Team | Labels | Created | Updated |\n")
+		b.WriteString("|---|---|---|---|---|---|---|---|---|\n")
+		for _, row := range g.Issues {
+			fmt.Fprintf(&b, "| [%s](%s) | %s | %s | %s | %s | %s | %s | %s | %s |\n",
+				row.Identifier, row.URL, cell(row.Title), cell(row.Status), row.Priority, cell(row.Assignee),
+				cell(row.Team), cell(strings.Join(row.Labels, ", ")), row.CreatedAt[:10], row.UpdatedAt[:10])
+		}
+		if _, err := w.Write([]byte(b.String())); err != nil {
+			return err
+		}
+		flush(w)
+	}
+	return nil
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
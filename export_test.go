@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// exportTestGroups returns two status groups, one issue with a title that
+// needs escaping in every format.
+func exportTestGroups() []ExportGroup {
+	created := time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC)
+	issue := func(id, title, state, stateType string, labels ...string) Issue {
+		i := Issue{
+			Identifier:    id,
+			Title:         title,
+			URL:           "https://linear.app/acme/issue/" + id,
+			PriorityLabel: "High",
+			CreatedAt:     created,
+			UpdatedAt:     created.Add(48 * time.Hour),
+			State:         State{Name: state, Type: stateType},
+			Team:          Team{Name: "Engineering", Key: "ENG"},
+			Assignee:      &User{Name: "Jane Doe", DisplayName: "jane"},
+		}
+		for _, name := range labels {
+			i.Labels.Nodes = append(i.Labels.Nodes, Label{Name: name})
+		}
+		return i
+	}
+	return exportGroups([]Issue{
+		issue("ENG-1", `Fix "save" | crash, again`, "In Progress", "started", "bug", "ux"),
+		issue("ENG-2", "Line one\nline two", "Todo", "unstarted"),
+	}, "status")
+}
+
+func TestWriteExportCSV(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := writeExportCSV(w, exportTestGroups(), "status"); err != nil {
+		t.Fatalf("writeExportCSV: %v", err)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v\n%s", err, w.Body.String())
+	}
+	want := [][]string{
+		append([]string{"group_status"}, exportColumns...),
+		{"In Progress", "ENG-1", `Fix "save" | crash, again`, "In Progress", "High", "jane", "Engineering", "bug, ux",
+			"2024-06-01T09:30:00Z", "2024-06-03T09:30:00Z", "https://linear.app/acme/issue/ENG-1"},
+		{"Todo", "ENG-2", "Line one\nline two", "Todo", "High", "jane", "Engineering", "",
+			"2024-06-01T09:30:00Z", "2024-06-03T09:30:00Z", "https://linear.app/acme/issue/ENG-2"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records =\n  %q\nwant\n  %q", records, want)
+	}
+}
+
+func TestWriteExportJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := writeExportJSON(w, exportTestGroups()); err != nil {
+		t.Fatalf("writeExportJSON: %v", err)
+	}
+
+	var out struct {
+		Groups []ExportGroup `json:"groups"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, w.Body.String())
+	}
+	if !reflect.DeepEqual(out.Groups, exportTestGroups()) {
+		t.Errorf("groups = %+v, want %+v", out.Groups, exportTestGroups())
+	}
+}
+
+func TestWriteExportJSONEmpty(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := writeExportJSON(w, nil); err != nil {
+		t.Fatalf("writeExportJSON: %v", err)
+	}
+	if got := w.Body.String(); got != "{\"groups\":[]}\n" {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestWriteExportMarkdown(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := writeExportMarkdown(w, exportTestGroups(), "status"); err != nil {
+		t.Fatalf("writeExportMarkdown: %v", err)
+	}
+	out := w.Body.String()
+
+	for _, want := range []string{
+		"# Open Linear issues by status\n\n2 issues · exported ",
+		"\n## In Progress (1)\n\n| Issue | Title | Status |",
+		"| [ENG-1](https://linear.app/acme/issue/ENG-1) | Fix \"save\" \\| crash, again | In Progress | High | jane | Engineering | bug, ux | 2024-06-01 | 2024-06-03 |\n",
+		"\n## Todo (1)\n",
+		"| [ENG-2](https://linear.app/acme/issue/ENG-2) | Line one line two | Todo |",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+
+	// Every table row has the same number of cells as the header.
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "|") {
+			if cells := strings.Count(strings.ReplaceAll(line, `\|`, ""), "|"); cells != 10 {
+				t.Errorf("row has %d separators, want 10: %s", cells, line)
+			}
+		}
+	}
+}
+
+func TestExportGroupsByAssignee(t *testing.T) {
+	issues := []Issue{
+		{Identifier: "ENG-1", Assignee: &User{DisplayName: "jane"}},
+		{Identifier: "ENG-2"},
+		{Identifier: "ENG-3", Assignee: &User{DisplayName: "jane"}},
+	}
+	groups := exportGroups(issues, "assignee")
+	if len(groups) != 2 || groups[0].Name != "jane" || groups[0].Count != 2 || groups[1].Name != "Unassigned" {
+		t.Errorf("groups = %+v, want jane (2) then Unassigned", groups)
+	}
+}
+
+func TestExportFilter(t *testing.T) {
+	q, _ := url.ParseQuery("teams=ENG, OPS,&priority=high&exclude_labels=wontfix")
+	f, err := exportFilter(q)
+	if err != nil {
+		t.Fatalf("exportFilter: %v", err)
+	}
+	want := &IssueFilter{Teams: []string{"ENG", "OPS"}, ExcludeLabels: []string{"wontfix"}, Priority: "high"}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("filter = %+v, want %+v", f, want)
+	}
+
+	q, _ = url.ParseQuery("priority=critical")
+	if _, err := exportFilter(q); err == nil {
+		t.Error("exportFilter accepted an invalid priority")
+	}
+}
+
+// failingWriter fails its nth write, like a client that disconnects
+// mid-export, and counts the writes.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	n, writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes == w.n {
+		return 0, errors.New("connection reset by peer")
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestExportWritersReturnWriteErrors(t *testing.T) {
+	groups := exportTestGroups()
+	writers := map[string]func(w http.ResponseWriter) error{
+		"csv":      func(w http.ResponseWriter) error { return writeExportCSV(w, groups, "status") },
+		"json":     func(w http.ResponseWriter) error { return writeExportJSON(w, groups) },
+		"markdown": func(w http.ResponseWriter) error { return writeExportMarkdown(w, groups, "status") },
+	}
+	for name, write := range writers {
+		counter := &failingWriter{ResponseRecorder: httptest.NewRecorder()}
+		if err := write(counter); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// Fail each write in turn, including the separators between groups.
+		for n := 1; n <= counter.writes; n++ {
+			if err := write(&failingWriter{ResponseRecorder: httptest.NewRecorder(), n: n}); err == nil {
+				t.Errorf("%s: error of write %d of %d was ignored", name, n, counter.writes)
+			}
+		}
+	}
+}
+
+func TestHandleReportExportJobWorkspace(t *testing.T) {
+	prevConfig, prevClient, prevKey, prevRegistry := appConfig, linearClient, linearAPIKey, workspaces
+	t.Cleanup(func() {
+		appConfig, linearClient, linearAPIKey, workspaces = prevConfig, prevClient, prevKey, prevRegistry
+	})
+
+	global := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the export of a workspace job queried LINEAR_API_KEY")
+		http.Error(w, "wrong workspace", http.StatusInternalServerError)
+	}))
+	defer global.Close()
+	acme := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer acme-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		fmt.Fprint(w, `{"data":{"issues":{"nodes":[{"id":"1","identifier":"ACME-1","title":"Client issue",`+
+			`"state":{"name":"Todo","type":"unstarted"},"createdAt":"2024-06-01T00:00:00Z","updatedAt":"2024-06-01T00:00:00Z"}],`+
+			`"pageInfo":{"hasNextPage":false}}}}`)
+	}))
+	defer acme.Close()
+
+	linearAPIKey = "global-key"
+	linearClient = linear.NewClient(linearAPIKey, linear.WithBaseURL(global.URL), linear.WithMaxRetries(0))
+	ws := &Workspace{ID: "org-acme", Key: "acme", client: linear.NewClient("", linear.WithBaseURL(acme.URL), linear.WithMaxRetries(0),
+		linear.WithTokenSource(func(context.Context) (string, error) { return "acme-token", nil }))}
+	workspaces = &workspaceRegistry{byID: map[string]*Workspace{ws.ID: ws}, lastSync: time.Now()}
+
+	var err error
+	appConfig, err = loadTestConfig(t, `{
+		"workspaces": {"acme": {"destinations": {"default": {"webhook_url": "https://discord.test/acme"}}}},
+		"jobs": [
+			{"name": "acme-digest", "kind": "digest", "schedule": "0 9 * * *", "workspace": "acme"},
+			{"name": "digest", "kind": "digest", "schedule": "0 9 * * *"}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{"?job=acme-digest&format=json", http.StatusOK, "ACME-1"},
+		{"?job=acme-digest&workspace=acme", http.StatusOK, "ACME-1"},
+		{"?job=acme-digest&workspace=other", http.StatusBadRequest, "does not run against"},
+		{"?job=missing", http.StatusNotFound, "Unknown job"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handleReportExport(w, httptest.NewRequest(http.MethodGet, "/report/export"+tt.query, nil))
+		if w.Code != tt.wantCode || !strings.Contains(w.Body.String(), tt.wantBody) {
+			t.Errorf("%s: %d %s, want %d containing %q", tt.query, w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// ISSUE FILTERS
+// ============================================================================
+
+// IssueFilter narrows a report to part of the workspace. Empty fields match
+// everything; set fields must all match. Name comparisons ignore case.
+type IssueFilter struct {
+	// Teams holds team keys, e.g. ["ENG", "OPS"].
+	Teams []string `json:"teams,omitempty"`
+	// Labels keeps issues carrying at least one of these label names.
+	Labels []string `json:"labels,omitempty"`
+	// ExcludeLabels drops issues carrying any of these label names.
+	ExcludeLabels []string `json:"exclude_labels,omitempty"`
+	// Projects holds project names.
+	Projects []string `json:"projects,omitempty"`
+	// Assignees holds emails or display names; "unassigned" matches issues
+	// without an assignee.
+	Assignees []string `json:"assignees,omitempty"`
+	// Priority keeps issues of this priority or more urgent: "urgent",
+	// "high", "medium" or "low". Issues without a priority are dropped.
+	Priority string `json:"priority,omitempty"`
+	// StateTypes holds workflow state types, e.g. ["started", "unstarted"].
+	StateTypes []string `json:"state_types,omitempty"`
+}
+
+var filterPriorities = map[string]int{"urgent": 1, "high": 2, "medium": 3, "low": 4}
+
+var filterStateTypes = []string{"triage", "backlog", "unstarted", "started", "completed", "canceled"}
+
+func (f *IssueFilter) validate() error {
+	if f == nil {
+		return nil
+	}
+	if f.Priority != "" && filterPriorities[strings.ToLower(f.Priority)] == 0 {
+		return fmt.Errorf("invalid priority %q (want urgent, high, medium or low)", f.Priority)
+	}
+	for _, st := range f.StateTypes {
+		if !containsFold(filterStateTypes, st) {
+			return fmt.Errorf("invalid state type %q (want one of %s)", st, strings.Join(filterStateTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// graphQL compiles the filter into a Linear IssueFilter input object, or nil
+// when it matches everything.
+func (f *IssueFilter) graphQL() map[string]interface{} {
+	if f == nil {
+		return nil
+	}
+
+	var conditions []interface{}
+	add := func(c map[string]interface{}) { conditions = append(conditions, c) }
+
+	if len(f.Teams) > 0 {
+		add(object("team", anyOf("key", "eqIgnoreCase", f.Teams)))
+	}
+	if len(f.Labels) > 0 {
+		add(object("labels", object("some", anyOf("name", "eqIgnoreCase", f.Labels))))
+	}
+	if len(f.ExcludeLabels) > 0 {
+		add(object("labels", object("every", allOf("name", "neqIgnoreCase", f.ExcludeLabels))))
+	}
+	if len(f.Projects) > 0 {
+		add(object("project", anyOf("name", "eqIgnoreCase", f.Projects)))
+	}
+	if len(f.Assignees) > 0 {
+		var people []string
+		unassigned := false
+		for _, a := range f.Assignees {
+			if strings.EqualFold(a, "unassigned") {
+				unassigned = true
+			} else {
+				people = append(people, a)
+			}
+		}
+		var alternatives []interface{}
+		if unassigned {
+			alternatives = append(alternatives, object("assignee", object("null", true)))
+		}
+		if len(people) > 0 {
+			byEmailOrName := append(comparisons("email", "eqIgnoreCase", people), comparisons("displayName", "eqIgnoreCase", people)...)
+			alternatives = append(alternatives, object("assignee", object("or", byEmailOrName)))
+		}
+		add(object("or", alternatives))
+	}
+	if p := filterPriorities[strings.ToLower(f.Priority)]; p > 0 {
+		add(object("priority", map[string]interface{}{"gte": 1, "lte": p}))
+	}
+	if len(f.StateTypes) > 0 {
+		types := make([]string, len(f.StateTypes))
+		for i, st := range f.StateTypes {
+			types[i] = strings.ToLower(st)
+		}
+		add(object("state", object("type", object("in", types))))
+	}
+
+	switch len(conditions) {
+	case 0:
+		return nil
+	case 1:
+		return conditions[0].(map[string]interface{})
+	default:
+		return object("and", conditions)
+	}
+}
+
+// issueFilterVariable combines a query's own IssueFilter with a report filter.
+func issueFilterVariable(base map[string]interface{}, f *IssueFilter) map[string]interface{} {
+	compiled := f.graphQL()
+	if compiled == nil {
+		return base
+	}
+	return object("and", []interface{}{base, compiled})
+}
+
+func object(key string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{key: value}
+}
+
+// anyOf matches when field compares equal to any of the values.
+func anyOf(field, comparator string, values []string) map[string]interface{} {
+	return object("or", comparisons(field, comparator, values))
+}
+
+// allOf matches when field passes the comparison with every value.
+func allOf(field, comparator string, values []string) map[string]interface{} {
+	return object("and", comparisons(field, comparator, values))
+}
+
+func comparisons(field, comparator string, values []string) []interface{} {
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = object(field, object(comparator, v))
+	}
+	return list
+}
+
+// hasTeam reports whether the filter allows the team. Reports over projects
+// use it where Linear's IssueFilter does not apply.
+func (f *IssueFilter) hasTeam(key string) bool {
+	return f == nil || len(f.Teams) == 0 || containsFold(f.Teams, key)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
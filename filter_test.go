@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIssueFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *IssueFilter
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"empty", &IssueFilter{}, false},
+		{"priority", &IssueFilter{Priority: "High"}, false},
+		{"state types", &IssueFilter{StateTypes: []string{"started", "Unstarted", "triage"}}, false},
+		{"unknown priority", &IssueFilter{Priority: "critical"}, true},
+		{"numeric priority", &IssueFilter{Priority: "1"}, true},
+		{"unknown state type", &IssueFilter{StateTypes: []string{"started", "in progress"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIssueFilterGraphQL(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *IssueFilter
+		want   string // JSON, "null" for no filter
+	}{
+		{"nil", nil, `null`},
+		{"empty", &IssueFilter{}, `null`},
+		{
+			"teams",
+			&IssueFilter{Teams: []string{"ENG", "ops"}},
+			`{"team":{"or":[{"key":{"eqIgnoreCase":"ENG"}},{"key":{"eqIgnoreCase":"ops"}}]}}`,
+		},
+		{
+			"labels",
+			&IssueFilter{Labels: []string{"bug"}},
+			`{"labels":{"some":{"or":[{"name":{"eqIgnoreCase":"bug"}}]}}}`,
+		},
+		{
+			"exclude labels",
+			&IssueFilter{ExcludeLabels: []string{"wontfix", "duplicate"}},
+			`{"labels":{"every":{"and":[{"name":{"neqIgnoreCase":"wontfix"}},{"name":{"neqIgnoreCase":"duplicate"}}]}}}`,
+		},
+		{
+			"projects",
+			&IssueFilter{Projects: []string{"Launch"}},
+			`{"project":{"or":[{"name":{"eqIgnoreCase":"Launch"}}]}}`,
+		},
+		{
+			"assignees",
+			&IssueFilter{Assignees: []string{"jane@example.com"}},
+			`{"or":[{"assignee":{"or":[{"email":{"eqIgnoreCase":"jane@example.com"}},{"displayName":{"eqIgnoreCase":"jane@example.com"}}]}}]}`,
+		},
+		{
+			"unassigned",
+			&IssueFilter{Assignees: []string{"Unassigned"}},
+			`{"or":[{"assignee":{"null":true}}]}`,
+		},
+		{
+			"assignees or unassigned",
+			&IssueFilter{Assignees: []string{"bob", "unassigned"}},
+			`{"or":[{"assignee":{"null":true}},{"assignee":{"or":[{"email":{"eqIgnoreCase":"bob"}},{"displayName":{"eqIgnoreCase":"bob"}}]}}]}`,
+		},
+		{
+			"priority",
+			&IssueFilter{Priority: "HIGH"},
+			`{"priority":{"gte":1,"lte":2}}`,
+		},
+		{
+			"state types",
+			&IssueFilter{StateTypes: []string{"Started", "unstarted"}},
+			`{"state":{"type":{"in":["started","unstarted"]}}}`,
+		},
+		{
+			"combined",
+			&IssueFilter{Teams: []string{"ENG"}, Priority: "urgent"},
+			`{"and":[{"team":{"or":[{"key":{"eqIgnoreCase":"ENG"}}]}},{"priority":{"gte":1,"lte":1}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.filter.graphQL())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("graphQL() =\n  %s\nwant\n  %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssueFilterVariable(t *testing.T) {
+	base := object("state", object("type", object("nin", []string{"completed", "canceled"})))
+
+	got, _ := json.Marshal(issueFilterVariable(base, nil))
+	if want := `{"state":{"type":{"nin":["completed","canceled"]}}}`; string(got) != want {
+		t.Errorf("without filter = %s, want %s", got, want)
+	}
+
+	got, _ = json.Marshal(issueFilterVariable(base, &IssueFilter{Priority: "low"}))
+	if want := `{"and":[{"state":{"type":{"nin":["completed","canceled"]}}},{"priority":{"gte":1,"lte":4}}]}`; string(got) != want {
+		t.Errorf("with filter = %s, want %s", got, want)
+	}
+}
+
+func TestIssueFilterHasTeam(t *testing.T) {
+	var none *IssueFilter
+	if !none.hasTeam("ENG") || !(&IssueFilter{}).hasTeam("ENG") {
+		t.Error("an empty filter should allow every team")
+	}
+	f := &IssueFilter{Teams: []string{"eng"}}
+	if !f.hasTeam("ENG") || f.hasTeam("OPS") {
+		t.Error("hasTeam should match team keys ignoring case")
+	}
+}
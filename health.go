@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// ============================================================================
+// HEALTH CHECKS
+// ============================================================================
+
+const (
+	// Dependency checks are cached so probes don't spend the Linear rate
+	// limit; failures are retried sooner to notice recovery.
+	readyCacheOK   = time.Minute
+	readyCacheFail = 15 * time.Second
+	readyTimeout   = 5 * time.Second
+)
+
+// DependencyCheck is the cached result of one readiness check.
+type DependencyCheck struct {
+	Name      string    `json:"name"`
+	OK        bool      `json:"ok"`
+	Primary   bool      `json:"primary,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Latency   string    `json:"latency"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type readinessChecks struct {
+	mu      sync.Mutex
+	results map[string]*DependencyCheck
+}
+
+var readiness = &readinessChecks{results: map[string]*DependencyCheck{}}
+
+// check returns the cached result of name, running fn when it is stale.
+func (rc *readinessChecks) check(ctx context.Context, name string, fn func(ctx context.Context) error) DependencyCheck {
+	rc.mu.Lock()
+	cached := rc.results[name]
+	rc.mu.Unlock()
+	if cached != nil {
+		ttl := readyCacheOK
+		if !cached.OK {
+			ttl = readyCacheFail
+		}
+		if time.Since(cached.CheckedAt) < ttl {
+			return *cached
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	start := time.Now()
+	err := fn(ctx)
+	result := &DependencyCheck{
+		Name:      name,
+		OK:        err == nil,
+		Latency:   time.Since(start).Round(time.Millisecond).String(),
+		CheckedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		result.Error = redactString(err.Error())
+	}
+
+	rc.mu.Lock()
+	rc.results[name] = result
+	rc.mu.Unlock()
+	return *result
+}
+
+// checkLinear validates the credentials of a Linear client.
+func checkLinear(client *linear.Client) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		viewer, err := client.Viewer(ctx)
+		if err != nil {
+			return err
+		}
+		if viewer == nil || viewer.ID == "" {
+			return fmt.Errorf("token has no viewer")
+		}
+		return nil
+	}
+}
+
+// checkDiscordWebhook fetches a webhook, which fails once it is deleted or
+// its token is wrong.
+func checkDiscordWebhook(webhookURL string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, webhookURL, nil)
+		if err != nil {
+			return fmt.Errorf("invalid webhook URL: %w", redactURLError(err))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			// Leave out the URL, which holds the webhook token.
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return fmt.Errorf("failed to reach discord: %w", err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("discord returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// ReadyJob is a scheduled job as reported by /health/ready.
+type ReadyJob struct {
+	Name        string `json:"name"`
+	NextRun     string `json:"next_run,omitempty"`
+	LastRun     string `json:"last_run,omitempty"`
+	LastSuccess string `json:"last_success,omitempty"`
+	Failing     bool   `json:"failing,omitempty"`
+}
+
+// handleLive reports that the process is up and serving.
+func handleLive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleReady checks that Linear accepts our credentials and every Discord
+// webhook still exists, and reports the outbox backlog, the scheduler and the
+// config version. It responds 503 only when a primary dependency fails: the
+// LINEAR_API_KEY token or the default destination. A failing workspace or
+// other destination leaves the relay "degraded" but ready, and their names are
+// only shown to admins.
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	type namedCheck struct {
+		name    string
+		label   string // name shown without the admin token
+		primary bool
+		fn      func(ctx context.Context) error
+	}
+	var checks []namedCheck
+	if linearAPIKey != "" {
+		checks = append(checks, namedCheck{"linear", "linear", true, checkLinear(linearClient)})
+	}
+	for i, ws := range workspaces.list() {
+		checks = append(checks, namedCheck{"linear:" + ws.Key, fmt.Sprintf("linear:workspace-%d", i+1), false, checkLinear(ws.client)})
+	}
+	names := make([]string, 0, len(appConfig.Destinations))
+	for name := range appConfig.Destinations {
+		if name != defaultDestination {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	// The default destination goes first so that it is checked, and counted as
+	// primary, even when another destination shares its webhook.
+	names = append([]string{defaultDestination}, names...)
+	checked := map[string]bool{}
+	for i, name := range names {
+		webhookURL := appConfig.Destinations[name].WebhookURL
+		if checked[webhookURL] {
+			continue
+		}
+		checked[webhookURL] = true
+		label := fmt.Sprintf("discord:destination-%d", i)
+		if name == defaultDestination {
+			label = "discord:" + name
+		}
+		checks = append(checks, namedCheck{"discord:" + name, label, name == defaultDestination, checkDiscordWebhook(webhookURL)})
+	}
+
+	results := make([]DependencyCheck, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			results[i] = readiness.check(r.Context(), c.name, c.fn)
+		}(i, c)
+	}
+	wg.Wait()
+
+	admin := isAdmin(r)
+	status, code := "ok", http.StatusOK
+	for i, c := range checks {
+		results[i].Primary = c.primary
+		if !admin {
+			results[i].Name = c.label
+		}
+		switch {
+		case results[i].OK:
+		case c.primary:
+			status, code = "unavailable", http.StatusServiceUnavailable
+		case status == "ok":
+			status = "degraded"
+		}
+	}
+
+	var jobs []ReadyJob
+	for _, job := range scheduler.status() {
+		jobs = append(jobs, ReadyJob{
+			Name:        job.Name,
+			NextRun:     job.NextRun,
+			LastRun:     job.LastRun,
+			LastSuccess: job.LastSuccess,
+			Failing:     job.LastError != "",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Status        string            `json:"status"`
+		Checks        []DependencyCheck `json:"checks"`
+		Outbox        int64             `json:"outbox"`
+		Spooled       int64             `json:"spooled"`
+		Jobs          []ReadyJob        `json:"jobs,omitempty"`
+		ConfigVersion string            `json:"config_version"`
+	}{
+		Status:        status,
+		Checks:        results,
+		Outbox:        outboxDepth.Load(),
+		Spooled:       spooledMessages.Load(),
+		Jobs:          jobs,
+		ConfigVersion: appConfig.version,
+	})
+}
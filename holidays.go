@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// HOLIDAYS & SKIP DATES
+// ============================================================================
+
+// HolidayConfig lists the days on which no scheduled job runs, either inline
+// or from an iCalendar (.ics) file. Inline entries are "2006-01-02", a range
+// "2006-01-02..2006-01-05", or "01-02" for a date that repeats every year.
+type HolidayConfig struct {
+	Dates   []string `json:"dates,omitempty"`
+	ICSFile string   `json:"ics_file,omitempty"`
+}
+
+// SkipRules are per-job exceptions on top of the holiday calendar.
+type SkipRules struct {
+	Dates          []string `json:"dates,omitempty"`
+	IgnoreHolidays bool     `json:"ignore_holidays,omitempty"`
+}
+
+// Calendar is a set of named days. Days are compared by calendar date, so the
+// same calendar applies in every job's time zone.
+type Calendar struct {
+	dates  map[string]string // "2006-01-02" → name
+	annual map[string]string // "01-02" → name
+}
+
+const dateLayout = "2006-01-02"
+
+func newCalendar() *Calendar {
+	return &Calendar{dates: map[string]string{}, annual: map[string]string{}}
+}
+
+// loadCalendar builds the holiday calendar from the config section.
+func loadCalendar(cfg *HolidayConfig) (*Calendar, error) {
+	cal := newCalendar()
+	if cfg == nil {
+		return cal, nil
+	}
+
+	if err := cal.addDates(cfg.Dates, "holiday"); err != nil {
+		return nil, err
+	}
+
+	if cfg.ICSFile != "" {
+		if err := cal.addICS(cfg.ICSFile); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", cfg.ICSFile, err)
+		}
+	}
+
+	return cal, nil
+}
+
+func (c *Calendar) addDates(entries []string, name string) error {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		if from, to, ok := strings.Cut(entry, ".."); ok {
+			start, err := time.Parse(dateLayout, from)
+			if err != nil {
+				return fmt.Errorf("invalid date range %q: %w", entry, err)
+			}
+			end, err := time.Parse(dateLayout, to)
+			if err != nil {
+				return fmt.Errorf("invalid date range %q: %w", entry, err)
+			}
+			if end.Before(start) {
+				return fmt.Errorf("invalid date range %q: end before start", entry)
+			}
+			for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+				c.dates[d.Format(dateLayout)] = name
+			}
+			continue
+		}
+
+		if _, err := time.Parse("01-02", entry); err == nil {
+			c.annual[entry] = name
+			continue
+		}
+
+		if _, err := time.Parse(dateLayout, entry); err != nil {
+			return fmt.Errorf("invalid date %q (want YYYY-MM-DD, YYYY-MM-DD..YYYY-MM-DD or MM-DD)", entry)
+		}
+		c.dates[entry] = name
+	}
+	return nil
+}
+
+// addICS reads all-day and timed VEVENTs from an iCalendar file. Only
+// RRULE:FREQ=YEARLY is understood; other recurrences are added once.
+func (c *Calendar) addICS(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Unfold continuation lines (RFC 5545 §3.1) before parsing.
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var inEvent, yearly bool
+	var summary string
+	var start, end time.Time
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(key, ";")
+
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent, yearly = true, false
+				summary, start, end = "", time.Time{}, time.Time{}
+			}
+		case "SUMMARY":
+			summary = value
+		case "DTSTART":
+			start, _ = parseICSDate(value)
+		case "DTEND":
+			end, _ = parseICSDate(value)
+		case "RRULE":
+			yearly = strings.Contains(strings.ToUpper(value), "FREQ=YEARLY")
+		case "END":
+			if !inEvent || !strings.EqualFold(value, "VEVENT") {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				continue
+			}
+			if summary == "" {
+				summary = "holiday"
+			}
+			if yearly {
+				c.annual[start.Format("01-02")] = summary
+				continue
+			}
+			// DTEND is exclusive for all-day events.
+			last := start
+			if !end.IsZero() && end.After(start) {
+				last = end.AddDate(0, 0, -1)
+			}
+			for d := start; !d.After(last); d = d.AddDate(0, 0, 1) {
+				c.dates[d.Format(dateLayout)] = summary
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseICSDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return time.Parse("20060102", value[:8])
+}
+
+// lookup returns the name of the holiday on t's calendar date, if any.
+func (c *Calendar) lookup(t time.Time) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	if name, ok := c.dates[t.Format(dateLayout)]; ok {
+		return name, true
+	}
+	name, ok := c.annual[t.Format("01-02")]
+	return name, ok
+}
+
+func (c *Calendar) size() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.dates) + len(c.annual)
+}
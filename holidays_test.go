@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarAddDates(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    []string // dates that are holidays
+		notWant []string
+		wantErr bool
+	}{
+		{name: "single date", entries: []string{"2024-12-24"}, want: []string{"2024-12-24"}, notWant: []string{"2025-12-24"}},
+		{name: "range", entries: []string{"2024-12-24..2024-12-26"}, want: []string{"2024-12-24", "2024-12-25", "2024-12-26"}, notWant: []string{"2024-12-27"}},
+		{name: "range across years", entries: []string{"2024-12-31..2025-01-01"}, want: []string{"2024-12-31", "2025-01-01"}},
+		{name: "annual", entries: []string{"12-25"}, want: []string{"2024-12-25", "2031-12-25"}, notWant: []string{"2024-12-26"}},
+		{name: "whitespace", entries: []string{" 2024-05-01 "}, want: []string{"2024-05-01"}},
+		{name: "end before start", entries: []string{"2024-12-26..2024-12-24"}, wantErr: true},
+		{name: "invalid range", entries: []string{"2024-12-24..tomorrow"}, wantErr: true},
+		{name: "invalid date", entries: []string{"24.12.2024"}, wantErr: true},
+		{name: "invalid day", entries: []string{"2024-02-30"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal := newCalendar()
+			err := cal.addDates(tt.entries, "holiday")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("addDates error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assertHolidays(t, cal, tt.want, tt.notWant)
+		})
+	}
+}
+
+func TestCalendarAddICS(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20241225",
+		"DTEND;VALUE=DATE:20241227",
+		"SUMMARY:Christmas",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20240101",
+		"RRULE:FREQ=YEARLY",
+		"SUMMARY:New Year's Day",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20240501",
+		"SUMMARY:Labour",
+		"  Day",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20240603T090000Z",
+		"DTEND:20240603T170000Z",
+		"SUMMARY:Offsite",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20240815",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:No start",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"DTSTART;VALUE=DATE:20240901",
+		"SUMMARY:Not an event",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+	path := filepath.Join(t.TempDir(), "holidays.ics")
+	if err := os.WriteFile(path, []byte(ics), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cal := newCalendar()
+	if err := cal.addICS(path); err != nil {
+		t.Fatalf("addICS: %v", err)
+	}
+
+	names := map[string]string{
+		"2024-12-25": "Christmas",
+		"2024-12-26": "Christmas",
+		"2024-01-01": "New Year's Day",
+		"2030-01-01": "New Year's Day",
+		"2024-05-01": "Labour Day", // folded line
+		"2024-06-03": "Offsite",
+		"2024-08-15": "holiday", // no SUMMARY
+	}
+	for date, want := range names {
+		d, _ := time.Parse(dateLayout, date)
+		if got, ok := cal.lookup(d); !ok || got != want {
+			t.Errorf("lookup(%s) = %q, %v; want %q", date, got, ok, want)
+		}
+	}
+	// DTEND is exclusive, and VTODOs are not holidays.
+	assertHolidays(t, cal, nil, []string{"2024-12-27", "2024-06-04", "2024-09-01"})
+	if got, want := cal.size(), 6; got != want {
+		t.Errorf("size = %d, want %d", got, want)
+	}
+}
+
+func TestCalendarAddICSMissingFile(t *testing.T) {
+	if err := newCalendar().addICS(filepath.Join(t.TempDir(), "missing.ics")); err == nil {
+		t.Error("addICS succeeded on a missing file")
+	}
+}
+
+func TestParseICSDate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"20241225", "2024-12-25", false},
+		{"20241225T090000Z", "2024-12-25", false},
+		{"20241225T090000", "2024-12-25", false},
+		{"2024122", "", true},
+		{"2024-12-25", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseICSDate(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseICSDate(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.Format(dateLayout) != tt.want {
+			t.Errorf("parseICSDate(%q) = %s, want %s", tt.value, got.Format(dateLayout), tt.want)
+		}
+	}
+}
+
+func TestCalendarLookupNil(t *testing.T) {
+	var cal *Calendar
+	if _, ok := cal.lookup(time.Now()); ok {
+		t.Error("nil calendar has a holiday")
+	}
+}
+
+func assertHolidays(t *testing.T, cal *Calendar, want, notWant []string) {
+	t.Helper()
+	for _, date := range want {
+		d, _ := time.Parse(dateLayout, date)
+		if _, ok := cal.lookup(d); !ok {
+			t.Errorf("%s is not a holiday", date)
+		}
+	}
+	for _, date := range notWant {
+		d, _ := time.Parse(dateLayout, date)
+		if _, ok := cal.lookup(d); ok {
+			t.Errorf("%s is a holiday", date)
+		}
+	}
+}
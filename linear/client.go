@@ -0,0 +1,223 @@
+// Package linear is a small client for the Linear GraphQL API.
+package linear
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	DefaultBaseURL = "https://api.linear.app/graphql"
+	DefaultTimeout = 30 * time.Second
+)
+
+// Client calls the Linear API with one API key. It is safe for concurrent
+// use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	maxRetries int
+	// tokenSource, if set, replaces apiKey with an OAuth access token.
+	tokenSource func(ctx context.Context) (string, error)
+	observer    func(RequestInfo)
+	tracer      func(ctx context.Context, operation string, attempt int) (context.Context, func(error))
+
+	mu        sync.Mutex
+	rateLimit RateLimit
+	queryCost map[string]int // last X-Complexity per query
+}
+
+type Option func(*Client)
+
+// WithBaseURL points the client at another GraphQL endpoint, e.g. a proxy.
+func WithBaseURL(url string) Option {
+	return func(c *Client) { c.baseURL = url }
+}
+
+// WithTimeout sets the timeout of each request. Defaults to 30s.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.httpClient.Timeout = d }
+}
+
+// WithHTTPClient replaces the HTTP client; its timeout is used as is.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithTokenSource authenticates with OAuth access tokens instead of the API
+// key. The source is called before every request, so it can refresh the token.
+func WithTokenSource(source func(ctx context.Context) (string, error)) Option {
+	return func(c *Client) { c.tokenSource = source }
+}
+
+// RequestInfo describes one finished request, retries counted separately.
+type RequestInfo struct {
+	// Operation is the query's first top-level field, e.g. "issues".
+	Operation string
+	Duration  time.Duration
+	Err       error
+}
+
+// WithObserver calls fn after every request, e.g. to record metrics.
+func WithObserver(fn func(RequestInfo)) Option {
+	return func(c *Client) { c.observer = fn }
+}
+
+// WithTracer wraps every request attempt, e.g. in a trace span: start is
+// called before it and the function it returns after it, with the result.
+// The returned context is used for the request.
+func WithTracer(start func(ctx context.Context, operation string, attempt int) (context.Context, func(error))) Option {
+	return func(c *Client) { c.tracer = start }
+}
+
+func NewClient(apiKey string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		maxRetries: DefaultMaxRetries,
+		queryCost:  make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type request struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors Errors          `json:"errors,omitempty"`
+}
+
+// Do runs a query and decodes its "data" into out, if given. GraphQL errors
+// are returned as Errors, other failed responses as *HTTPError.
+//
+// Requests are throttled once the rate limit budget runs low. Rate limited
+// responses are retried when the exhausted budget resets, or fail with
+// ErrBudgetExhausted if that is over a minute away; 5xx responses are
+// retried with jittered backoff.
+func (c *Client) Do(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	body, err := json.Marshal(request{Query: query, Variables: variables})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	operation := operationName(query)
+	for attempt := 0; ; attempt++ {
+		if err := c.throttle(ctx, query); err != nil {
+			return err
+		}
+		attemptCtx, end := ctx, func(error) {}
+		if c.tracer != nil {
+			attemptCtx, end = c.tracer(ctx, operation, attempt)
+		}
+		start := time.Now()
+		err := c.do(attemptCtx, query, body, out)
+		end(err)
+		if c.observer != nil {
+			c.observer(RequestInfo{Operation: operation, Duration: time.Since(start), Err: err})
+		}
+		if err == nil || attempt >= c.maxRetries || !retryable(err) {
+			return err
+		}
+
+		delay := retryDelay(attempt)
+		if rateLimited(err) {
+			if wait, ok := c.resetDelay(time.Now()); ok {
+				if wait > rateLimitMaxWait {
+					return fmt.Errorf("%w until %s: %w", ErrBudgetExhausted, time.Now().Add(wait).UTC().Format(time.RFC3339), err)
+				}
+				delay = wait
+			}
+		}
+		slog.WarnContext(ctx, "Linear request failed, retrying", "error", err, "delay", delay.Round(time.Millisecond).String())
+		c.mu.Lock()
+		c.rateLimit.Retries++
+		c.rateLimit.WaitSeconds += delay.Seconds()
+		c.mu.Unlock()
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, query string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.tokenSource != nil {
+		token, err := c.tokenSource(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get access token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.Header.Set("Authorization", c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	c.recordRateLimit(query, resp.Header)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Linear reports most failures, rate limiting included, as GraphQL
+	// errors with a 400 status, so the body is parsed first.
+	var graphResp response
+	parseErr := json.Unmarshal(respBody, &graphResp)
+	if parseErr == nil && len(graphResp.Errors) > 0 {
+		return graphResp.Errors
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	if parseErr != nil {
+		return fmt.Errorf("failed to parse response: %w", parseErr)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(graphResp.Data, out); err != nil {
+			return fmt.Errorf("failed to parse response data: %w", err)
+		}
+	}
+	return nil
+}
+
+// operationName returns the first field selected by a query.
+func operationName(query string) string {
+	i := strings.Index(query, "{")
+	if i < 0 {
+		return ""
+	}
+	rest := strings.TrimLeft(query[i+1:], " \t\r\n")
+	end := strings.IndexFunc(rest, func(r rune) bool {
+		return !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	if end < 0 {
+		return rest
+	}
+	return rest[:end]
+}
@@ -0,0 +1,214 @@
+package linear
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client for a test server running handler, without
+// retries.
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewClient("test-key", append([]Option{WithBaseURL(srv.URL), WithMaxRetries(0)}, opts...)...)
+}
+
+// decodeRequest reads the GraphQL request of r.
+func decodeRequest(t *testing.T, r *http.Request) request {
+	t.Helper()
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		t.Errorf("failed to decode request: %v", err)
+	}
+	return req
+}
+
+func TestDoDecodesData(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "test-key" {
+			t.Errorf("Authorization = %q, want the API key", got)
+		}
+		req := decodeRequest(t, r)
+		if req.Variables["id"] != "abc" {
+			t.Errorf("variables = %v, want id abc", req.Variables)
+		}
+		w.Write([]byte(`{"data":{"viewer":{"id":"u1","name":"Jane"}}}`))
+	})
+
+	var out struct {
+		Viewer User `json:"viewer"`
+	}
+	if err := c.Do(context.Background(), `query { viewer { id name } }`, map[string]interface{}{"id": "abc"}, &out); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if out.Viewer.ID != "u1" || out.Viewer.Name != "Jane" {
+		t.Errorf("viewer = %+v", out.Viewer)
+	}
+}
+
+func TestDoTokenSource(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer oauth-token" {
+			t.Errorf("Authorization = %q, want the OAuth token", got)
+		}
+		w.Write([]byte(`{"data":{}}`))
+	}, WithTokenSource(func(context.Context) (string, error) { return "oauth-token", nil }))
+
+	if err := c.Do(context.Background(), `query { viewer { id } }`, nil, nil); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+}
+
+func TestDoErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantCode   string // extensions.code of Errors, if any
+		wantStatus int    // status of HTTPError, if any
+	}{
+		{
+			name:     "400 with GraphQL errors",
+			status:   http.StatusBadRequest,
+			body:     `{"errors":[{"message":"Rate limit exceeded","extensions":{"code":"RATELIMITED"}}]}`,
+			wantCode: CodeRateLimited,
+		},
+		{
+			name:     "200 with GraphQL errors",
+			status:   http.StatusOK,
+			body:     `{"data":null,"errors":[{"message":"Entity not found","extensions":{"code":"INVALID_INPUT"}}]}`,
+			wantCode: CodeInvalidInput,
+		},
+		{
+			name:       "400 without GraphQL errors",
+			status:     http.StatusBadRequest,
+			body:       `Bad Request`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "401 with empty errors",
+			status:     http.StatusUnauthorized,
+			body:       `{"errors":[]}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "502 HTML page",
+			status:     http.StatusBadGateway,
+			body:       `<html>Bad Gateway</html>`,
+			wantStatus: http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			err := c.Do(context.Background(), `query { viewer { id } }`, nil, nil)
+			if err == nil {
+				t.Fatal("Do succeeded, want an error")
+			}
+
+			var errs Errors
+			if got := errors.As(err, &errs); got != (tt.wantCode != "") {
+				t.Fatalf("error %v: is Errors = %v, want %v", err, got, tt.wantCode != "")
+			}
+			if tt.wantCode != "" && errs.Code() != tt.wantCode {
+				t.Errorf("Code() = %q, want %q", errs.Code(), tt.wantCode)
+			}
+
+			var httpErr *HTTPError
+			if got := errors.As(err, &httpErr); got != (tt.wantStatus != 0) {
+				t.Fatalf("error %v: is HTTPError = %v, want %v", err, got, tt.wantStatus != 0)
+			}
+			if tt.wantStatus != 0 && httpErr.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", httpErr.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDoRetriesServerErrors(t *testing.T) {
+	calls := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data":{}}`))
+	}, WithMaxRetries(1))
+
+	if err := c.Do(context.Background(), `query { viewer { id } }`, nil, nil); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+	if rl := c.RateLimit(); rl.Retries != 1 {
+		t.Errorf("Retries = %d, want 1", rl.Retries)
+	}
+}
+
+func TestDoContextCanceled(t *testing.T) {
+	// The handler holds the request until the test ends, as the server may
+	// not notice the client hanging up.
+	release := make(chan struct{})
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	t.Cleanup(func() { close(release) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := c.Do(ctx, `query { viewer { id } }`, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Do = %v, want context.Canceled", err)
+	}
+}
+
+func TestDoContextCanceledDuringRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusInternalServerError)
+	}, WithMaxRetries(3))
+
+	err := c.Do(ctx, `query { viewer { id } }`, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Do = %v, want context.Canceled", err)
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`query { viewer { id } }`, "viewer"},
+		{`query($cursor: String) {
+			issues(first: 50, after: $cursor) { nodes { id } }
+		}`, "issues"},
+		{`{ workflowStates { nodes { id } } }`, "workflowStates"},
+		{`mutation { issueCreate(input: {}) { success } }`, "issueCreate"},
+		{`no selection`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := operationName(tt.query); got != tt.want {
+			t.Errorf("operationName(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package linear
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error codes Linear sets in extensions.code.
+const (
+	CodeRateLimited    = "RATELIMITED"
+	CodeAuthentication = "AUTHENTICATION_ERROR"
+	CodeForbidden      = "FORBIDDEN"
+	CodeInvalidInput   = "INVALID_INPUT"
+)
+
+// Error is one entry of a GraphQL response's "errors".
+type Error struct {
+	Message    string          `json:"message"`
+	Path       []interface{}   `json:"path,omitempty"`
+	Extensions ErrorExtensions `json:"extensions"`
+}
+
+type ErrorExtensions struct {
+	Code                   string `json:"code"`
+	Type                   string `json:"type"`
+	UserPresentableMessage string `json:"userPresentableMessage"`
+}
+
+func (e *Error) Error() string {
+	if e.Extensions.Code != "" {
+		return fmt.Sprintf("%s (%s)", e.Message, e.Extensions.Code)
+	}
+	return e.Message
+}
+
+// Errors are the GraphQL errors of a response.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "GraphQL errors: " + strings.Join(messages, "; ")
+}
+
+// Code returns the first error code in the response, or "".
+func (e Errors) Code() string {
+	for _, err := range e {
+		if err.Extensions.Code != "" {
+			return err.Extensions.Code
+		}
+	}
+	return ""
+}
+
+// HTTPError is a failed response without GraphQL errors.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("linear API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// ErrorCode returns the extensions.code of a GraphQL error anywhere in err's
+// chain, or "".
+func ErrorCode(err error) string {
+	var errs Errors
+	if errors.As(err, &errs) {
+		return errs.Code()
+	}
+	return ""
+}
+
+// IsRateLimited reports whether err is a RATELIMITED error, or the budget
+// ran out before a request was sent.
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrBudgetExhausted) || ErrorCode(err) == CodeRateLimited
+}
+
+// IsAuthentication reports whether the API key was rejected.
+func IsAuthentication(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == 401 {
+		return true
+	}
+	return ErrorCode(err) == CodeAuthentication
+}
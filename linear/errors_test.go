@@ -0,0 +1,71 @@
+package linear
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestErrorsCode(t *testing.T) {
+	tests := []struct {
+		name string
+		errs Errors
+		want string
+	}{
+		{"none", nil, ""},
+		{"first code", Errors{{Message: "a", Extensions: ErrorExtensions{Code: CodeForbidden}}, {Message: "b", Extensions: ErrorExtensions{Code: CodeInvalidInput}}}, CodeForbidden},
+		{"skips errors without code", Errors{{Message: "a"}, {Message: "b", Extensions: ErrorExtensions{Code: CodeRateLimited}}}, CodeRateLimited},
+		{"no code", Errors{{Message: "a"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.errs.Code(); got != tt.want {
+				t.Errorf("Code() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCodeWrapped(t *testing.T) {
+	err := fmt.Errorf("failed to fetch issues: %w", Errors{{Message: "slow down", Extensions: ErrorExtensions{Code: CodeRateLimited}}})
+	if got := ErrorCode(err); got != CodeRateLimited {
+		t.Errorf("ErrorCode = %q, want %q", got, CodeRateLimited)
+	}
+	if !IsRateLimited(err) {
+		t.Error("IsRateLimited = false, want true")
+	}
+	if got := ErrorCode(fmt.Errorf("plain")); got != "" {
+		t.Errorf("ErrorCode of a plain error = %q, want \"\"", got)
+	}
+}
+
+func TestIsAuthentication(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"401", &HTTPError{StatusCode: 401}, true},
+		{"wrapped 401", fmt.Errorf("viewer: %w", &HTTPError{StatusCode: 401}), true},
+		{"authentication error", Errors{{Message: "Authentication required", Extensions: ErrorExtensions{Code: CodeAuthentication}}}, true},
+		{"403", &HTTPError{StatusCode: 403}, false},
+		{"forbidden", Errors{{Message: "Forbidden", Extensions: ErrorExtensions{Code: CodeForbidden}}}, false},
+		{"other", fmt.Errorf("connection refused"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAuthentication(tt.err); got != tt.want {
+				t.Errorf("IsAuthentication(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	errs := Errors{{Message: "a", Extensions: ErrorExtensions{Code: CodeForbidden}}, {Message: "b"}}
+	if got, want := errs.Error(), "GraphQL errors: a (FORBIDDEN); b"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package linear
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAuthorizeURL = "https://linear.app/oauth/authorize"
+	DefaultTokenURL     = "https://api.linear.app/oauth/token"
+)
+
+// OAuthConfig is a Linear OAuth2 application.
+type OAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes defaults to "read".
+	Scopes []string
+	// Actor is "user" (the default) or "app", which installs the application
+	// for the whole workspace rather than as the authorizing user.
+	Actor string
+
+	// AuthorizeURL and TokenURL default to Linear's endpoints.
+	AuthorizeURL string
+	TokenURL     string
+	HTTPClient   *http.Client
+}
+
+// Token is an OAuth2 token response. ExpiresAt is zero for tokens that do
+// not expire.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Scope        string    `json:"scope"`
+	ExpiresIn    int64     `json:"expires_in,omitempty"`
+	ExpiresAt    time.Time `json:"-"`
+}
+
+// AuthCodeURL returns the URL that asks a workspace admin to install the
+// application. state is echoed back to the redirect URL.
+func (o *OAuthConfig) AuthCodeURL(state string) string {
+	scopes := o.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"read"}
+	}
+	q := url.Values{
+		"client_id":     {o.ClientID},
+		"redirect_uri":  {o.RedirectURL},
+		"response_type": {"code"},
+		"scope":         {strings.Join(scopes, ",")},
+		"state":         {state},
+		"prompt":        {"consent"},
+	}
+	if o.Actor != "" {
+		q.Set("actor", o.Actor)
+	}
+
+	base := o.AuthorizeURL
+	if base == "" {
+		base = DefaultAuthorizeURL
+	}
+	return base + "?" + q.Encode()
+}
+
+// Exchange trades the authorization code from the redirect for a token.
+func (o *OAuthConfig) Exchange(ctx context.Context, code string) (*Token, error) {
+	return o.token(ctx, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {o.RedirectURL},
+	})
+}
+
+// Refresh returns a new token for a refresh token.
+func (o *OAuthConfig) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	return o.token(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+func (o *OAuthConfig) token(ctx context.Context, form url.Values) (*Token, error) {
+	form.Set("client_id", o.ClientID)
+	form.Set("client_secret", o.ClientSecret)
+
+	tokenURL := o.TokenURL
+	if tokenURL == "" {
+		tokenURL = DefaultTokenURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	hc := o.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var token Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return &token, nil
+}
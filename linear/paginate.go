@@ -0,0 +1,69 @@
+package linear
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type PageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// Connection is one page of a Linear connection field.
+type Connection[T any] struct {
+	Nodes    []T      `json:"nodes"`
+	PageInfo PageInfo `json:"pageInfo"`
+}
+
+// Paginate runs a query that takes a $cursor variable and selects
+// nodes and pageInfo { hasNextPage endCursor } of field, page by page until
+// the last one, and returns all nodes. field is a top-level field, or a
+// dotted path to a connection nested in one, e.g. "cycle.issues".
+func Paginate[T any](ctx context.Context, c *Client, query string, variables map[string]interface{}, field string) ([]T, error) {
+	vars := make(map[string]interface{}, len(variables)+1)
+	for k, v := range variables {
+		vars[k] = v
+	}
+
+	var all []T
+	for {
+		var data json.RawMessage
+		if err := c.Do(ctx, query, vars, &data); err != nil {
+			return nil, err
+		}
+
+		var page Connection[T]
+		raw, err := lookup(data, field)
+		if err == nil {
+			err = json.Unmarshal(raw, &page)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", field, err)
+		}
+		all = append(all, page.Nodes...)
+
+		if !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == "" {
+			return all, nil
+		}
+		vars["cursor"] = page.PageInfo.EndCursor
+	}
+}
+
+// lookup returns the value at a dotted path of object fields.
+func lookup(data json.RawMessage, path string) (json.RawMessage, error) {
+	for _, name := range strings.Split(path, ".") {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			return nil, err
+		}
+		value, ok := object[name]
+		if !ok {
+			return nil, fmt.Errorf("missing field %q", name)
+		}
+		data = value
+	}
+	return data, nil
+}
@@ -0,0 +1,89 @@
+package linear
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"data":{"teams":{"nodes":[{"id":"t1"},{"id":"t2"}],"pageInfo":{"hasNextPage":true,"endCursor":"c1"}}}}`,
+		"c1": `{"data":{"teams":{"nodes":[{"id":"t3"}],"pageInfo":{"hasNextPage":true,"endCursor":"c2"}}}}`,
+		"c2": `{"data":{"teams":{"nodes":[{"id":"t4"}],"pageInfo":{"hasNextPage":false,"endCursor":"c3"}}}}`,
+	}
+	var cursors []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		req := decodeRequest(t, r)
+		if req.Variables["filter"] != "keep" {
+			t.Errorf("filter variable = %v, want it on every page", req.Variables["filter"])
+		}
+		cursor, _ := req.Variables["cursor"].(string)
+		cursors = append(cursors, cursor)
+		w.Write([]byte(pages[cursor]))
+	})
+
+	teams, err := Paginate[Team](context.Background(), c, `query { teams { nodes { id } } }`, map[string]interface{}{"filter": "keep"}, "teams")
+	if err != nil {
+		t.Fatalf("Paginate: %v", err)
+	}
+
+	var ids []string
+	for _, team := range teams {
+		ids = append(ids, team.ID)
+	}
+	if want := []string{"t1", "t2", "t3", "t4"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+	if want := []string{"", "c1", "c2"}; !reflect.DeepEqual(cursors, want) {
+		t.Errorf("cursors = %v, want %v", cursors, want)
+	}
+}
+
+func TestPaginateNestedField(t *testing.T) {
+	calls := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		hasNext := calls == 1
+		fmt.Fprintf(w, `{"data":{"cycle":{"uncompletedIssuesUponClose":{"nodes":[{"id":"i%d"}],"pageInfo":{"hasNextPage":%t,"endCursor":"c%d"}}}}}`,
+			calls, hasNext, calls)
+	})
+
+	issues, err := Paginate[Issue](context.Background(), c, `query { cycle { id } }`, nil, "cycle.uncompletedIssuesUponClose")
+	if err != nil {
+		t.Fatalf("Paginate: %v", err)
+	}
+	if len(issues) != 2 || issues[0].ID != "i1" || issues[1].ID != "i2" {
+		t.Errorf("issues = %+v, want i1 and i2", issues)
+	}
+}
+
+func TestPaginateMissingField(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"cycle":null}}`))
+	})
+
+	if _, err := Paginate[Issue](context.Background(), c, `query { cycle { id } }`, nil, "cycle.issues"); err == nil {
+		t.Error("Paginate succeeded on a null parent, want an error")
+	}
+	if _, err := Paginate[Issue](context.Background(), c, `query { cycle { id } }`, nil, "issues"); err == nil {
+		t.Error("Paginate succeeded on a missing field, want an error")
+	}
+}
+
+func TestPaginateStopsWithoutCursor(t *testing.T) {
+	calls := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"data":{"teams":{"nodes":[{"id":"t1"}],"pageInfo":{"hasNextPage":true,"endCursor":""}}}}`))
+	})
+
+	if _, err := Paginate[Team](context.Background(), c, `query { teams { nodes { id } } }`, nil, "teams"); err != nil {
+		t.Fatalf("Paginate: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
@@ -0,0 +1,443 @@
+package linear
+
+import (
+	"context"
+	"time"
+)
+
+// IssueFields is the selection of every query that returns Issue.
+const IssueFields = `
+	id
+	identifier
+	title
+	priority
+	priorityLabel
+	url
+	createdAt
+	updatedAt
+	startedAt
+	completedAt
+	canceledAt
+	dueDate
+	slaBreachesAt
+	estimate
+	state {
+		id
+		name
+		color
+		type
+	}
+	assignee {
+		id
+		name
+		displayName
+		email
+	}
+	team {
+		id
+		name
+		key
+	}
+	labels {
+		nodes {
+			id
+			name
+			color
+		}
+	}
+`
+
+const userFields = `
+	id
+	name
+	displayName
+	email
+`
+
+const teamFields = `
+	id
+	name
+	key
+`
+
+// cycleIssuesPageSize keeps a page of cycle issues, each with its labels,
+// well below Linear's query complexity limit.
+const cycleIssuesPageSize = 50
+
+// IssuesOptions narrows Issues. A nil Filter returns every issue.
+type IssuesOptions struct {
+	// Filter is a Linear IssueFilter input object.
+	Filter          map[string]interface{}
+	IncludeArchived bool
+	// OrderBy is "createdAt" or "updatedAt"; Linear's default is createdAt.
+	OrderBy string
+}
+
+// Organization returns the workspace the client's credentials belong to.
+func (c *Client) Organization(ctx context.Context) (*Organization, error) {
+	query := `
+		query {
+			organization {
+				id
+				name
+				urlKey
+			}
+		}
+	`
+
+	var data struct {
+		Organization *Organization `json:"organization"`
+	}
+	if err := c.Do(ctx, query, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Organization, nil
+}
+
+// Viewer returns the user the client's credentials belong to. It is the
+// cheapest query that validates them.
+func (c *Client) Viewer(ctx context.Context) (*User, error) {
+	query := `
+		query {
+			viewer {
+				id
+				name
+			}
+		}
+	`
+
+	var data struct {
+		Viewer *User `json:"viewer"`
+	}
+	if err := c.Do(ctx, query, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Viewer, nil
+}
+
+// Issues returns all issues matching the options.
+func (c *Client) Issues(ctx context.Context, opts IssuesOptions) ([]Issue, error) {
+	query := `
+		query($cursor: String, $filter: IssueFilter, $includeArchived: Boolean, $orderBy: PaginationOrderBy) {
+			issues(
+				filter: $filter
+				first: 100
+				after: $cursor
+				includeArchived: $includeArchived
+				orderBy: $orderBy
+			) {
+				nodes {` + IssueFields + `}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+
+	variables := map[string]interface{}{"filter": opts.Filter, "includeArchived": opts.IncludeArchived}
+	if opts.OrderBy != "" {
+		variables["orderBy"] = opts.OrderBy
+	}
+	return Paginate[Issue](ctx, c, query, variables, "issues")
+}
+
+// IssueByIdentifier returns one issue by identifier ("ENG-123") or ID.
+func (c *Client) IssueByIdentifier(ctx context.Context, identifier string) (*Issue, error) {
+	query := `
+		query($id: String!) {
+			issue(id: $id) {` + IssueFields + `}
+		}
+	`
+
+	var data struct {
+		Issue *Issue `json:"issue"`
+	}
+	if err := c.Do(ctx, query, map[string]interface{}{"id": identifier}, &data); err != nil {
+		return nil, err
+	}
+	return data.Issue, nil
+}
+
+// Cycles returns the cycles matching a CycleFilter (which may be nil),
+// without their issues; see CycleIssues and UncompletedIssuesUponClose.
+func (c *Client) Cycles(ctx context.Context, filter map[string]interface{}) ([]Cycle, error) {
+	query := `
+		query($cursor: String, $filter: CycleFilter) {
+			cycles(filter: $filter, first: 50, after: $cursor) {
+				nodes {
+					id
+					number
+					name
+					startsAt
+					endsAt
+					completedAt
+					scopeHistory
+					completedScopeHistory
+					issueCountHistory
+					completedIssueCountHistory
+					team {` + teamFields + `}
+				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+
+	return Paginate[Cycle](ctx, c, query, map[string]interface{}{"filter": filter}, "cycles")
+}
+
+// CycleIssues returns the issues currently in a cycle that match issueFilter
+// (which may be nil), with the time each was added to it.
+func (c *Client) CycleIssues(ctx context.Context, cycleID string, issueFilter map[string]interface{}) ([]Issue, error) {
+	query := `
+		query($cursor: String, $filter: IssueFilter, $first: Int) {
+			issues(filter: $filter, first: $first, after: $cursor, includeArchived: true) {
+				nodes {` + IssueFields + `
+					addedToCycleAt
+				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+
+	filter := map[string]interface{}{"cycle": map[string]interface{}{"id": map[string]interface{}{"eq": cycleID}}}
+	if issueFilter != nil {
+		filter = map[string]interface{}{"and": []interface{}{filter, issueFilter}}
+	}
+	variables := map[string]interface{}{"filter": filter, "first": cycleIssuesPageSize}
+	return Paginate[Issue](ctx, c, query, variables, "issues")
+}
+
+// UncompletedIssuesUponClose returns the issues that were not done when a
+// cycle closed and match issueFilter (which may be nil). Linear moves them to
+// the next cycle, so they are no longer among CycleIssues.
+func (c *Client) UncompletedIssuesUponClose(ctx context.Context, cycleID string, issueFilter map[string]interface{}) ([]Issue, error) {
+	query := `
+		query($cursor: String, $id: String!, $filter: IssueFilter, $first: Int) {
+			cycle(id: $id) {
+				uncompletedIssuesUponClose(filter: $filter, first: $first, after: $cursor) {
+					nodes {` + IssueFields + `
+						addedToCycleAt
+					}
+					pageInfo {
+						hasNextPage
+						endCursor
+					}
+				}
+			}
+		}
+	`
+
+	variables := map[string]interface{}{"id": cycleID, "filter": issueFilter, "first": cycleIssuesPageSize}
+	return Paginate[Issue](ctx, c, query, variables, "cycle.uncompletedIssuesUponClose")
+}
+
+// Projects returns the projects matching a ProjectFilter, each with its five
+// most recent project updates.
+func (c *Client) Projects(ctx context.Context, filter map[string]interface{}) ([]Project, error) {
+	query := `
+		query($cursor: String, $filter: ProjectFilter) {
+			projects(filter: $filter, first: 50, after: $cursor) {
+				nodes {
+					id
+					name
+					url
+					state
+					progress
+					targetDate
+					lead {` + userFields + `}
+					teams {
+						nodes {` + teamFields + `}
+					}
+					projectUpdates(first: 5) {
+						nodes {
+							health
+							url
+							createdAt
+						}
+					}
+				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+
+	return Paginate[Project](ctx, c, query, map[string]interface{}{"filter": filter}, "projects")
+}
+
+// Users returns the workspace's users.
+func (c *Client) Users(ctx context.Context) ([]User, error) {
+	query := `
+		query($cursor: String) {
+			users(first: 100, after: $cursor) {
+				nodes {` + userFields + `}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+
+	return Paginate[User](ctx, c, query, nil, "users")
+}
+
+// Teams returns the teams the API key can access.
+func (c *Client) Teams(ctx context.Context) ([]Team, error) {
+	query := `
+		query($cursor: String) {
+			teams(first: 100, after: $cursor) {
+				nodes {` + teamFields + `}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+
+	return Paginate[Team](ctx, c, query, nil, "teams")
+}
+
+// Labels returns the workspace and team issue labels.
+func (c *Client) Labels(ctx context.Context) ([]Label, error) {
+	query := `
+		query($cursor: String) {
+			issueLabels(first: 100, after: $cursor) {
+				nodes {
+					id
+					name
+					color
+				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+
+	return Paginate[Label](ctx, c, query, nil, "issueLabels")
+}
+
+// WorkflowStates returns the workflow states matching a WorkflowStateFilter,
+// with their team and position.
+func (c *Client) WorkflowStates(ctx context.Context, filter map[string]interface{}) ([]State, error) {
+	query := `
+		query($cursor: String, $filter: WorkflowStateFilter) {
+			workflowStates(filter: $filter, first: 100, after: $cursor) {
+				nodes {
+					id
+					name
+					color
+					type
+					position
+					team {` + teamFields + `}
+				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+
+	return Paginate[State](ctx, c, query, map[string]interface{}{"filter": filter}, "workflowStates")
+}
+
+// CommentsSince returns the comments created after the given time.
+func (c *Client) CommentsSince(ctx context.Context, since time.Time) ([]Comment, error) {
+	query := `
+		query($cursor: String, $since: DateTimeOrDuration!) {
+			comments(filter: { createdAt: { gt: $since } }, first: 100, after: $cursor) {
+				nodes {
+					body
+					url
+					createdAt
+					user {` + userFields + `}
+					issue {
+						identifier
+						title
+						url
+					}
+				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+
+	return Paginate[Comment](ctx, c, query, map[string]interface{}{"since": since.UTC().Format(time.RFC3339)}, "comments")
+}
+
+// stateHistoryLength is how many history entries per issue LastStateChanges
+// looks at. Issues are fetched 50 at a time, so a page stays well within
+// Linear's query complexity limit.
+const stateHistoryLength = 50
+
+// LastStateChanges returns when each of the given issues last moved between
+// workflow states, judged by its stateHistoryLength latest history entries.
+// Issues without a state change among them are left out.
+func (c *Client) LastStateChanges(ctx context.Context, issueIDs []string) (map[string]time.Time, error) {
+	query := `
+		query($cursor: String, $filter: IssueFilter, $history: Int) {
+			issues(filter: $filter, first: 50, after: $cursor) {
+				nodes {
+					id
+					history(first: $history) {
+						nodes {
+							createdAt
+							fromState { id }
+							toState { id }
+						}
+					}
+				}
+				pageInfo {
+					hasNextPage
+					endCursor
+				}
+			}
+		}
+	`
+	type issueHistory struct {
+		ID      string `json:"id"`
+		History struct {
+			Nodes []IssueHistory `json:"nodes"`
+		} `json:"history"`
+	}
+
+	changes := map[string]time.Time{}
+	for start := 0; start < len(issueIDs); start += 50 {
+		ids := issueIDs[start:min(start+50, len(issueIDs))]
+		variables := map[string]interface{}{
+			"filter":  map[string]interface{}{"id": map[string]interface{}{"in": ids}},
+			"history": stateHistoryLength,
+		}
+		issues, err := Paginate[issueHistory](ctx, c, query, variables, "issues")
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			for _, entry := range issue.History.Nodes {
+				if entry.ToState == nil || entry.FromState != nil && entry.FromState.ID == entry.ToState.ID {
+					continue
+				}
+				if entry.CreatedAt.After(changes[issue.ID]) {
+					changes[issue.ID] = entry.CreatedAt
+				}
+			}
+		}
+	}
+	return changes, nil
+}
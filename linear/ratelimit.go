@@ -0,0 +1,216 @@
+package linear
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is how often a rate limited or 5xx request is
+	// retried.
+	DefaultMaxRetries = 3
+
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+
+	// throttleReserve is the fraction of a budget below which requests are
+	// spread evenly over the time left until it resets.
+	throttleReserve = 0.1
+
+	// rateLimitMaxWait is the longest a request waits for a budget to reset.
+	// Budgets reset hourly, so past this it fails with ErrBudgetExhausted
+	// instead of holding up a webhook or report.
+	rateLimitMaxWait = time.Minute
+)
+
+// ErrBudgetExhausted is returned when a request would have to wait longer
+// than rateLimitMaxWait for the rate limit budget to reset.
+var ErrBudgetExhausted = errors.New("linear rate limit budget exhausted")
+
+// RateLimit is the request and complexity budget Linear reported on the
+// latest response. Zero values mean the header was never seen.
+type RateLimit struct {
+	RequestsLimit       int       `json:"requests_limit"`
+	RequestsRemaining   int       `json:"requests_remaining"`
+	RequestsReset       time.Time `json:"requests_reset"`
+	ComplexityLimit     int       `json:"complexity_limit"`
+	ComplexityRemaining int       `json:"complexity_remaining"`
+	ComplexityReset     time.Time `json:"complexity_reset"`
+	// LastComplexity is the X-Complexity cost of the latest query.
+	LastComplexity int   `json:"last_complexity"`
+	Throttled      int64 `json:"throttled"`
+	Retries        int64 `json:"retries"`
+	// WaitSeconds is the total time spent throttled or backing off.
+	WaitSeconds float64   `json:"wait_seconds"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WithMaxRetries sets how often a rate limited or 5xx request is retried.
+// Defaults to 3; 0 disables retries.
+func WithMaxRetries(n int) Option {
+	return func(c *Client) { c.maxRetries = n }
+}
+
+// RateLimit returns the latest known rate limit budget.
+func (c *Client) RateLimit() RateLimit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rateLimit
+}
+
+// recordRateLimit stores the budget headers of a response and the cost of
+// its query.
+func (c *Client) recordRateLimit(query string, h http.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rl := &c.rateLimit
+	seen := headerInt(h, "X-RateLimit-Requests-Limit", &rl.RequestsLimit)
+	seen = headerInt(h, "X-RateLimit-Requests-Remaining", &rl.RequestsRemaining) || seen
+	seen = headerTime(h, "X-RateLimit-Requests-Reset", &rl.RequestsReset) || seen
+	seen = headerInt(h, "X-RateLimit-Complexity-Limit", &rl.ComplexityLimit) || seen
+	seen = headerInt(h, "X-RateLimit-Complexity-Remaining", &rl.ComplexityRemaining) || seen
+	seen = headerTime(h, "X-RateLimit-Complexity-Reset", &rl.ComplexityReset) || seen
+	if headerInt(h, "X-Complexity", &rl.LastComplexity) {
+		c.queryCost[query] = rl.LastComplexity
+		seen = true
+	}
+	if seen {
+		rl.UpdatedAt = time.Now()
+	}
+}
+
+// throttleDelay returns how long to wait before running query so that neither
+// budget runs out before it resets. Once a budget drops below its reserve the
+// remaining requests are spread over the time left, and an exhausted budget
+// waits for the reset.
+func (c *Client) throttleDelay(query string, now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rl := c.rateLimit
+	delay := budgetDelay(rl.RequestsLimit, rl.RequestsRemaining, 1, rl.RequestsReset, now)
+
+	cost := c.queryCost[query]
+	if cost == 0 {
+		cost = rl.LastComplexity
+	}
+	if cost > 0 {
+		if d := budgetDelay(rl.ComplexityLimit, rl.ComplexityRemaining, cost, rl.ComplexityReset, now); d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// resetDelay returns how long until the budget that rate limited the last
+// request resets, or false when no exhausted budget with a known reset was
+// recorded.
+func (c *Client) resetDelay(now time.Time) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rl := c.rateLimit
+	var reset time.Time
+	if rl.RequestsLimit > 0 && rl.RequestsRemaining <= 0 {
+		reset = rl.RequestsReset
+	}
+	if rl.ComplexityLimit > 0 && rl.ComplexityRemaining < max(rl.LastComplexity, 1) && rl.ComplexityReset.After(reset) {
+		reset = rl.ComplexityReset
+	}
+	if !reset.After(now) {
+		return 0, false
+	}
+	return reset.Sub(now), true
+}
+
+func budgetDelay(limit, remaining, cost int, reset, now time.Time) time.Duration {
+	untilReset := reset.Sub(now)
+	if limit <= 0 || untilReset <= 0 || float64(remaining) >= float64(limit)*throttleReserve {
+		return 0
+	}
+	calls := remaining / cost
+	if calls < 1 {
+		return untilReset
+	}
+	return untilReset / time.Duration(calls)
+}
+
+// throttle waits out the throttle delay of query, or until ctx is done. It
+// fails with ErrBudgetExhausted rather than wait over rateLimitMaxWait.
+func (c *Client) throttle(ctx context.Context, query string) error {
+	delay := c.throttleDelay(query, time.Now())
+	if delay <= 0 {
+		return nil
+	}
+	if delay > rateLimitMaxWait {
+		return fmt.Errorf("%w: next request allowed in %s", ErrBudgetExhausted, delay.Round(time.Second))
+	}
+	c.mu.Lock()
+	c.rateLimit.Throttled++
+	c.rateLimit.WaitSeconds += delay.Seconds()
+	c.mu.Unlock()
+	return sleep(ctx, delay)
+}
+
+// retryable reports whether a failed request is worth retrying: rate limited
+// queries and server errors.
+func retryable(err error) bool {
+	if rateLimited(err) {
+		return true
+	}
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode >= 500
+}
+
+// rateLimited reports whether Linear rejected a request for its rate limit,
+// as a RATELIMITED GraphQL error or a 429.
+func rateLimited(err error) bool {
+	var httpErr *HTTPError
+	return ErrorCode(err) == CodeRateLimited ||
+		errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests
+}
+
+// retryDelay is an exponential backoff with full jitter.
+func retryDelay(attempt int) time.Duration {
+	d := retryBaseDelay << attempt
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func headerInt(h http.Header, name string, dst *int) bool {
+	n, err := strconv.Atoi(h.Get(name))
+	if err != nil {
+		return false
+	}
+	*dst = n
+	return true
+}
+
+// headerTime parses a reset header in UTC epoch milliseconds.
+func headerTime(h http.Header, name string, dst *time.Time) bool {
+	ms, err := strconv.ParseInt(h.Get(name), 10, 64)
+	if err != nil {
+		return false
+	}
+	*dst = time.UnixMilli(ms)
+	return true
+}
@@ -0,0 +1,181 @@
+package linear
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBudgetDelay(t *testing.T) {
+	now := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(10 * time.Minute)
+
+	tests := []struct {
+		name      string
+		limit     int
+		remaining int
+		cost      int
+		reset     time.Time
+		want      time.Duration
+	}{
+		{"unknown budget", 0, 0, 1, reset, 0},
+		{"above reserve", 1000, 500, 1, reset, 0},
+		{"at reserve", 1000, 100, 1, reset, 0},
+		{"below reserve", 1000, 10, 1, reset, time.Minute},
+		{"below reserve, costly query", 1000, 10, 5, reset, 5 * time.Minute},
+		{"exhausted", 1000, 0, 1, reset, 10 * time.Minute},
+		{"cost above remaining", 1000, 3, 5, reset, 10 * time.Minute},
+		{"reset passed", 1000, 0, 1, now.Add(-time.Second), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := budgetDelay(tt.limit, tt.remaining, tt.cost, tt.reset, now); got != tt.want {
+				t.Errorf("budgetDelay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", Errors{{Message: "slow down", Extensions: ErrorExtensions{Code: CodeRateLimited}}}, true},
+		{"wrapped rate limited", fmt.Errorf("issues: %w", Errors{{Extensions: ErrorExtensions{Code: CodeRateLimited}}}), true},
+		{"429", &HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{"500", &HTTPError{StatusCode: http.StatusInternalServerError}, true},
+		{"503", &HTTPError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"400", &HTTPError{StatusCode: http.StatusBadRequest}, false},
+		{"401", &HTTPError{StatusCode: http.StatusUnauthorized}, false},
+		{"invalid input", Errors{{Extensions: ErrorExtensions{Code: CodeInvalidInput}}}, false},
+		{"budget exhausted", fmt.Errorf("%w: next request allowed in 1h", ErrBudgetExhausted), false},
+		{"canceled", context.Canceled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderTime(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"epoch milliseconds", "1717416000123", time.UnixMilli(1717416000123), true},
+		{"missing", "", time.Time{}, false},
+		{"not a number", "soon", time.Time{}, false},
+		{"RFC 3339", "2024-06-03T12:00:00Z", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.value != "" {
+				h.Set("X-RateLimit-Requests-Reset", tt.value)
+			}
+			var got time.Time
+			ok := headerTime(h, "X-RateLimit-Requests-Reset", &got)
+			if ok != tt.ok || !got.Equal(tt.want) {
+				t.Errorf("headerTime(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRecordRateLimit(t *testing.T) {
+	c := NewClient("")
+	h := http.Header{}
+	h.Set("X-RateLimit-Requests-Limit", "1500")
+	h.Set("X-RateLimit-Requests-Remaining", "1499")
+	h.Set("X-RateLimit-Requests-Reset", "1717416000000")
+	h.Set("X-Complexity", "42")
+	c.recordRateLimit("query", h)
+
+	rl := c.RateLimit()
+	if rl.RequestsLimit != 1500 || rl.RequestsRemaining != 1499 || !rl.RequestsReset.Equal(time.UnixMilli(1717416000000)) {
+		t.Errorf("requests budget = %d/%d until %v", rl.RequestsRemaining, rl.RequestsLimit, rl.RequestsReset)
+	}
+	if rl.LastComplexity != 42 || c.queryCost["query"] != 42 {
+		t.Errorf("complexity = %d, cost = %d, want 42", rl.LastComplexity, c.queryCost["query"])
+	}
+	if rl.UpdatedAt.IsZero() {
+		t.Error("UpdatedAt not set")
+	}
+}
+
+// rateLimitedHandler answers with a RATELIMITED error and an exhausted
+// request budget that resets after resetIn.
+func rateLimitedHandler(resetIn time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Requests-Limit", "1500")
+		w.Header().Set("X-RateLimit-Requests-Remaining", "0")
+		w.Header().Set("X-RateLimit-Requests-Reset", strconv.FormatInt(time.Now().Add(resetIn).UnixMilli(), 10))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":[{"message":"Rate limit exceeded","extensions":{"code":"RATELIMITED"}}]}`))
+	}
+}
+
+func TestRateLimitedFailsFastWhenResetIsFar(t *testing.T) {
+	calls := 0
+	handler := rateLimitedHandler(time.Hour)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		handler(w, r)
+	}, WithMaxRetries(3))
+
+	start := time.Now()
+	err := c.Do(context.Background(), `query { viewer { id } }`, nil, nil)
+	if !errors.Is(err, ErrBudgetExhausted) || !IsRateLimited(err) {
+		t.Fatalf("Do = %v, want ErrBudgetExhausted", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do took %v, want it to fail fast", elapsed)
+	}
+
+	// The next request doesn't wait for the reset either.
+	err = c.Do(context.Background(), `query { viewer { id } }`, nil, nil)
+	if !errors.Is(err, ErrBudgetExhausted) || calls != 1 {
+		t.Errorf("second Do = %v after %d calls, want ErrBudgetExhausted without a request", err, calls)
+	}
+}
+
+func TestRateLimitedWaitsForReset(t *testing.T) {
+	calls := 0
+	limited := rateLimitedHandler(200 * time.Millisecond)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			limited(w, r)
+			return
+		}
+		w.Write([]byte(`{"data":{}}`))
+	}, WithMaxRetries(1))
+
+	start := time.Now()
+	if err := c.Do(context.Background(), `query { viewer { id } }`, nil, nil); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("retried after %v, want it to wait for the reset", elapsed)
+	}
+}
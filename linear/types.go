@@ -0,0 +1,136 @@
+package linear
+
+import "time"
+
+// Organization is a Linear workspace.
+type Organization struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	URLKey string `json:"urlKey"`
+}
+
+type Issue struct {
+	ID             string     `json:"id"`
+	Identifier     string     `json:"identifier"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description,omitempty"`
+	Priority       int        `json:"priority"`
+	PriorityLabel  string     `json:"priorityLabel"`
+	URL            string     `json:"url"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	StartedAt      *time.Time `json:"startedAt"`
+	CompletedAt    *time.Time `json:"completedAt"`
+	CanceledAt     *time.Time `json:"canceledAt"`
+	DueDate        string     `json:"dueDate"`
+	SLABreachesAt  *time.Time `json:"slaBreachesAt"`
+	Estimate       *float64   `json:"estimate"`
+	AddedToCycleAt *time.Time `json:"addedToCycleAt"`
+	State          State      `json:"state"`
+	Assignee       *User      `json:"assignee"`
+	Team           Team       `json:"team"`
+	Labels         struct {
+		Nodes []Label `json:"nodes"`
+	} `json:"labels"`
+}
+
+// State is a workflow state. Team and Position are only set by
+// WorkflowStates.
+type State struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Color    string  `json:"color"`
+	Type     string  `json:"type"`
+	Position float64 `json:"position,omitempty"`
+	Team     *Team   `json:"team,omitempty"`
+}
+
+type User struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Email       string `json:"email"`
+}
+
+type Team struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+type Label struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type Cycle struct {
+	ID          string     `json:"id"`
+	Number      int        `json:"number"`
+	Name        string     `json:"name"`
+	StartsAt    time.Time  `json:"startsAt"`
+	EndsAt      time.Time  `json:"endsAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+	Team        Team       `json:"team"`
+
+	// Daily history since the start of the cycle, in estimate points and
+	// issue counts.
+	ScopeHistory               []float64 `json:"scopeHistory"`
+	CompletedScopeHistory      []float64 `json:"completedScopeHistory"`
+	IssueCountHistory          []float64 `json:"issueCountHistory"`
+	CompletedIssueCountHistory []float64 `json:"completedIssueCountHistory"`
+
+	Issues struct {
+		Nodes []Issue `json:"nodes"`
+	} `json:"issues"`
+	UncompletedIssuesUponClose struct {
+		Nodes []Issue `json:"nodes"`
+	} `json:"uncompletedIssuesUponClose"`
+}
+
+type Project struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	URL      string  `json:"url"`
+	State    string  `json:"state"`
+	Progress float64 `json:"progress"`
+	// TargetDate is a calendar date ("2006-01-02") or empty.
+	TargetDate string `json:"targetDate"`
+	Lead       *User  `json:"lead"`
+	Teams      struct {
+		Nodes []Team `json:"nodes"`
+	} `json:"teams"`
+	ProjectUpdates struct {
+		Nodes []ProjectUpdate `json:"nodes"`
+	} `json:"projectUpdates"`
+}
+
+type ProjectUpdate struct {
+	Health    string    `json:"health"` // onTrack, atRisk or offTrack
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Comment is a comment with the issue it belongs to.
+type Comment struct {
+	Body      string    `json:"body"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"createdAt"`
+	User      *User     `json:"user"`
+	Issue     *IssueRef `json:"issue"`
+}
+
+// IssueHistory is one entry of an issue's history. FromState and ToState are
+// only set on entries that moved the issue between workflow states.
+type IssueHistory struct {
+	CreatedAt time.Time `json:"createdAt"`
+	FromState *State    `json:"fromState"`
+	ToState   *State    `json:"toState"`
+}
+
+// IssueRef identifies an issue without its details.
+type IssueRef struct {
+	Identifier string `json:"identifier"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+}
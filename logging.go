@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// LOGGING
+// ============================================================================
+
+// logPayloads enables logging of redacted webhook payloads (LOG_PAYLOADS).
+var logPayloads bool
+
+// setupLogging installs the default slog logger. level is debug, info (the
+// default), warn or error; format is json (the default) or text. Lines
+// written through package log end up there too, at info level.
+func setupLogging(w io.Writer, level, format string) error {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("invalid LOG_LEVEL %q: %w", level, err)
+		}
+	}
+	handlerOpts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	case "text":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT %q: want json or text", format)
+	}
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// fatal logs an error and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// ----------------------------------------------------------------------------
+// Request and delivery IDs
+// ----------------------------------------------------------------------------
+
+type logAttrsKey struct{}
+
+// withLogAttrs returns a context whose log lines carry attrs, e.g. the
+// request ID, in addition to those already on ctx.
+func withLogAttrs(ctx context.Context, args ...any) context.Context {
+	r := slog.NewRecord(time.Time{}, 0, "", 0)
+	r.Add(args...)
+	attrs := append([]slog.Attr{}, logAttrs(ctx)...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, logAttrsKey{}, attrs)
+}
+
+func logAttrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler adds the attrs of withLogAttrs to every record logged with
+// a context, so code that passes ctx along needs no logger of its own.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := logAttrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	if span := spanFromContext(ctx); span != nil {
+		r.AddAttrs(
+			slog.String("trace_id", hex.EncodeToString(span.traceID[:])),
+			slog.String("span_id", hex.EncodeToString(span.spanID[:])),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// newRequestID returns a random 16 character hex ID.
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+var requestIDPattern = regexp.MustCompile(`^[\w.:-]{1,64}$`)
+
+// withRequestID gives every request an ID, taken from a well-formed
+// X-Request-ID header or generated, that is echoed in the response and
+// logged with every line written for the request.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(withLogAttrs(r.Context(), "request_id", id)))
+	})
+}
+
+// ----------------------------------------------------------------------------
+// Redaction
+// ----------------------------------------------------------------------------
+
+var (
+	emailPattern          = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+	discordWebhookPattern = regexp.MustCompile(`(discord(?:app)?\.com/api(?:/v\d+)?/webhooks/\d+/)[\w-]+`)
+)
+
+// redactedFields hold free text written by users: issue descriptions,
+// comment bodies and their rich text versions.
+var redactedFields = map[string]bool{
+	"body":            true,
+	"bodyData":        true,
+	"content":         true,
+	"contentData":     true,
+	"description":     true,
+	"descriptionData": true,
+}
+
+// redactString masks email addresses and Discord webhook tokens.
+func redactString(s string) string {
+	s = emailPattern.ReplaceAllString(s, "$1***@$2")
+	return discordWebhookPattern.ReplaceAllString(s, "$1***")
+}
+
+// redactURLError masks the webhook token in the URL of a failed request.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactString(urlErr.URL)
+	}
+	return err
+}
+
+// redactPayload returns a JSON payload with free text replaced by its
+// length, secrets masked and emails and webhook tokens masked in all other
+// strings. Bodies that aren't JSON are masked completely.
+func redactPayload(body []byte) loggedPayload {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return loggedPayload(fmt.Sprintf(`"[redacted %d bytes]"`, len(body)))
+	}
+	redacted, err := json.Marshal(redactValue("", v))
+	if err != nil {
+		return loggedPayload(fmt.Sprintf(`"[redacted %d bytes]"`, len(body)))
+	}
+	return loggedPayload(redacted)
+}
+
+func redactValue(key string, v interface{}) interface{} {
+	lower := strings.ToLower(key)
+	switch {
+	case redactedFields[key]:
+		if v == nil {
+			return nil
+		}
+		if s, ok := v.(string); ok {
+			return fmt.Sprintf("[redacted %d chars]", len(s))
+		}
+		return "[redacted]"
+	case strings.Contains(lower, "token") || strings.Contains(lower, "secret") || strings.Contains(lower, "password"):
+		if v == nil {
+			return nil
+		}
+		return "***"
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = redactValue(k, child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(key, child)
+		}
+		return v
+	case string:
+		return redactString(v)
+	}
+	return v
+}
+
+// loggedPayload is redacted JSON that the JSON handler nests as an object and
+// the text handler writes as is.
+type loggedPayload []byte
+
+func (p loggedPayload) MarshalJSON() ([]byte, error) { return p, nil }
+func (p loggedPayload) MarshalText() ([]byte, error) { return p, nil }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestRedactPayload(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"free text",
+			`{"data":{"title":"Crash","description":"steps to reproduce","descriptionData":{"type":"doc"}}}`,
+			`{"data":{"description":"[redacted 18 chars]","descriptionData":"[redacted]","title":"Crash"}}`,
+		},
+		{
+			"comment body",
+			`{"type":"Comment","data":{"body":"héllo","issue":{"identifier":"ENG-1"}}}`,
+			`{"data":{"body":"[redacted 6 chars]","issue":{"identifier":"ENG-1"}},"type":"Comment"}`,
+		},
+		{
+			"null free text",
+			`{"description":null}`,
+			`{"description":null}`,
+		},
+		{
+			"secrets",
+			`{"accessToken":"lin_oauth_x","webhookSecret":"s","Password":"p","refresh_token":null}`,
+			`{"Password":"***","accessToken":"***","refresh_token":null,"webhookSecret":"***"}`,
+		},
+		{
+			"emails",
+			`{"actor":{"email":"jane.doe@example.com"},"title":"ping bob@acme.io"}`,
+			`{"actor":{"email":"j***@example.com"},"title":"ping b***@acme.io"}`,
+		},
+		{
+			"discord webhook",
+			`{"url":"https://discord.com/api/webhooks/123/abc-DEF_9"}`,
+			`{"url":"https://discord.com/api/webhooks/123/***"}`,
+		},
+		{
+			"arrays",
+			`{"labels":[{"name":"bug","description":"x"}],"tokens":["a","b"]}`,
+			`{"labels":[{"description":"[redacted 1 chars]","name":"bug"}],"tokens":"***"}`,
+		},
+		{
+			"not JSON",
+			`token=abc&email=jane@example.com`,
+			`"[redacted 32 bytes]"`,
+		},
+		{
+			"numbers and booleans",
+			`{"priority":2,"archived":false,"webhookTimestamp":1717243200000}`,
+			`{"archived":false,"priority":2,"webhookTimestamp":1717243200000}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactPayload([]byte(tt.in))
+			if string(got) != tt.want {
+				t.Errorf("redactPayload(%s)\n got %s\nwant %s", tt.in, got, tt.want)
+			}
+			if !json.Valid(got) {
+				t.Errorf("redacted payload is not valid JSON: %s", got)
+			}
+		})
+	}
+}
+
+func TestRedactURLError(t *testing.T) {
+	err := redactURLError(&url.Error{
+		Op:  "Post",
+		URL: "https://discordapp.com/api/v10/webhooks/42/secret-token?wait=true",
+		Err: errors.New("connection refused"),
+	})
+	want := `Post "https://discordapp.com/api/v10/webhooks/42/***?wait=true": connection refused`
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
@@ -2,104 +2,62 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
-)
 
-const linearAPIURL = "https://api.linear.app/graphql"
+	"github.com/scenextras/linear-daily-digest/linear"
+)
 
 // ============================================================================
 // LINEAR TYPES
 // ============================================================================
 
-type GraphQLRequest struct {
-	Query     string                 `json:"query"`
-	Variables map[string]interface{} `json:"variables,omitempty"`
-}
-
-type GraphQLResponse struct {
-	Data   json.RawMessage `json:"data"`
-	Errors []GraphQLError  `json:"errors,omitempty"`
-}
-
-type GraphQLError struct {
-	Message string `json:"message"`
-}
-
-type IssuesResponse struct {
-	Issues struct {
-		Nodes    []Issue  `json:"nodes"`
-		PageInfo PageInfo `json:"pageInfo"`
-	} `json:"issues"`
-}
-
-type PageInfo struct {
-	HasNextPage bool   `json:"hasNextPage"`
-	EndCursor   string `json:"endCursor"`
-}
-
-type Issue struct {
-	ID            string    `json:"id"`
-	Identifier    string    `json:"identifier"`
-	Title         string    `json:"title"`
-	Description   string    `json:"description,omitempty"`
-	Priority      int       `json:"priority"`
-	PriorityLabel string    `json:"priorityLabel"`
-	URL           string    `json:"url"`
-	CreatedAt     time.Time `json:"createdAt"`
-	UpdatedAt     time.Time `json:"updatedAt"`
-	State         State     `json:"state"`
-	Assignee      *User     `json:"assignee"`
-	Team          Team      `json:"team"`
-	Labels        struct {
-		Nodes []Label `json:"nodes"`
-	} `json:"labels"`
-}
-
-type State struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Color string `json:"color"`
-	Type  string `json:"type"`
-}
-
-type User struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	DisplayName string `json:"displayName"`
-	Email       string `json:"email"`
-}
-
-type Team struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Key  string `json:"key"`
-}
-
-type Label struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Color string `json:"color"`
-}
+// The API types live in package linear; the aliases keep the report code
+// short.
+type (
+	Issue         = linear.Issue
+	State         = linear.State
+	User          = linear.User
+	Team          = linear.Team
+	Label         = linear.Label
+	Cycle         = linear.Cycle
+	Project       = linear.Project
+	ProjectUpdate = linear.ProjectUpdate
+	IssueComment  = linear.Comment
+)
 
 // Linear Webhook types
 type LinearWebhook struct {
-	Action       string          `json:"action"`
-	Actor        *User           `json:"actor,omitempty"`
-	CreatedAt    string          `json:"createdAt"`
-	Data         json.RawMessage `json:"data"`
-	Type         string          `json:"type"`
-	URL          string          `json:"url,omitempty"`
-	UpdatedFrom  json.RawMessage `json:"updatedFrom,omitempty"`
-	WebhookID    string          `json:"webhookId,omitempty"`
-	WebhookTS    int64           `json:"webhookTimestamp,omitempty"`
+	Action    string          `json:"action"`
+	Actor     *User           `json:"actor,omitempty"`
+	CreatedAt string          `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+	Type      string          `json:"type"`
+	URL       string          `json:"url,omitempty"`
+	// OrganizationID identifies the workspace the event comes from.
+	OrganizationID string          `json:"organizationId,omitempty"`
+	UpdatedFrom    json.RawMessage `json:"updatedFrom,omitempty"`
+	WebhookID      string          `json:"webhookId,omitempty"`
+	WebhookTS      int64           `json:"webhookTimestamp,omitempty"`
 }
 
 type LinearWebhookIssue struct {
@@ -114,6 +72,12 @@ type LinearWebhookIssue struct {
 	Team          *Team   `json:"team,omitempty"`
 	Labels        []Label `json:"labels,omitempty"`
 	URL           string  `json:"url,omitempty"`
+
+	// IDs Linear sends alongside (or instead of) the nested objects.
+	StateID    string   `json:"stateId,omitempty"`
+	TeamID     string   `json:"teamId,omitempty"`
+	AssigneeID string   `json:"assigneeId,omitempty"`
+	LabelIDs   []string `json:"labelIds,omitempty"`
 }
 
 type LinearWebhookComment struct {
@@ -138,10 +102,14 @@ type LinearWebhookProject struct {
 // ============================================================================
 
 type DiscordWebhook struct {
-	Content   string         `json:"content,omitempty"`
-	Username  string         `json:"username,omitempty"`
-	AvatarURL string         `json:"avatar_url,omitempty"`
-	Embeds    []DiscordEmbed `json:"embeds,omitempty"`
+	Content     string              `json:"content,omitempty"`
+	Username    string              `json:"username,omitempty"`
+	AvatarURL   string              `json:"avatar_url,omitempty"`
+	Embeds      []DiscordEmbed      `json:"embeds,omitempty"`
+	Attachments []DiscordAttachment `json:"attachments,omitempty"`
+
+	// Files are uploaded with the message as multipart/form-data.
+	Files []DiscordFile `json:"-"`
 }
 
 type DiscordEmbed struct {
@@ -153,6 +121,7 @@ type DiscordEmbed struct {
 	Footer      *DiscordFooter `json:"footer,omitempty"`
 	Author      *DiscordAuthor `json:"author,omitempty"`
 	Fields      []DiscordField `json:"fields,omitempty"`
+	Image       *DiscordImage  `json:"image,omitempty"`
 }
 
 type DiscordFooter struct {
@@ -172,11 +141,33 @@ type DiscordField struct {
 	Inline bool   `json:"inline,omitempty"`
 }
 
+type DiscordImage struct {
+	URL string `json:"url"`
+}
+
+type DiscordAttachment struct {
+	ID       int    `json:"id"`
+	Filename string `json:"filename"`
+}
+
+type DiscordFile struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// attach adds a file to the message and returns the URL embeds use to show it.
+func (p *DiscordWebhook) attach(name string, data []byte) string {
+	p.Attachments = append(p.Attachments, DiscordAttachment{ID: len(p.Files), Filename: name})
+	p.Files = append(p.Files, DiscordFile{Name: name, Data: data})
+	return "attachment://" + name
+}
+
 // Colors
 const (
 	ColorBlue   = 0x5E6AD2 // Linear brand color
 	ColorGreen  = 0x22C55E // Success/Done
 	ColorYellow = 0xEAB308 // Warning/In Progress
+	ColorOrange = 0xF97316 // Escalation
 	ColorRed    = 0xEF4444 // Error/Urgent
 	ColorGray   = 0x6B7280 // Neutral
 	ColorPurple = 0x8B5CF6 // Comments
@@ -186,7 +177,12 @@ const linearAvatarURL = "https://asset.brandfetch.io/ideiLNHwrW/id_xq4rBdb.png"
 
 var (
 	linearAPIKey      string
+	linearClient      *linear.Client
 	discordWebhookURL string
+
+	// linearWebhookSecrets are the signing secrets webhooks are verified
+	// with: the OAuth app's and those of webhooks created in a workspace.
+	linearWebhookSecrets []string
 )
 
 // ============================================================================
@@ -194,33 +190,145 @@ var (
 // ============================================================================
 
 func main() {
+	if err := setupLogging(os.Stderr, os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT")); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	logPayloads = os.Getenv("LOG_PAYLOADS") == "true"
+	if err := loadTracing(os.Getenv); err != nil {
+		fatal("Invalid tracing configuration", "error", err)
+	}
+
 	discordWebhookURL = os.Getenv("DISCORD_WEBHOOK_URL")
 	if discordWebhookURL == "" {
-		log.Fatal("DISCORD_WEBHOOK_URL environment variable is required")
+		fatal("DISCORD_WEBHOOK_URL environment variable is required")
 	}
 
 	// LINEAR_API_KEY is optional - only needed for daily digest
 	linearAPIKey = os.Getenv("LINEAR_API_KEY")
+	linearClient = linear.NewClient(linearAPIKey, linearOptions()...)
+
+	config, err := loadConfig(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	appConfig = config
+	if linearAPIKey != "" {
+		metadata = newMetadataCache(linearClient, appConfig.metadataTTL)
+		metadata.warm()
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	// Start internal scheduler for daily reports (9 AM UTC, Mon-Fri)
-	go startDailyScheduler()
+	adminToken = os.Getenv("ADMIN_TOKEN")
+
+	shutdownTimeout, err := parseShutdownTimeout(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil {
+		fatal("Invalid shutdown configuration", "error", err)
+	}
+
+	if err := loadDiscordBot(os.Getenv("DISCORD_BOT_TOKEN"), os.Getenv("DISCORD_APPLICATION_ID"), os.Getenv("DISCORD_PUBLIC_KEY")); err != nil {
+		fatal("Invalid Discord bot configuration", "error", err)
+	}
+
+	store, err = openStateStore(os.Getenv("STATE_DIR"))
+	if err != nil {
+		fatal("Invalid state directory", "error", err)
+	}
+
+	if err := loadOAuth(os.Getenv("LINEAR_CLIENT_ID"), os.Getenv("LINEAR_CLIENT_SECRET"), os.Getenv("LINEAR_OAUTH_REDIRECT_URL"), os.Getenv("TOKEN_ENCRYPTION_KEY")); err != nil {
+		fatal("Invalid Linear OAuth configuration", "error", err)
+	}
+	if err := loadWorkspaces(); err != nil {
+		fatal("Could not load installed workspaces", "error", err)
+	}
+	if oauthConfig != nil {
+		// Only this workspace's events may use the global relay_to.
+		hostOrg.resolve(context.Background())
+	}
+
+	for _, secret := range strings.Split(os.Getenv("LINEAR_WEBHOOK_SECRET"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			linearWebhookSecrets = append(linearWebhookSecrets, secret)
+		}
+	}
+	switch {
+	case len(linearWebhookSecrets) == 0 && oauthConfig != nil:
+		// Webhooks are routed to a workspace by their organizationId, which
+		// must not be forgeable.
+		fatal("LINEAR_WEBHOOK_SECRET is required with LINEAR_CLIENT_ID")
+	case len(linearWebhookSecrets) == 0:
+		slog.Warn("LINEAR_WEBHOOK_SECRET not set, webhook signatures are not verified")
+	}
+
+	// Deliver what the previous instance spooled while shutting down
+	go replayOutbox()
+
+	// Start internal scheduler for configured reports
+	startScheduler(appConfig.Jobs, appConfig.holidays)
+	go registerSlashCommands()
 
 	// Routes
 	http.HandleFunc("/health", handleHealth)
-	http.HandleFunc("/webhook", handleLinearWebhook)       // Linear → Discord relay
-	http.HandleFunc("/report", handleReport)               // Daily digest summary
-	http.HandleFunc("/report/by-user", handleReportByUser) // Detailed per-user report
+	http.HandleFunc("/health/live", handleLive)
+	http.HandleFunc("/health/ready", handleReady)
+	http.HandleFunc("/metrics", handleMetrics)
+	http.HandleFunc("/webhook", handleLinearWebhook)                      // Linear → Discord relay
+	http.HandleFunc("/report", handleReport)                              // Daily digest summary
+	http.HandleFunc("/report/by-user", handleReportByUser)                // Detailed per-user report
+	http.HandleFunc("/report/stale", handleReportStale)                   // Stuck and neglected issues
+	http.HandleFunc("/report/weekly", handleReportWeekly)                 // Completed work of the last 7 days
+	http.HandleFunc("/report/cycles", handleReportCycles)                 // Active cycle progress or summaries
+	http.HandleFunc("/report/projects", handleReportProjects)             // Project portfolio health
+	http.HandleFunc("/report/sla", handleReportSLA)                       // Due date and SLA breach alerts
+	http.HandleFunc("/report/preview", requireAdmin(handleReportPreview)) // Dry run of a scheduled job
+	http.HandleFunc("/report/export", requireAdmin(handleReportExport))   // Open issues as CSV, JSON or Markdown
+	http.HandleFunc("/discord/interactions", handleDiscordInteractions)   // Slash commands
+	http.HandleFunc("/oauth/linear/callback", handleOAuthCallback)        // Linear OAuth install redirect
+	http.HandleFunc("/admin/scheduler", requireAdmin(handleSchedulerStatus))
+	http.HandleFunc("/admin/scheduler/pause", requireAdmin(handleSchedulerPause))
+	http.HandleFunc("/admin/scheduler/resume", requireAdmin(handleSchedulerResume))
+	http.HandleFunc("/admin/workspaces", requireAdmin(handleWorkspaces))
+	http.HandleFunc("/admin/workspaces/install", requireAdmin(handleOAuthInstall))
+	http.HandleFunc("/admin/linear", requireAdmin(handleLinearStatus))
 	http.HandleFunc("/", handleRoot)
 
-	log.Printf("Linear-Discord Communication Relay listening on port %s", port)
-	log.Printf("Endpoints: /webhook (Linear relay), /report (daily digest), /health")
-	log.Printf("Daily report scheduled: 9:00 AM UTC, Monday-Friday")
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	slog.Info("Linear-Discord Communication Relay listening", "port", port)
+	slog.Info("Endpoints: /webhook (Linear relay), /report (daily digest), /health, /metrics")
+
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           withRequestID(traceRequests(http.DefaultServeMux)),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		// Reports respond once every message is posted, which takes a while
+		// for per-user reports.
+		WriteTimeout: 10 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			fatal("Server stopped", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // a second signal kills the process
+	shutdown(server, shutdownTimeout)
+}
+
+// linearOptions are the Linear client options shared by all workspaces.
+func linearOptions() []linear.Option {
+	opts := []linear.Option{linear.WithObserver(observeLinearRequest), linear.WithTracer(traceLinearRequest)}
+	if url := os.Getenv("LINEAR_API_URL"); url != "" {
+		opts = append(opts, linear.WithBaseURL(url))
+	}
+	return opts
 }
 
 func handleRoot(w http.ResponseWriter, r *http.Request) {
@@ -233,16 +341,38 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 		"service": "Linear-Discord Communication Relay",
 		"version": "1.0.0",
 		"endpoints": map[string]string{
-			"/webhook": "POST - Receive Linear webhooks and forward to Discord",
-			"/report":  "GET/POST - Generate and send daily digest",
-			"/health":  "GET - Health check",
+			"/webhook":                  "POST - Receive Linear webhooks and forward to Discord",
+			"/report":                   "GET/POST - Generate and send daily digest",
+			"/health":                   "GET - Health check",
+			"/admin/scheduler":          "GET - Scheduled jobs with next run times (admin)",
+			"/report/stale":             "GET/POST - Generate and send stale issue report",
+			"/report/weekly":            "GET/POST - Generate and send weekly recap",
+			"/report/cycles":            "GET/POST - Send active cycle progress (?summary=true for ended cycles)",
+			"/report/projects":          "GET/POST - Generate and send project health report",
+			"/discord/interactions":     "POST - Discord slash command interactions",
+			"/report/sla":               "GET/POST - Alert on issues near or past their due date or SLA",
+			"/report/preview":           "GET - Preview a scheduled job without posting (?job=name[&format=html], admin)",
+			"/report/export":            "GET - Open issues as CSV, JSON or Markdown (?format=csv|json|md, admin)",
+			"/admin/linear":             "GET - Linear rate limit budget and metadata cache per workspace (admin)",
+			"/oauth/linear/callback":    "GET - Linear OAuth install redirect",
+			"/admin/workspaces":         "GET - Installed Linear workspaces (admin)",
+			"/admin/workspaces/install": "GET - Link that installs the relay in a Linear workspace (admin)",
+			"/metrics":                  "GET - Prometheus metrics",
+			"/health/live":              "GET - Liveness probe",
+			"/health/ready":             "GET - Readiness: Linear and Discord checks, outbox, scheduler, config version",
 		},
 	})
 }
 
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(struct {
+		Status     string      `json:"status"`
+		MissedRuns []MissedRun `json:"missed_runs,omitempty"`
+	}{
+		Status:     "ok",
+		MissedRuns: scheduler.missedRuns(),
+	})
 }
 
 // ============================================================================
@@ -255,430 +385,335 @@ func handleLinearWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Deliveries finish even if Linear stops waiting for the response.
+	ctx := context.WithoutCancel(r.Context())
+	if id := r.Header.Get("Linear-Delivery"); id != "" {
+		ctx = withLogAttrs(ctx, "delivery_id", id)
+	}
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading body: %v", err)
+		slog.ErrorContext(ctx, "Error reading body", "error", err)
 		http.Error(w, "Error reading request", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
-	log.Printf("Received Linear webhook: %s", string(body))
+	if len(linearWebhookSecrets) > 0 {
+		if err := verifyLinearWebhook(linearWebhookSecrets, r.Header.Get("Linear-Signature"), body, time.Now()); err != nil {
+			slog.WarnContext(ctx, "Rejected Linear webhook", "error", err)
+			http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
+			return
+		}
+	}
 
 	var webhook LinearWebhook
 	if err := json.Unmarshal(body, &webhook); err != nil {
-		log.Printf("Error parsing webhook: %v", err)
+		slog.ErrorContext(ctx, "Error parsing webhook", "error", err, "bytes", len(body))
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	recordWebhookReceived(webhook.Type, webhook.Action)
 
-	discordPayload, err := transformWebhookToDiscord(webhook)
-	if err != nil {
-		log.Printf("Error transforming webhook: %v", err)
-		http.Error(w, "Error processing webhook", http.StatusInternalServerError)
-		return
+	identifier := webhookIssueIdentifier(webhook)
+	ctx = withLogAttrs(ctx, "type", webhook.Type, "action", webhook.Action, "issue", identifier)
+	eventAttrs := []interface{}{"linear.event.type", webhook.Type, "linear.event.action", webhook.Action, "linear.issue.identifier", identifier}
+	spanFromContext(ctx).set(eventAttrs...)
+	slog.InfoContext(ctx, "Received Linear webhook", "bytes", len(body))
+	if logPayloads {
+		slog.InfoContext(ctx, "Webhook payload", "payload", redactPayload(body))
 	}
 
-	if discordPayload == nil {
-		w.WriteHeader(http.StatusOK)
-		return
+	cache, targets := relayTargets(ctx, webhook)
+	if cache != nil {
+		cache.observe(webhook)
 	}
 
-	if err := sendToDiscord(discordPayload); err != nil {
-		log.Printf("Error sending to Discord: %v", err)
-		http.Error(w, "Error forwarding to Discord", http.StatusInternalServerError)
-		return
-	}
+	// A failed destination doesn't stop the others, and the webhook is still
+	// acknowledged: Linear would retry it to every destination, posting it
+	// twice where it did go through.
+	forwarded, failed := 0, 0
+	for _, dest := range targets {
+		dctx, span := startSpan(ctx, "relay.deliver", spanKindInternal, append([]interface{}{"relay.destination", dest.Name}, eventAttrs...)...)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "forwarded"})
-}
+		discordPayload, err := transformWebhookToDiscord(dctx, webhook, dest, cache)
+		if err != nil {
+			webhookDeliveries.inc(dest.Name, "failed")
+			slog.ErrorContext(dctx, "Error transforming webhook", "destination", dest.Name, "error", err)
+			span.end(err)
+			failed++
+			continue
+		}
 
-func transformWebhookToDiscord(webhook LinearWebhook) (*DiscordWebhook, error) {
-	switch webhook.Type {
-	case "Issue":
-		return transformIssueWebhook(webhook)
-	case "Comment":
-		return transformCommentWebhook(webhook)
-	case "Project":
-		return transformProjectWebhook(webhook)
-	default:
-		log.Printf("Unhandled webhook type: %s", webhook.Type)
-		return nil, nil
-	}
-}
+		if discordPayload == nil {
+			webhookDeliveries.inc(dest.Name, "filtered")
+			span.set("relay.result", "filtered")
+			span.end(nil)
+			continue
+		}
 
-func transformIssueWebhook(webhook LinearWebhook) (*DiscordWebhook, error) {
-	var issue LinearWebhookIssue
-	if err := json.Unmarshal(webhook.Data, &issue); err != nil {
-		return nil, fmt.Errorf("failed to parse issue data: %w", err)
-	}
-
-	var title, emoji string
-	color := ColorBlue
-
-	switch webhook.Action {
-	case "create":
-		emoji = "🎯"
-		title = "New Issue Created"
-		color = ColorBlue
-	case "update":
-		emoji = "📝"
-		title = "Issue Updated"
-		color = ColorYellow
-	case "remove":
-		emoji = "🗑️"
-		title = "Issue Removed"
-		color = ColorRed
-	default:
-		emoji = "📋"
-		title = fmt.Sprintf("Issue %s", strings.Title(webhook.Action))
+		if err := sendToWebhook(dctx, dest, discordPayload); err != nil {
+			webhookDeliveries.inc(dest.Name, "failed")
+			slog.ErrorContext(dctx, "Error sending to Discord", "destination", dest.Name, "error", err)
+			span.end(err)
+			failed++
+			continue
+		}
+		webhookDeliveries.inc(dest.Name, "relayed")
+		span.set("relay.result", "relayed")
+		span.end(nil)
+		forwarded++
 	}
 
-	description := truncate(issue.Description, 300)
-	if description == "" {
-		description = "*No description*"
+	if failed > 0 {
+		slog.WarnContext(ctx, "Webhook: Some destinations failed", "forwarded", forwarded, "failed", failed)
 	}
-
-	embed := DiscordEmbed{
-		Title:       fmt.Sprintf("%s %s", emoji, title),
-		Description: fmt.Sprintf("**[%s](%s)** - %s\n\n%s", issue.Identifier, issue.URL, issue.Title, description),
-		URL:         issue.URL,
-		Color:       color,
-		Timestamp:   time.Now().UTC().Format(time.RFC3339),
-		Fields:      []DiscordField{},
+	if forwarded == 0 && failed == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
 	}
 
-	if issue.State != nil {
-		embed.Fields = append(embed.Fields, DiscordField{
-			Name:   "Status",
-			Value:  fmt.Sprintf("%s %s", getStateEmoji(issue.State.Type), issue.State.Name),
-			Inline: true,
-		})
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "forwarded", "forwarded": forwarded, "failed": failed})
+}
 
-	if issue.PriorityLabel != "" {
-		embed.Fields = append(embed.Fields, DiscordField{
-			Name:   "Priority",
-			Value:  fmt.Sprintf("%s %s", getPriorityEmoji(issue.Priority), issue.PriorityLabel),
-			Inline: true,
-		})
+// webhookMaxAge bounds how far webhookTimestamp may be from now, so that a
+// captured delivery can't be replayed later.
+const webhookMaxAge = time.Minute
+
+// verifyLinearWebhook checks the Linear-Signature header, a hex HMAC-SHA256
+// of the raw body, against each secret, then the webhookTimestamp it covers.
+func verifyLinearWebhook(secrets []string, signature string, body []byte, now time.Time) error {
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return errors.New("missing or malformed Linear-Signature")
+	}
+	valid := false
+	for _, secret := range secrets {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		if hmac.Equal(got, mac.Sum(nil)) {
+			valid = true
+			break
+		}
 	}
-
-	if issue.Assignee != nil {
-		embed.Fields = append(embed.Fields, DiscordField{
-			Name:   "Assignee",
-			Value:  fmt.Sprintf("👤 %s", issue.Assignee.Name),
-			Inline: true,
-		})
+	if !valid {
+		return errors.New("signature mismatch")
 	}
 
-	if issue.Team != nil {
-		embed.Fields = append(embed.Fields, DiscordField{
-			Name:   "Team",
-			Value:  fmt.Sprintf("👥 %s", issue.Team.Name),
-			Inline: true,
-		})
+	var ts struct {
+		WebhookTimestamp int64 `json:"webhookTimestamp"`
 	}
-
-	if len(issue.Labels) > 0 {
-		labelNames := make([]string, len(issue.Labels))
-		for i, label := range issue.Labels {
-			labelNames[i] = fmt.Sprintf("`%s`", label.Name)
-		}
-		embed.Fields = append(embed.Fields, DiscordField{
-			Name:   "Labels",
-			Value:  strings.Join(labelNames, " "),
-			Inline: false,
-		})
+	if err := json.Unmarshal(body, &ts); err != nil || ts.WebhookTimestamp == 0 {
+		return errors.New("missing webhookTimestamp")
 	}
-
-	if webhook.Actor != nil {
-		embed.Footer = &DiscordFooter{Text: fmt.Sprintf("by %s", webhook.Actor.Name)}
+	if age := now.Sub(time.UnixMilli(ts.WebhookTimestamp)); age > webhookMaxAge || age < -webhookMaxAge {
+		return fmt.Errorf("webhookTimestamp is %s from now", age.Round(time.Second))
 	}
-
-	return &DiscordWebhook{
-		Username:  "Linear",
-		AvatarURL: linearAvatarURL,
-		Embeds:    []DiscordEmbed{embed},
-	}, nil
+	return nil
 }
 
-func transformCommentWebhook(webhook LinearWebhook) (*DiscordWebhook, error) {
-	var comment LinearWebhookComment
-	if err := json.Unmarshal(webhook.Data, &comment); err != nil {
-		return nil, fmt.Errorf("failed to parse comment data: %w", err)
+// webhookIssueIdentifier returns the identifier of the issue an issue or
+// comment webhook is about, e.g. "ENG-123", or "".
+func webhookIssueIdentifier(webhook LinearWebhook) string {
+	var data struct {
+		Identifier string `json:"identifier"`
+		Issue      *struct {
+			Identifier string `json:"identifier"`
+		} `json:"issue"`
 	}
-
-	var title, emoji string
-
-	switch webhook.Action {
-	case "create":
-		emoji = "💬"
-		title = "New Comment"
-	case "update":
-		emoji = "✏️"
-		title = "Comment Updated"
-	case "remove":
-		emoji = "🗑️"
-		title = "Comment Removed"
-	default:
-		emoji = "💬"
-		title = fmt.Sprintf("Comment %s", strings.Title(webhook.Action))
+	json.Unmarshal(webhook.Data, &data)
+	if data.Identifier == "" && data.Issue != nil {
+		return data.Issue.Identifier
 	}
+	return data.Identifier
+}
 
-	issueInfo := ""
-	if comment.Issue != nil {
-		issueInfo = fmt.Sprintf("**[%s](%s)** - %s", comment.Issue.Identifier, comment.Issue.URL, comment.Issue.Title)
+// transformWebhookToDiscord renders the webhook with the destination's
+// templates, filling in the payload from the workspace's metadata cache. It
+// returns nil when no template matches or the template drops it.
+func transformWebhookToDiscord(ctx context.Context, webhook LinearWebhook, dest *Destination, cache *MetadataCache) (payload *DiscordWebhook, err error) {
+	ctx, span := startSpan(ctx, "relay.transform", spanKindInternal, "relay.destination", dest.Name)
+	defer func() {
+		span.set("relay.filtered", err == nil && payload == nil)
+		span.end(err)
+	}()
+
+	tmpl := dest.templates.lookup(webhook.Type, webhook.Action)
+	if tmpl == nil {
+		slog.InfoContext(ctx, "Unhandled webhook type", "destination", dest.Name)
+		return nil, nil
 	}
 
-	embed := DiscordEmbed{
-		Title:       fmt.Sprintf("%s %s", emoji, title),
-		Description: fmt.Sprintf("%s\n\n>>> %s", issueInfo, truncate(comment.Body, 500)),
-		URL:         comment.URL,
-		Color:       ColorPurple,
-		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	tctx, err := newTemplateContext(webhook)
+	if err != nil {
+		return nil, err
 	}
-
-	if comment.User != nil {
-		embed.Author = &DiscordAuthor{Name: comment.User.Name}
+	tctx.Destination = dest.Name
+	cache.enrichIssue(ctx, tctx.Issue)
+	if tctx.Comment != nil {
+		cache.enrichIssue(ctx, tctx.Comment.Issue)
 	}
 
-	if webhook.Actor != nil {
-		embed.Footer = &DiscordFooter{Text: fmt.Sprintf("by %s", webhook.Actor.Name)}
+	embed, err := tmpl.render(tctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s template: %w", tmpl.key, err)
+	}
+	if embed == nil {
+		return nil, nil
 	}
 
 	return &DiscordWebhook{
 		Username:  "Linear",
 		AvatarURL: linearAvatarURL,
-		Embeds:    []DiscordEmbed{embed},
+		Embeds:    []DiscordEmbed{*embed},
 	}, nil
 }
 
-func transformProjectWebhook(webhook LinearWebhook) (*DiscordWebhook, error) {
-	var project LinearWebhookProject
-	if err := json.Unmarshal(webhook.Data, &project); err != nil {
-		return nil, fmt.Errorf("failed to parse project data: %w", err)
-	}
-
-	var title, emoji string
-	color := ColorBlue
-
-	switch webhook.Action {
-	case "create":
-		emoji = "🚀"
-		title = "New Project Created"
-		color = ColorGreen
-	case "update":
-		emoji = "📊"
-		title = "Project Updated"
-		color = ColorYellow
-	case "remove":
-		emoji = "🗑️"
-		title = "Project Removed"
-		color = ColorRed
-	default:
-		emoji = "📁"
-		title = fmt.Sprintf("Project %s", strings.Title(webhook.Action))
-	}
+// ============================================================================
+// DAILY DIGEST
+// ============================================================================
 
-	description := truncate(project.Description, 300)
-	if description == "" {
-		description = "*No description*"
-	}
+// ReportOptions selects the issues a report covers and where it is posted.
+type ReportOptions struct {
+	Destination *Destination
+	Filter      *IssueFilter
+	// Preview collects the messages instead of sending them (dry run).
+	Preview *Preview
+	// Workspace is the installed workspace the report covers; nil for the
+	// LINEAR_API_KEY one.
+	Workspace *Workspace
+
+	// ctx carries the request or job the report runs for into its log lines.
+	ctx context.Context
+}
 
-	embed := DiscordEmbed{
-		Title:       fmt.Sprintf("%s %s", emoji, title),
-		Description: fmt.Sprintf("**%s**\n\n%s", project.Name, description),
-		URL:         project.URL,
-		Color:       color,
-		Timestamp:   time.Now().UTC().Format(time.RFC3339),
-		Fields:      []DiscordField{},
+func (o ReportOptions) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
 	}
+	return o.ctx
+}
 
-	if project.State != "" {
-		embed.Fields = append(embed.Fields, DiscordField{
-			Name:   "State",
-			Value:  project.State,
-			Inline: true,
-		})
+// linear returns the client for the report's workspace.
+func (o ReportOptions) linear() *linear.Client {
+	if o.Workspace != nil {
+		return o.Workspace.client
 	}
+	return linearClient
+}
 
-	if webhook.Actor != nil {
-		embed.Footer = &DiscordFooter{Text: fmt.Sprintf("by %s", webhook.Actor.Name)}
+func (o ReportOptions) send(payload *DiscordWebhook) error {
+	if o.Preview != nil {
+		o.Preview.add(o.destinationName(), payload)
+		return nil
 	}
-
-	return &DiscordWebhook{
-		Username:  "Linear",
-		AvatarURL: linearAvatarURL,
-		Embeds:    []DiscordEmbed{embed},
-	}, nil
+	if o.Destination == nil {
+		if o.Workspace != nil {
+			// Never post a workspace's issues to the host's channels.
+			return fmt.Errorf("workspace %q has no destination", o.Workspace.Key)
+		}
+		return sendToDiscord(o.context(), payload)
+	}
+	return sendToWebhook(o.context(), o.Destination, payload)
 }
 
-// ============================================================================
-// DAILY DIGEST
-// ============================================================================
+func (o ReportOptions) destinationName() string {
+	if o.Destination == nil {
+		return defaultDestination
+	}
+	return o.Destination.Name
+}
 
 func handleReport(w http.ResponseWriter, r *http.Request) {
-	if linearAPIKey == "" {
-		http.Error(w, "LINEAR_API_KEY not configured", http.StatusServiceUnavailable)
+	opts, ok := reportOptions(w, r)
+	if !ok {
 		return
 	}
-
-	if err := generateAndSendReport(); err != nil {
-		log.Printf("Error generating report: %v", err)
+	if err := generateAndSendReport(opts); err != nil {
+		slog.ErrorContext(opts.context(), "Error generating report", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if opts.dryRun() {
+		writePreview(w, r, opts.Preview)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "report_sent"})
 }
 
-func generateAndSendReport() error {
-	log.Println("Fetching issues from Linear...")
-	issues, err := fetchAllOpenIssues()
+func generateAndSendReport(opts ReportOptions) error {
+	slog.InfoContext(opts.context(), "Fetching issues from Linear...")
+	issues, err := fetchAllOpenIssues(opts)
 	if err != nil {
 		return fmt.Errorf("failed to fetch issues: %w", err)
 	}
 
-	log.Printf("Fetched %d open issues", len(issues))
+	slog.InfoContext(opts.context(), "Fetched open issues", "count", len(issues))
+	opts.stat("open_issues", len(issues))
 
-	if len(issues) == 0 {
-		return sendNoIssuesReport()
+	// Only the digest keeps snapshots: they are its baselines and history
+	// chart, and other reports would write one on every run.
+	if !opts.dryRun() {
+		if err := saveSnapshot(opts, issues); err != nil {
+			slog.WarnContext(opts.context(), "Snapshots: Could not save snapshot", "error", err)
+		}
 	}
 
-	byStatus := groupByStatus(issues)
-	byAssignee := groupByAssignee(issues)
-
-	return sendReport(issues, byStatus, byAssignee)
-}
-
-func fetchAllOpenIssues() ([]Issue, error) {
-	var allIssues []Issue
-	var cursor string
+	now := time.Now()
+	diff := digestDiff(opts, issues, now)
+	if diff != nil {
+		opts.stat("baseline", diff.Since)
+		opts.stat("opened", len(diff.Opened))
+		opts.stat("closed", len(diff.Completed)+len(diff.Canceled))
+	}
 
-	query := `
-		query($cursor: String) {
-			issues(
-				filter: {
-					state: { type: { nin: ["completed", "canceled"] } }
-				}
-				first: 100
-				after: $cursor
-				orderBy: updatedAt
-			) {
-				nodes {
-					id
-					identifier
-					title
-					priority
-					priorityLabel
-					url
-					createdAt
-					updatedAt
-					state {
-						id
-						name
-						color
-						type
-					}
-					assignee {
-						id
-						name
-						displayName
-						email
-					}
-					team {
-						id
-						name
-						key
-					}
-					labels {
-						nodes {
-							id
-							name
-							color
-						}
-					}
-				}
-				pageInfo {
-					hasNextPage
-					endCursor
-				}
+	var byStatus []StatusGroup
+	if len(issues) == 0 {
+		err = sendNoIssuesReport(opts)
+	} else {
+		byStatus = groupByStatus(issues)
+		byAssignee := groupByAssignee(issues)
+		if opts.dryRun() {
+			statusCounts, assigneeCounts := map[string]int{}, map[string]int{}
+			for _, group := range byStatus {
+				statusCounts[group.Name] = len(group.Issues)
 			}
+			for _, group := range byAssignee {
+				assigneeCounts[group.Name] = len(group.Issues)
+			}
+			opts.stat("by_status", statusCounts)
+			opts.stat("by_assignee", assigneeCounts)
 		}
-	`
-
-	for {
-		variables := map[string]interface{}{}
-		if cursor != "" {
-			variables["cursor"] = cursor
-		}
-
-		resp, err := executeGraphQL(query, variables)
-		if err != nil {
-			return nil, err
-		}
-
-		var issuesResp IssuesResponse
-		if err := json.Unmarshal(resp, &issuesResp); err != nil {
-			return nil, fmt.Errorf("failed to parse issues response: %w", err)
-		}
-
-		allIssues = append(allIssues, issuesResp.Issues.Nodes...)
-
-		if !issuesResp.Issues.PageInfo.HasNextPage {
-			break
-		}
-		cursor = issuesResp.Issues.PageInfo.EndCursor
+		err = sendReport(opts, issues, byStatus, byAssignee, diff)
 	}
-
-	return allIssues, nil
-}
-
-func executeGraphQL(query string, variables map[string]interface{}) (json.RawMessage, error) {
-	reqBody := GraphQLRequest{Query: query, Variables: variables}
-
-	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return err
 	}
-
-	req, err := http.NewRequest("POST", linearAPIURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	if !opts.dryRun() {
+		recordDigestIssues(opts.destinationName(), byStatus)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", linearAPIKey)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+	if store != nil && !opts.dryRun() {
+		if err := markDigest(opts, now); err != nil {
+			slog.WarnContext(opts.context(), "Snapshots: Could not record digest baseline", "error", err)
+		}
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+func fetchAllOpenIssues(opts ReportOptions) ([]Issue, error) {
+	notClosed := object("state", object("type", object("nin", []string{"completed", "canceled"})))
+	allIssues, err := opts.linear().Issues(opts.context(), linear.IssuesOptions{
+		Filter:  issueFilterVariable(notClosed, opts.Filter),
+		OrderBy: "updatedAt",
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("linear API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var graphResp GraphQLResponse
-	if err := json.Unmarshal(body, &graphResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	if len(graphResp.Errors) > 0 {
-		return nil, fmt.Errorf("GraphQL errors: %v", graphResp.Errors)
+		return nil, err
 	}
-
-	return graphResp.Data, nil
+	return allIssues, nil
 }
 
 type StatusGroup struct {
@@ -738,15 +773,7 @@ func groupByAssignee(issues []Issue) []AssigneeGroup {
 	groups := make(map[string]*AssigneeGroup)
 
 	for _, issue := range issues {
-		var name string
-		if issue.Assignee != nil {
-			name = issue.Assignee.Name
-			if issue.Assignee.DisplayName != "" {
-				name = issue.Assignee.DisplayName
-			}
-		} else {
-			name = "Unassigned"
-		}
+		name := assigneeName(issue.Assignee)
 
 		if groups[name] == nil {
 			groups[name] = &AssigneeGroup{Name: name, Issues: []Issue{}}
@@ -772,7 +799,18 @@ func groupByAssignee(issues []Issue) []AssigneeGroup {
 	return result
 }
 
-func sendNoIssuesReport() error {
+// assigneeName is the name reports group a user under.
+func assigneeName(user *User) string {
+	if user == nil {
+		return "Unassigned"
+	}
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return user.Name
+}
+
+func sendNoIssuesReport(opts ReportOptions) error {
 	embed := DiscordEmbed{
 		Title:       "📊 Linear Daily Digest",
 		Description: "No open issues found. Great job keeping the backlog clean! 🎉",
@@ -781,36 +819,81 @@ func sendNoIssuesReport() error {
 		Footer:      &DiscordFooter{Text: "Linear Daily Digest"},
 	}
 
-	return sendToDiscord(&DiscordWebhook{
+	return opts.send(&DiscordWebhook{
 		Username:  "Linear Daily Digest",
 		AvatarURL: linearAvatarURL,
 		Embeds:    []DiscordEmbed{embed},
 	})
 }
 
-func sendReport(issues []Issue, byStatus []StatusGroup, byAssignee []AssigneeGroup) error {
+func sendReport(opts ReportOptions, issues []Issue, byStatus []StatusGroup, byAssignee []AssigneeGroup, diff *SnapshotDiff) error {
 	urgentCount := 0
 	highCount := 0
+	priorityCounts := map[int]int{}
+	priorityLabels := map[int]string{}
 	for _, issue := range issues {
 		if issue.Priority == 1 {
 			urgentCount++
 		} else if issue.Priority == 2 {
 			highCount++
 		}
+		priorityCounts[issue.Priority]++
+		priorityLabels[issue.Priority] = issue.PriorityLabel
 	}
 
+	// Deltas since the last report; nil maps (no baseline) render as "".
+	var statusDelta, assigneeDelta, priorityDelta map[string]int
+	if diff != nil {
+		statusDelta, assigneeDelta, priorityDelta = diff.Status, diff.Assignee, diff.Priority
+	}
+	delta := func(counts map[string]int, key string) string {
+		if counts == nil {
+			return ""
+		}
+		return formatDelta(counts[key])
+	}
+
+	// Groups that lost all their issues since the baseline are listed with 0.
 	var statusLines []string
+	shownStatuses := map[string]bool{}
 	for _, group := range byStatus {
-		statusLines = append(statusLines, fmt.Sprintf("%s **%s**: %d", getStateEmoji(group.Type), group.Name, len(group.Issues)))
+		statusLines = append(statusLines, fmt.Sprintf("%s **%s**: %d%s", getStateEmoji(group.Type), group.Name, len(group.Issues),
+			delta(statusDelta, group.Name)))
+		shownStatuses[group.Name] = true
+	}
+	for _, name := range droppedToZero(statusDelta, shownStatuses) {
+		statusLines = append(statusLines, fmt.Sprintf("%s **%s**: 0%s", getStateEmoji(diff.StateTypes[name]), name,
+			delta(statusDelta, name)))
 	}
 
+	assigneeEmoji := func(name string) string {
+		if name == "Unassigned" {
+			return "❓"
+		}
+		return "👤"
+	}
 	var assigneeLines []string
+	shownAssignees := map[string]bool{}
 	for _, group := range byAssignee {
-		emoji := "👤"
-		if group.Name == "Unassigned" {
-			emoji = "❓"
+		assigneeLines = append(assigneeLines, fmt.Sprintf("%s **%s**: %d%s", assigneeEmoji(group.Name), group.Name, len(group.Issues),
+			delta(assigneeDelta, group.Name)))
+		shownAssignees[group.Name] = true
+	}
+	for _, name := range droppedToZero(assigneeDelta, shownAssignees) {
+		assigneeLines = append(assigneeLines, fmt.Sprintf("%s **%s**: 0%s", assigneeEmoji(name), name, delta(assigneeDelta, name)))
+	}
+
+	var priorityLines []string
+	for _, priority := range []int{1, 2, 3, 4, 0} {
+		label := priorityLabels[priority]
+		if priorityCounts[priority] == 0 {
+			if diff == nil || diff.Priority[diff.PriorityLabels[priority]] >= 0 {
+				continue
+			}
+			label = diff.PriorityLabels[priority]
 		}
-		assigneeLines = append(assigneeLines, fmt.Sprintf("%s **%s**: %d", emoji, group.Name, len(group.Issues)))
+		priorityLines = append(priorityLines, fmt.Sprintf("%s **%s**: %d%s", getPriorityEmoji(priority), label,
+			priorityCounts[priority], delta(priorityDelta, label)))
 	}
 
 	var priorityAlerts []string
@@ -833,15 +916,28 @@ func sendReport(issues []Issue, byStatus []StatusGroup, byAssignee []AssigneeGro
 	if len(priorityAlerts) > 0 {
 		summaryParts = append(summaryParts, strings.Join(priorityAlerts, " | "))
 	}
+	if diff != nil {
+		summaryParts = append(summaryParts, fmt.Sprintf("📈 **+%d** opened, **−%d** closed since %s",
+			len(diff.Opened), len(diff.Completed)+len(diff.Canceled), diff.Since.UTC().Format("Mon Jan 2 15:04 MST")))
+	}
 	mainEmbed.Description = strings.Join(summaryParts, "\n")
 
 	mainEmbed.Fields = append(mainEmbed.Fields,
 		DiscordField{Name: "📋 By Status", Value: strings.Join(statusLines, "\n"), Inline: true},
 		DiscordField{Name: "👥 By Assignee", Value: strings.Join(assigneeLines, "\n"), Inline: true},
+		DiscordField{Name: "🎚️ By Priority", Value: strings.Join(priorityLines, "\n"), Inline: true},
 	)
 
 	embeds := []DiscordEmbed{mainEmbed}
 
+	if changes := formatSnapshotChanges(diff); changes != "" {
+		embeds = append(embeds, DiscordEmbed{
+			Title:       "🔀 Since Last Report",
+			Description: changes,
+			Color:       ColorPurple,
+		})
+	}
+
 	if urgentCount > 0 || highCount > 0 {
 		var priorityIssues []string
 		count := 0
@@ -870,6 +966,14 @@ func sendReport(issues []Issue, byStatus []StatusGroup, byAssignee []AssigneeGro
 		}
 	}
 
+	if overdue := formatOverdue(issues, time.Now()); overdue != "" {
+		embeds = append(embeds, DiscordEmbed{
+			Title:       "⏰ Overdue",
+			Description: overdue,
+			Color:       ColorRed,
+		})
+	}
+
 	today := time.Now().Truncate(24 * time.Hour)
 	var recentIssues []string
 	for _, issue := range issues {
@@ -887,11 +991,15 @@ func sendReport(issues []Issue, byStatus []StatusGroup, byAssignee []AssigneeGro
 		})
 	}
 
-	return sendToDiscord(&DiscordWebhook{
+	payload := &DiscordWebhook{
 		Username:  "Linear Daily Digest",
 		AvatarURL: linearAvatarURL,
 		Embeds:    embeds,
-	})
+	}
+	if appConfig.chartsEnabled() {
+		attachDigestCharts(payload, opts, issues, time.Now())
+	}
+	return opts.send(payload)
 }
 
 // ============================================================================
@@ -899,33 +1007,38 @@ func sendReport(issues []Issue, byStatus []StatusGroup, byAssignee []AssigneeGro
 // ============================================================================
 
 func handleReportByUser(w http.ResponseWriter, r *http.Request) {
-	if linearAPIKey == "" {
-		http.Error(w, "LINEAR_API_KEY not configured", http.StatusServiceUnavailable)
+	opts, ok := reportOptions(w, r)
+	if !ok {
 		return
 	}
-
-	if err := generateUserTasksReport(); err != nil {
-		log.Printf("Error generating user report: %v", err)
+	if err := generateUserTasksReport(opts); err != nil {
+		slog.ErrorContext(opts.context(), "Error generating user report", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if opts.dryRun() {
+		writePreview(w, r, opts.Preview)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "user_report_sent"})
 }
 
-func generateUserTasksReport() error {
-	log.Println("Fetching issues for per-user report...")
-	issues, err := fetchAllOpenIssues()
+func generateUserTasksReport(opts ReportOptions) error {
+	slog.InfoContext(opts.context(), "Fetching issues for per-user report...")
+	issues, err := fetchAllOpenIssues(opts)
 	if err != nil {
 		return fmt.Errorf("failed to fetch issues: %w", err)
 	}
 
 	if len(issues) == 0 {
-		return sendNoIssuesReport()
+		return sendNoIssuesReport(opts)
 	}
 
 	byAssignee := groupByAssignee(issues)
+	opts.stat("open_issues", len(issues))
+	opts.stat("assignees", len(byAssignee))
 
 	// Send one embed per user (Discord limit: 10 embeds per message)
 	var embeds []DiscordEmbed
@@ -964,7 +1077,7 @@ func generateUserTasksReport() error {
 
 		// Discord limit: 10 embeds per message, send in batches
 		if len(embeds) >= 10 {
-			if err := sendToDiscord(&DiscordWebhook{
+			if err := opts.send(&DiscordWebhook{
 				Username:  "Linear Task Report",
 				AvatarURL: linearAvatarURL,
 				Embeds:    embeds,
@@ -978,7 +1091,7 @@ func generateUserTasksReport() error {
 
 	// Send remaining embeds
 	if len(embeds) > 0 {
-		return sendToDiscord(&DiscordWebhook{
+		return opts.send(&DiscordWebhook{
 			Username:  "Linear Task Report",
 			AvatarURL: linearAvatarURL,
 			Embeds:    embeds,
@@ -989,89 +1102,105 @@ func generateUserTasksReport() error {
 }
 
 // ============================================================================
-// INTERNAL SCHEDULER (9 AM UTC, Mon-Fri)
+// HELPERS
 // ============================================================================
 
-func startDailyScheduler() {
-	if linearAPIKey == "" {
-		log.Println("Scheduler: LINEAR_API_KEY not set, daily reports disabled")
-		return
-	}
-
-	log.Println("Scheduler: Started daily report scheduler (9:00 AM UTC, Mon-Fri)")
-
-	for {
-		now := time.Now().UTC()
-		next := nextScheduledTime(now)
-		duration := next.Sub(now)
-
-		log.Printf("Scheduler: Next report at %s (in %s)", next.Format(time.RFC3339), duration.Round(time.Minute))
-
-		time.Sleep(duration)
+func sendToDiscord(ctx context.Context, payload *DiscordWebhook) error {
+	return sendToWebhook(ctx, appConfig.destination(defaultDestination), payload)
+}
 
-		// Double-check it's a weekday (in case of drift)
-		if isWeekday(time.Now().UTC()) {
-			log.Println("Scheduler: Triggering daily per-user report")
-			if err := generateUserTasksReport(); err != nil {
-				log.Printf("Scheduler: Error generating report: %v", err)
-			} else {
-				log.Println("Scheduler: Daily report sent successfully")
-			}
-		}
+// sendToWebhook posts a message to a destination's webhook. During shutdown,
+// messages that can't be sent before the drain deadline are spooled to the
+// outbox instead.
+func sendToWebhook(ctx context.Context, dest *Destination, payload *DiscordWebhook) (err error) {
+	if spooling() {
+		return spool(ctx, &OutboxMessage{Destination: dest.Name, Payload: payload})
 	}
-}
+	body, contentType, err := encodeDiscordPayload(payload)
+	if err != nil {
+		return err
+	}
+	sendCtx, cancel := deliveryContext(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(sendCtx, http.MethodPost, dest.WebhookURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to create discord request: %w", redactURLError(err))
+	}
+	req.Header.Set("Content-Type", contentType)
 
-func nextScheduledTime(now time.Time) time.Time {
-	// Target: 9:00 AM UTC
-	targetHour := 9
-	targetMinute := 0
+	slog.InfoContext(ctx, "Sending to Discord", "embeds", len(payload.Embeds), "files", len(payload.Files))
+	outboxDepth.Add(1)
+	defer outboxDepth.Add(-1)
 
-	// Start with today at 9 AM
-	next := time.Date(now.Year(), now.Month(), now.Day(), targetHour, targetMinute, 0, 0, time.UTC)
+	_, span := startSpan(ctx, "discord.send", spanKindClient, "discord.embeds", len(payload.Embeds), "discord.files", len(payload.Files))
+	defer func() { span.end(err) }()
 
-	// If we've passed today's time, move to tomorrow
-	if now.After(next) {
-		next = next.Add(24 * time.Hour)
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	observeDiscordRequest("webhook", start, resp)
+	if resp != nil {
+		span.set("http.response.status_code", resp.StatusCode)
 	}
-
-	// Skip weekends (Saturday=6, Sunday=0)
-	for !isWeekday(next) {
-		next = next.Add(24 * time.Hour)
+	if err != nil {
+		if spooling() && interrupted(err) {
+			return spool(ctx, &OutboxMessage{Destination: dest.Name, Payload: payload})
+		}
+		return fmt.Errorf("failed to send to discord: %w", redactURLError(err))
 	}
+	defer resp.Body.Close()
 
-	return next
-}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("discord returned status %d: %s", resp.StatusCode, string(body))
+	}
 
-func isWeekday(t time.Time) bool {
-	day := t.Weekday()
-	return day >= time.Monday && day <= time.Friday
+	slog.InfoContext(ctx, "Successfully sent to Discord", "status", resp.StatusCode)
+	return nil
 }
 
-// ============================================================================
-// HELPERS
-// ============================================================================
-
-func sendToDiscord(payload *DiscordWebhook) error {
+// encodeDiscordPayload returns the request body and its content type: plain
+// JSON, or multipart/form-data with the JSON in "payload_json" when the
+// message has files.
+func encodeDiscordPayload(payload *DiscordWebhook) (io.Reader, string, error) {
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal discord payload: %w", err)
+		return nil, "", fmt.Errorf("failed to marshal discord payload: %w", err)
+	}
+	if len(payload.Files) == 0 {
+		return bytes.NewReader(jsonData), "application/json", nil
 	}
 
-	log.Printf("Sending to Discord: %d embeds", len(payload.Embeds))
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
 
-	resp, err := http.Post(discordWebhookURL, "application/json", bytes.NewBuffer(jsonData))
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="payload_json"`)
+	header.Set("Content-Type", "application/json")
+	part, err := mw.CreatePart(header)
 	if err != nil {
-		return fmt.Errorf("failed to send to discord: %w", err)
+		return nil, "", fmt.Errorf("failed to encode discord payload: %w", err)
 	}
-	defer resp.Body.Close()
+	part.Write(jsonData)
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("discord returned status %d: %s", resp.StatusCode, string(body))
+	for i, file := range payload.Files {
+		contentType := mime.TypeByExtension(filepath.Ext(file.Name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[%d]"; filename=%q`, i, file.Name))
+		header.Set("Content-Type", contentType)
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode discord payload: %w", err)
+		}
+		part.Write(file.Data)
 	}
 
-	log.Printf("Successfully sent to Discord (status: %d)", resp.StatusCode)
-	return nil
+	if err := mw.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to encode discord payload: %w", err)
+	}
+	return &buf, mw.FormDataContentType(), nil
 }
 
 func truncate(s string, maxLen int) string {
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// ============================================================================
+// WORKSPACE METADATA
+// ============================================================================
+
+// defaultMetadataTTL is how long cached teams, users, states and labels are
+// used before they are fetched again.
+const defaultMetadataTTL = time.Hour
+
+// metadataMissInterval limits how often a lookup of an unknown ID (a team or
+// label created since the last load) refreshes the cache early.
+const metadataMissInterval = time.Minute
+
+// metadataLoadTimeout bounds a background reload, which no request waits for.
+const metadataLoadTimeout = time.Minute
+
+// MetadataCache is a cache of the workspace's teams, users, workflow states
+// and labels, keyed by ID. It is loaded when it is created and reloaded as a
+// whole in the background when the TTL expires, when a lookup misses, or when
+// a webhook reports a change. Lookups never wait for a reload: they are
+// answered from the data already loaded, stale or not.
+type MetadataCache struct {
+	client *linear.Client
+	ttl    time.Duration
+
+	// refresh is held by the running reload, so there is at most one.
+	refresh sync.Mutex
+
+	mu          sync.RWMutex
+	teams       map[string]Team
+	users       map[string]User
+	states      map[string]State
+	labels      map[string]Label
+	loadedAt    time.Time
+	lastAttempt time.Time
+}
+
+// metadata caches the LINEAR_API_KEY workspace; installed workspaces have
+// their own.
+var metadata = newMetadataCache(nil, defaultMetadataTTL)
+
+// newMetadataCache returns a cache loaded through client. Without a client
+// every lookup misses.
+func newMetadataCache(client *linear.Client, ttl time.Duration) *MetadataCache {
+	return &MetadataCache{client: client, ttl: ttl}
+}
+
+func (m *MetadataCache) team(ctx context.Context, id string) (Team, bool) {
+	m.ensure(ctx, func() bool { _, ok := m.teams[id]; return ok })
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.teams[id]
+	return t, ok
+}
+
+func (m *MetadataCache) user(ctx context.Context, id string) (User, bool) {
+	m.ensure(ctx, func() bool { _, ok := m.users[id]; return ok })
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.users[id]
+	return u, ok
+}
+
+func (m *MetadataCache) state(ctx context.Context, id string) (State, bool) {
+	m.ensure(ctx, func() bool { _, ok := m.states[id]; return ok })
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.states[id]
+	return s, ok
+}
+
+func (m *MetadataCache) label(ctx context.Context, id string) (Label, bool) {
+	m.ensure(ctx, func() bool { _, ok := m.labels[id]; return ok })
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	l, ok := m.labels[id]
+	return l, ok
+}
+
+// ensure starts a reload when the cache is stale or has reported a miss.
+// Reloads are at most once per metadataMissInterval unless the cache was
+// invalidated, and a failed one keeps the old data.
+func (m *MetadataCache) ensure(ctx context.Context, has func() bool) {
+	if m.client == nil || !m.needsLoad(has) {
+		return
+	}
+	m.reload(ctx)
+}
+
+// warm starts the first load, so that webhooks find the cache filled.
+func (m *MetadataCache) warm() {
+	if m.client == nil {
+		return
+	}
+	m.reload(context.Background())
+}
+
+// reload loads the cache in the background unless a reload is already
+// running. It keeps ctx's trace but not its cancellation, since the request
+// that noticed the cache was stale doesn't wait for it.
+func (m *MetadataCache) reload(ctx context.Context) {
+	if !m.refresh.TryLock() {
+		return
+	}
+	go func() {
+		defer m.refresh.Unlock()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), metadataLoadTimeout)
+		defer cancel()
+		if err := m.load(ctx); err != nil {
+			slog.WarnContext(ctx, "Metadata: Could not load workspace metadata", "error", err)
+		}
+	}()
+}
+
+func (m *MetadataCache) needsLoad(has func() bool) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	stale := m.loadedAt.IsZero() || now.Sub(m.loadedAt) >= m.ttl
+	if !stale && has() {
+		return false
+	}
+	return now.Sub(m.lastAttempt) >= metadataMissInterval
+}
+
+func (m *MetadataCache) load(ctx context.Context) error {
+	m.mu.Lock()
+	m.lastAttempt = time.Now()
+	m.mu.Unlock()
+
+	teams, err := m.client.Teams(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch teams: %w", err)
+	}
+	users, err := m.client.Users(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch users: %w", err)
+	}
+	states, err := m.client.WorkflowStates(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch workflow states: %w", err)
+	}
+	labels, err := m.client.Labels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch labels: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.teams = make(map[string]Team, len(teams))
+	for _, t := range teams {
+		m.teams[t.ID] = t
+	}
+	m.users = make(map[string]User, len(users))
+	for _, u := range users {
+		m.users[u.ID] = u
+	}
+	m.states = make(map[string]State, len(states))
+	for _, s := range states {
+		m.states[s.ID] = s
+	}
+	m.labels = make(map[string]Label, len(labels))
+	for _, l := range labels {
+		m.labels[l.ID] = l
+	}
+	m.loadedAt = time.Now()
+
+	slog.InfoContext(ctx, "Metadata: Loaded workspace metadata", "teams", len(teams), "users", len(users), "states", len(states), "labels", len(labels))
+	return nil
+}
+
+// invalidate makes the next lookup reload the cache.
+func (m *MetadataCache) invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loadedAt = time.Time{}
+	m.lastAttempt = time.Time{}
+}
+
+// observe keeps the cache in step with webhooks: label events are applied
+// directly, changes to teams, users and workflow states invalidate it.
+func (m *MetadataCache) observe(webhook LinearWebhook) {
+	switch webhook.Type {
+	case "IssueLabel":
+		var label Label
+		if err := json.Unmarshal(webhook.Data, &label); err != nil || label.ID == "" {
+			m.invalidate()
+			return
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.labels == nil {
+			return
+		}
+		if webhook.Action == "remove" {
+			delete(m.labels, label.ID)
+		} else {
+			m.labels[label.ID] = label
+		}
+	case "Team", "User", "WorkflowState":
+		m.invalidate()
+	}
+}
+
+// MetadataStats is the cache's published state.
+type MetadataStats struct {
+	Teams    int       `json:"teams"`
+	Users    int       `json:"users"`
+	States   int       `json:"states"`
+	Labels   int       `json:"labels"`
+	LoadedAt time.Time `json:"loaded_at"`
+	TTL      string    `json:"ttl"`
+}
+
+func (m *MetadataCache) stats() MetadataStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return MetadataStats{
+		Teams:    len(m.teams),
+		Users:    len(m.users),
+		States:   len(m.states),
+		Labels:   len(m.labels),
+		LoadedAt: m.loadedAt,
+		TTL:      m.ttl.String(),
+	}
+}
+
+// enrichIssue fills in what Linear leaves out of webhook payloads: objects
+// that are only referenced by ID (stateId, teamId, assigneeId, labelIds) and
+// fields missing from partial nested objects.
+func (m *MetadataCache) enrichIssue(ctx context.Context, issue *LinearWebhookIssue) {
+	if issue == nil {
+		return
+	}
+
+	stateID := issue.StateID
+	if issue.State != nil {
+		stateID = issue.State.ID
+	}
+	if stateID != "" && (issue.State == nil || issue.State.Name == "" || issue.State.Color == "" || issue.State.Type == "") {
+		if s, ok := m.state(ctx, stateID); ok {
+			issue.State = &s
+		}
+	}
+
+	teamID := issue.TeamID
+	if issue.Team != nil {
+		teamID = issue.Team.ID
+	}
+	if teamID != "" && (issue.Team == nil || issue.Team.Name == "" || issue.Team.Key == "") {
+		if t, ok := m.team(ctx, teamID); ok {
+			issue.Team = &t
+		}
+	}
+
+	if issue.Assignee == nil && issue.AssigneeID != "" {
+		if u, ok := m.user(ctx, issue.AssigneeID); ok {
+			issue.Assignee = &u
+		}
+	}
+
+	if len(issue.Labels) == 0 {
+		for _, id := range issue.LabelIDs {
+			if l, ok := m.label(ctx, id); ok {
+				issue.Labels = append(issue.Labels, l)
+			}
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// ============================================================================
+// METRICS (Prometheus text format)
+// ============================================================================
+
+// A small stdlib implementation of counters, gauges and histograms with
+// labels, written in the Prometheus text exposition format on /metrics.
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var metricsRegistry []metric
+
+// metricVec holds one value per label combination.
+type metricVec struct {
+	name, help, kind string
+	labels           []string
+
+	mu     sync.Mutex
+	series map[string][]string // key → label values
+	values map[string]float64
+}
+
+func newMetricVec(kind, name, help string, labels ...string) *metricVec {
+	m := &metricVec{
+		name: name, help: help, kind: kind, labels: labels,
+		series: map[string][]string{},
+		values: map[string]float64{},
+	}
+	metricsRegistry = append(metricsRegistry, m)
+	return m
+}
+
+func newCounter(name, help string, labels ...string) *metricVec {
+	return newMetricVec("counter", name, help, labels...)
+}
+
+func newGauge(name, help string, labels ...string) *metricVec {
+	return newMetricVec("gauge", name, help, labels...)
+}
+
+func (m *metricVec) add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series[key] = labelValues
+	m.values[key] += v
+}
+
+func (m *metricVec) inc(labelValues ...string) {
+	m.add(1, labelValues...)
+}
+
+func (m *metricVec) set(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series[key] = labelValues
+	m.values[key] = v
+}
+
+// deleteWhere drops the series whose label values match.
+func (m *metricVec) deleteWhere(match func(labelValues []string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, values := range m.series {
+		if match(values) {
+			delete(m.series, key)
+			delete(m.values, key)
+		}
+	}
+}
+
+func (m *metricVec) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeHeader(w, m.name, m.help, m.kind)
+	for _, key := range sortedKeys(m.series) {
+		fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(m.labels, m.series[key]), formatValue(m.values[key]))
+	}
+}
+
+// histogramVec counts observations into cumulative buckets per label
+// combination.
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+// latencyBuckets suit HTTP calls to Discord and Linear, in seconds.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *histogramVec {
+	h := &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogram{}}
+	metricsRegistry = append(metricsRegistry, h)
+	return h
+}
+
+func (h *histogramVec) observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[key]
+	if s == nil {
+		s = &histogram{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	labels := append(append([]string{}, h.labels...), "le")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		for i, bound := range h.buckets {
+			values := append(append([]string{}, s.labelValues...), formatValue(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(labels, values), s.counts[i])
+		}
+		values := append(append([]string{}, s.labelValues...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(labels, values), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.labelValues), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.labelValues), s.count)
+	}
+}
+
+// metricFunc is a counter or gauge read from elsewhere on every scrape.
+type metricFunc struct {
+	name, help, kind string
+	labels           []string
+	collect          func(emit func(v float64, labelValues ...string))
+}
+
+func newMetricFunc(kind, name, help string, labels []string, collect func(emit func(v float64, labelValues ...string))) {
+	metricsRegistry = append(metricsRegistry, &metricFunc{name: name, help: help, kind: kind, labels: labels, collect: collect})
+}
+
+func (g *metricFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, g.kind)
+	g.collect(func(v float64, labelValues ...string) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labels, labelValues), formatValue(v))
+	})
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = name + `="` + labelEscaper.Replace(value) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for _, m := range metricsRegistry {
+		m.write(bw)
+	}
+	bw.Flush()
+}
+
+// ----------------------------------------------------------------------------
+// Relay metrics
+// ----------------------------------------------------------------------------
+
+var (
+	webhooksReceived = newCounter("relay_webhooks_received_total",
+		"Linear webhooks received, by event type and action.", "type", "action")
+	webhookDeliveries = newCounter("relay_webhook_deliveries_total",
+		"Linear webhook events per destination by result: relayed, filtered (no template or dropped by it) or failed.",
+		"destination", "result")
+
+	discordLatency = newHistogram("relay_discord_request_duration_seconds",
+		"Latency of Discord requests, for webhooks and the bot API.", latencyBuckets, "target")
+	discordResponses = newCounter("relay_discord_responses_total",
+		"Discord responses by status code (\"error\" when no response was received).", "target", "code")
+
+	linearLatency = newHistogram("relay_linear_request_duration_seconds",
+		"Latency of Linear GraphQL requests by top-level field.", latencyBuckets, "operation")
+	linearErrors = newCounter("relay_linear_request_errors_total",
+		"Failed Linear GraphQL requests by top-level field and error code.", "operation", "code")
+
+	digestIssues = newGauge("relay_digest_issues",
+		"Open issues per status in the last digest sent to each destination.", "destination", "status")
+)
+
+// linearWebhookTypes and linearWebhookActions are the values
+// relay_webhooks_received_total is labelled with. The webhook body is
+// untrusted, so anything else is counted as "other" to bound the series.
+var (
+	linearWebhookTypes = map[string]bool{
+		"Issue": true, "Comment": true, "IssueLabel": true, "Reaction": true,
+		"Project": true, "ProjectUpdate": true, "Cycle": true, "Attachment": true,
+		"Document": true, "Initiative": true, "InitiativeUpdate": true,
+		"Customer": true, "CustomerNeed": true, "IssueSLA": true,
+		"Team": true, "User": true, "WorkflowState": true, "OAuthApp": true,
+	}
+	linearWebhookActions = map[string]bool{
+		"create": true, "update": true, "remove": true, "restore": true,
+		"set": true, "highRisk": true, "breached": true, "revoked": true,
+	}
+)
+
+// recordWebhookReceived counts a webhook by its type and action.
+func recordWebhookReceived(eventType, action string) {
+	if !linearWebhookTypes[eventType] {
+		eventType = "other"
+	}
+	if !linearWebhookActions[action] {
+		action = "other"
+	}
+	webhooksReceived.inc(eventType, action)
+}
+
+// outboxDepth counts the Discord messages accepted but not delivered yet.
+var outboxDepth atomic.Int64
+
+func init() {
+	newMetricFunc("gauge", "relay_outbox_depth", "Discord messages waiting to be delivered.", nil,
+		func(emit func(float64, ...string)) { emit(float64(outboxDepth.Load())) })
+	newMetricFunc("gauge", "relay_outbox_spooled", "Discord messages spooled at shutdown and not replayed yet.", nil,
+		func(emit func(float64, ...string)) { emit(float64(spooledMessages.Load())) })
+
+	newMetricFunc("counter", "relay_linear_rate_limit_wait_seconds_total",
+		"Time Linear requests spent throttled or backing off, per workspace.", []string{"workspace"},
+		func(emit func(float64, ...string)) {
+			eachLinearClient(func(name string, c *linear.Client) { emit(c.RateLimit().WaitSeconds, name) })
+		})
+	newMetricFunc("gauge", "relay_linear_rate_limit_requests_remaining",
+		"Requests left in the current Linear rate limit window, per workspace.", []string{"workspace"},
+		func(emit func(float64, ...string)) {
+			eachLinearClient(func(name string, c *linear.Client) {
+				if rl := c.RateLimit(); !rl.UpdatedAt.IsZero() {
+					emit(float64(rl.RequestsRemaining), name)
+				}
+			})
+		})
+	newMetricFunc("gauge", "relay_linear_rate_limit_complexity_remaining",
+		"Complexity points left in the current Linear rate limit window, per workspace.", []string{"workspace"},
+		func(emit func(float64, ...string)) {
+			eachLinearClient(func(name string, c *linear.Client) {
+				if rl := c.RateLimit(); !rl.UpdatedAt.IsZero() {
+					emit(float64(rl.ComplexityRemaining), name)
+				}
+			})
+		})
+
+	newMetricFunc("gauge", "relay_job_last_success_timestamp_seconds",
+		"Scheduled time of each job's last successful run, as a Unix timestamp.", []string{"job"},
+		func(emit func(float64, ...string)) {
+			for _, job := range scheduler.jobMetrics() {
+				if !job.lastSuccess.IsZero() {
+					emit(float64(job.lastSuccess.Unix()), job.name)
+				}
+			}
+		})
+	newMetricFunc("gauge", "relay_job_last_duration_seconds",
+		"Duration of each job's last run since startup.", []string{"job"},
+		func(emit func(float64, ...string)) {
+			for _, job := range scheduler.jobMetrics() {
+				if job.lastDuration > 0 {
+					emit(job.lastDuration.Seconds(), job.name)
+				}
+			}
+		})
+}
+
+// eachLinearClient calls fn with the LINEAR_API_KEY client ("default") and
+// every installed workspace's client.
+func eachLinearClient(fn func(name string, c *linear.Client)) {
+	if linearClient != nil && linearAPIKey != "" {
+		fn(defaultDestination, linearClient)
+	}
+	for _, ws := range workspaces.list() {
+		fn(ws.Key, ws.client)
+	}
+}
+
+// observeLinearRequest records the latency and errors of a Linear request.
+func observeLinearRequest(info linear.RequestInfo) {
+	linearLatency.observe(info.Duration.Seconds(), info.Operation)
+	if info.Err == nil {
+		return
+	}
+	code := linear.ErrorCode(info.Err)
+	var httpErr *linear.HTTPError
+	switch {
+	case code != "":
+	case errors.As(info.Err, &httpErr):
+		code = strconv.Itoa(httpErr.StatusCode)
+	default:
+		code = "error"
+	}
+	linearErrors.inc(info.Operation, code)
+}
+
+// observeDiscordRequest records the latency and status of a Discord request.
+func observeDiscordRequest(target string, start time.Time, resp *http.Response) {
+	discordLatency.observe(time.Since(start).Seconds(), target)
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	discordResponses.inc(target, code)
+}
+
+// recordDigestIssues replaces the per-status issue counts of a destination.
+func recordDigestIssues(destination string, byStatus []StatusGroup) {
+	digestIssues.deleteWhere(func(labelValues []string) bool { return labelValues[0] == destination })
+	for _, group := range byStatus {
+		digestIssues.set(float64(len(group.Issues)), destination, group.Name)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ============================================================================
+// REPORT PREVIEW
+// ============================================================================
+
+// Preview collects what a report would post instead of posting it. A report
+// runs in preview mode when ReportOptions.Preview is set; nothing is sent and
+// no "already posted" marks or snapshots are recorded.
+type Preview struct {
+	Job    string       `json:"job,omitempty"`
+	Kind   string       `json:"kind,omitempty"`
+	Filter *IssueFilter `json:"filter,omitempty"`
+	// GraphQLFilter is Filter compiled to a Linear IssueFilter. Each query
+	// combines it with its own conditions, e.g. "not completed or canceled".
+	GraphQLFilter map[string]interface{} `json:"graphql_filter,omitempty"`
+	Stats         map[string]interface{} `json:"stats"`
+	Messages      []PreviewMessage       `json:"messages"`
+}
+
+// PreviewMessage is one message the report would have sent. To is a
+// destination name, or "dm:<linear user>" for DM digests.
+type PreviewMessage struct {
+	To      string          `json:"to"`
+	Payload *DiscordWebhook `json:"payload"`
+}
+
+func newPreview(filter *IssueFilter) *Preview {
+	return &Preview{
+		Filter:        filter,
+		GraphQLFilter: filter.graphQL(),
+		Stats:         map[string]interface{}{},
+		Messages:      []PreviewMessage{},
+	}
+}
+
+func (p *Preview) add(to string, payload *DiscordWebhook) {
+	p.Messages = append(p.Messages, PreviewMessage{To: to, Payload: payload})
+}
+
+func (o ReportOptions) dryRun() bool {
+	return o.Preview != nil
+}
+
+// stat records a statistic behind the report for the preview.
+func (o ReportOptions) stat(name string, value interface{}) {
+	if o.Preview != nil {
+		o.Preview.Stats[name] = value
+	}
+}
+
+// reportOptions returns the options for a report triggered over HTTP:
+// ?dry_run=true previews the report instead of posting it, and
+// ?workspace=key runs it against an installed workspace and posts it to that
+// workspace's default destination. Both return issue data of their own, so
+// they require the admin token. It responds with an error and returns false
+// if the caller may not run the report or the workspace can't be queried.
+func reportOptions(w http.ResponseWriter, r *http.Request) (ReportOptions, bool) {
+	// Reports run to the end even if the caller stops waiting.
+	opts := ReportOptions{ctx: context.WithoutCancel(r.Context())}
+	key := r.URL.Query().Get("workspace")
+	dryRun := r.URL.Query().Get("dry_run")
+	dryRunSet := dryRun == "true" || dryRun == "1"
+	if (key != "" || dryRunSet) && !authorizeAdmin(w, r) {
+		return opts, false
+	}
+
+	if key != "" {
+		if opts.Workspace = workspaces.byKey(key); opts.Workspace == nil {
+			http.Error(w, fmt.Sprintf("Unknown workspace %q", key), http.StatusNotFound)
+			return opts, false
+		}
+		opts.Destination = appConfig.workspaceDestination(key, "")
+		if opts.Destination == nil && !dryRunSet {
+			http.Error(w, fmt.Sprintf("Workspace %q has no default destination", key), http.StatusConflict)
+			return opts, false
+		}
+	} else if linearAPIKey == "" {
+		http.Error(w, "LINEAR_API_KEY not configured", http.StatusServiceUnavailable)
+		return opts, false
+	}
+
+	if dryRunSet {
+		opts.Preview = newPreview(nil)
+	}
+	return opts, true
+}
+
+// handleReportPreview previews a scheduled job with its filter and
+// destination: /report/preview?job=name[&format=html].
+func handleReportPreview(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("job")
+	job := jobByName(name)
+	if job == nil {
+		http.Error(w, fmt.Sprintf("Unknown job %q", name), http.StatusNotFound)
+		return
+	}
+
+	if job.Workspace == "" && linearAPIKey == "" {
+		http.Error(w, "LINEAR_API_KEY not configured", http.StatusServiceUnavailable)
+		return
+	}
+	opts, err := jobOptions(withLogAttrs(context.WithoutCancel(r.Context()), "job", job.Name), job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	opts.Preview = newPreview(job.Filter)
+	opts.Preview.Job = job.Name
+	opts.Preview.Kind = job.Kind
+
+	if err := jobKinds[job.Kind](opts); err != nil {
+		slog.ErrorContext(opts.context(), "Error previewing job", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writePreview(w, r, opts.Preview)
+}
+
+// writePreview responds with the preview as JSON, or with ?format=html as a
+// page styled like a Discord channel.
+func writePreview(w http.ResponseWriter, r *http.Request, p *Preview) {
+	if r.URL.Query().Get("format") != "html" {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(p)
+		return
+	}
+
+	filter := ""
+	if p.GraphQLFilter != nil {
+		data, _ := json.MarshalIndent(p.GraphQLFilter, "", "  ")
+		filter = string(data)
+	}
+	stats, _ := json.MarshalIndent(p.Stats, "", "  ")
+
+	view := previewView{Preview: p, FilterJSON: filter, StatsJSON: string(stats)}
+	for _, m := range p.Messages {
+		view.Messages = append(view.Messages, newPreviewMessageView(m))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := previewTemplate.Execute(w, view); err != nil {
+		slog.ErrorContext(r.Context(), "Error rendering preview", "error", err)
+	}
+}
+
+type previewView struct {
+	*Preview
+	FilterJSON string
+	StatsJSON  string
+	Messages   []previewMessageView
+}
+
+type previewMessageView struct {
+	To        string
+	Username  string
+	AvatarURL string
+	Content   template.HTML
+	Embeds    []previewEmbedView
+}
+
+type previewEmbedView struct {
+	DiscordEmbed
+	Color       string
+	Description template.HTML
+	Fields      []previewFieldView
+	Image       template.URL
+}
+
+type previewFieldView struct {
+	Name   string
+	Value  template.HTML
+	Inline bool
+}
+
+// newPreviewMessageView renders the Markdown of a message and inlines its
+// attached images as data URIs.
+func newPreviewMessageView(m PreviewMessage) previewMessageView {
+	files := map[string]DiscordFile{}
+	for _, f := range m.Payload.Files {
+		files["attachment://"+f.Name] = f
+	}
+
+	view := previewMessageView{
+		To:        m.To,
+		Username:  m.Payload.Username,
+		AvatarURL: m.Payload.AvatarURL,
+		Content:   discordMarkdown(m.Payload.Content),
+	}
+	for _, e := range m.Payload.Embeds {
+		ev := previewEmbedView{
+			DiscordEmbed: e,
+			Color:        fmt.Sprintf("#%06x", e.Color),
+			Description:  discordMarkdown(e.Description),
+		}
+		for _, f := range e.Fields {
+			ev.Fields = append(ev.Fields, previewFieldView{Name: f.Name, Value: discordMarkdown(f.Value), Inline: f.Inline})
+		}
+		if e.Image != nil {
+			if f, ok := files[e.Image.URL]; ok {
+				ev.Image = template.URL("data:" + mime.TypeByExtension(filepath.Ext(f.Name)) + ";base64," + base64.StdEncoding.EncodeToString(f.Data))
+			} else if strings.HasPrefix(e.Image.URL, "https://") {
+				ev.Image = template.URL(e.Image.URL)
+			}
+		}
+		view.Embeds = append(view.Embeds, ev)
+	}
+	return view
+}
+
+var (
+	markdownLink   = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	markdownBold   = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	markdownItalic = regexp.MustCompile(`\*(.+?)\*`)
+	markdownStrike = regexp.MustCompile(`~~(.+?)~~`)
+	markdownCode   = regexp.MustCompile("`([^`]+)`")
+)
+
+// discordMarkdown renders the subset of Discord Markdown the reports use.
+func discordMarkdown(s string) template.HTML {
+	s = html.EscapeString(s)
+	s = markdownCode.ReplaceAllString(s, "<code>$1</code>")
+	s = markdownLink.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	s = markdownBold.ReplaceAllString(s, "<strong>$1</strong>")
+	s = markdownItalic.ReplaceAllString(s, "<em>$1</em>")
+	s = markdownStrike.ReplaceAllString(s, "<s>$1</s>")
+	s = strings.ReplaceAll(s, "\n", "<br>")
+	return template.HTML(s)
+}
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Report preview{{with .Job}} - {{.}}{{end}}</title>
+<style>
+body { background: #313338; color: #dbdee1; font: 15px/1.375 "gg sans", "Helvetica Neue", Helvetica, Arial, sans-serif; margin: 0; padding: 16px 24px; }
+a { color: #00a8fc; text-decoration: none; }
+pre, code { background: #2b2d31; border-radius: 4px; font-family: Consolas, monospace; font-size: 85%; }
+pre { padding: 8px; white-space: pre-wrap; }
+details { margin-bottom: 16px; color: #b5bac1; }
+.message { display: flex; gap: 16px; margin: 16px 0; }
+.avatar { width: 40px; height: 40px; border-radius: 50%; }
+.username { color: #f2f3f5; font-weight: 500; }
+.to, .timestamp { color: #949ba4; font-size: 12px; margin-left: 4px; }
+.embed { background: #2b2d31; border-left: 4px solid; border-radius: 4px; max-width: 520px; margin-top: 8px; padding: 8px 16px 16px 12px; }
+.embed-author { font-size: 14px; font-weight: 600; margin-top: 8px; }
+.embed-title { color: #f2f3f5; font-weight: 600; margin-top: 8px; }
+.embed-description { font-size: 14px; margin-top: 8px; }
+.embed-fields { display: grid; grid-template-columns: repeat(3, 1fr); gap: 8px; margin-top: 8px; font-size: 14px; }
+.embed-field { grid-column: 1 / -1; }
+.embed-field.inline { grid-column: auto; }
+.embed-field-name { color: #f2f3f5; font-weight: 600; }
+.embed-image { max-width: 100%; border-radius: 4px; margin-top: 16px; }
+.embed-footer { color: #b5bac1; font-size: 12px; margin-top: 8px; }
+</style>
+</head>
+<body>
+<details><summary>{{with .Job}}Job <strong>{{.}}</strong> ({{$.Kind}}) · {{end}}{{len .Messages}} message(s) · stats and filter</summary>
+<pre>{{.StatsJSON}}</pre>
+{{if .FilterJSON}}<pre>{{.FilterJSON}}</pre>{{end}}
+</details>
+{{range .Messages}}
+<div class="message">
+  <img class="avatar" src="{{.AvatarURL}}" alt="">
+  <div>
+    <span class="username">{{.Username}}</span><span class="to">→ {{.To}}</span>
+    {{if .Content}}<div>{{.Content}}</div>{{end}}
+    {{range .Embeds}}
+    <div class="embed" style="border-color: {{.Color}}">
+      {{with .Author}}<div class="embed-author">{{.Name}}</div>{{end}}
+      {{if .Title}}<div class="embed-title">{{if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</div>{{end}}
+      {{if .Description}}<div class="embed-description">{{.Description}}</div>{{end}}
+      {{if .Fields}}<div class="embed-fields">{{range .Fields}}<div class="embed-field{{if .Inline}} inline{{end}}"><div class="embed-field-name">{{.Name}}</div><div>{{.Value}}</div></div>{{end}}</div>{{end}}
+      {{if .Image}}<img class="embed-image" src="{{.Image}}" alt="">{{end}}
+      {{if or .Footer .Timestamp}}<div class="embed-footer">{{with .Footer}}{{.Text}}{{end}}{{if .Timestamp}} · {{.Timestamp}}{{end}}</div>{{end}}
+    </div>
+    {{end}}
+  </div>
+</div>
+{{end}}
+</body>
+</html>
+`))
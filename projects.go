@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// PROJECT HEALTH REPORT
+// ============================================================================
+
+// ProjectHealthConfig is the "projects" section of the config.
+type ProjectHealthConfig struct {
+	// UpdateStaleDays flags projects whose latest update is older than this.
+	// Defaults to 14.
+	UpdateStaleDays int `json:"update_stale_days,omitempty"`
+}
+
+const defaultUpdateStaleDays = 14
+
+func (c *ProjectHealthConfig) updateStaleAfter() time.Duration {
+	days := defaultUpdateStaleDays
+	if c != nil && c.UpdateStaleDays > 0 {
+		days = c.UpdateStaleDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// lastProjectUpdate returns the newest project update, or nil if there is
+// none.
+func lastProjectUpdate(p Project) *ProjectUpdate {
+	var last *ProjectUpdate
+	for i, update := range p.ProjectUpdates.Nodes {
+		if last == nil || update.CreatedAt.After(last.CreatedAt) {
+			last = &p.ProjectUpdates.Nodes[i]
+		}
+	}
+	return last
+}
+
+// projectTargetDate parses TargetDate as the end of that day in UTC.
+func projectTargetDate(p Project) (time.Time, bool) {
+	t, err := time.Parse(dateLayout, p.TargetDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.Add(24 * time.Hour), true
+}
+
+// ProjectHealth is a project together with what the report flags about it.
+type ProjectHealth struct {
+	Project
+	Update      *ProjectUpdate
+	Overdue     bool
+	StaleUpdate bool
+}
+
+func (h ProjectHealth) needsAttention() bool {
+	if h.Overdue || h.StaleUpdate {
+		return true
+	}
+	return h.Update != nil && h.Update.Health != "onTrack"
+}
+
+func assessProjects(projects []Project, cfg *ProjectHealthConfig, now time.Time) []ProjectHealth {
+	result := make([]ProjectHealth, len(projects))
+	for i, p := range projects {
+		h := ProjectHealth{Project: p, Update: lastProjectUpdate(p)}
+		if target, ok := projectTargetDate(p); ok && now.After(target) {
+			h.Overdue = true
+		}
+		h.StaleUpdate = h.Update == nil || now.Sub(h.Update.CreatedAt) > cfg.updateStaleAfter()
+		result[i] = h
+	}
+
+	// Soonest target date first; projects without one go last.
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i].TargetDate, result[j].TargetDate
+		if (a == "") != (b == "") {
+			return b == ""
+		}
+		if a != b {
+			return a < b
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// fetchActiveProjects returns the started projects with their five most
+// recent updates. A team filter keeps projects shared with any listed team.
+func fetchActiveProjects(opts ReportOptions) ([]Project, error) {
+	all, err := opts.linear().Projects(opts.context(), object("state", object("eq", "started")))
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []Project
+	for _, p := range all {
+		if projectMatches(p, opts.Filter) {
+			projects = append(projects, p)
+		}
+	}
+	return projects, nil
+}
+
+func projectMatches(p Project, filter *IssueFilter) bool {
+	if filter == nil || len(filter.Teams) == 0 {
+		return true
+	}
+	for _, team := range p.Teams.Nodes {
+		if filter.hasTeam(team.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+func handleReportProjects(w http.ResponseWriter, r *http.Request) {
+	opts, ok := reportOptions(w, r)
+	if !ok {
+		return
+	}
+	if err := generateProjectHealthReport(opts); err != nil {
+		slog.ErrorContext(opts.context(), "Error generating project health report", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if opts.dryRun() {
+		writePreview(w, r, opts.Preview)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "project_report_sent"})
+}
+
+func generateProjectHealthReport(opts ReportOptions) error {
+	slog.InfoContext(opts.context(), "Fetching projects for project health report...")
+	projects, err := fetchActiveProjects(opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch projects: %w", err)
+	}
+
+	now := time.Now()
+	health := assessProjects(projects, appConfig.Projects, now)
+
+	if len(health) == 0 {
+		return opts.send(&DiscordWebhook{
+			Username:  "Linear Projects",
+			AvatarURL: linearAvatarURL,
+			Embeds: []DiscordEmbed{{
+				Title:       "📁 Project Health",
+				Description: "No projects are in progress.",
+				Color:       ColorGray,
+				Timestamp:   now.UTC().Format(time.RFC3339),
+			}},
+		})
+	}
+
+	counts := map[string]int{}
+	var attention, onTrack []ProjectHealth
+	overdue, stale := 0, 0
+	for _, h := range health {
+		if h.Update != nil {
+			counts[h.Update.Health]++
+		} else {
+			counts[""]++
+		}
+		if h.Overdue {
+			overdue++
+		}
+		if h.StaleUpdate {
+			stale++
+		}
+		if h.needsAttention() {
+			attention = append(attention, h)
+		} else {
+			onTrack = append(onTrack, h)
+		}
+	}
+	slog.InfoContext(opts.context(), "Project health: Assessed projects", "projects", len(health), "attention", len(attention))
+
+	description := fmt.Sprintf("**%d** active projects\n🟢 %d on track | 🟡 %d at risk | 🔴 %d off track | ⚪ %d no update",
+		len(health), counts["onTrack"], counts["atRisk"], counts["offTrack"], counts[""])
+	if overdue > 0 || stale > 0 {
+		description += fmt.Sprintf("\n⏰ **%d** past target date | 💤 **%d** without an update in %d days",
+			overdue, stale, int(appConfig.Projects.updateStaleAfter().Hours()/24))
+	}
+
+	color := ColorGreen
+	if len(attention) > 0 {
+		color = ColorYellow
+	}
+	if counts["offTrack"] > 0 || overdue > 0 {
+		color = ColorRed
+	}
+
+	embeds := []DiscordEmbed{{
+		Title:       "📁 Project Health",
+		Description: description,
+		Color:       color,
+		Timestamp:   now.UTC().Format(time.RFC3339),
+	}}
+
+	if len(attention) > 0 {
+		embeds = append(embeds, DiscordEmbed{
+			Title:       "🚩 Needs Attention",
+			Description: formatProjects(attention, now),
+			Color:       ColorRed,
+		})
+	}
+	if len(onTrack) > 0 {
+		embeds = append(embeds, DiscordEmbed{
+			Title:       "✅ On Track",
+			Description: formatProjects(onTrack, now),
+			Color:       ColorGreen,
+		})
+	}
+
+	return opts.send(&DiscordWebhook{
+		Username:  "Linear Projects",
+		AvatarURL: linearAvatarURL,
+		Embeds:    embeds,
+	})
+}
+
+// formatProjects writes two lines per project and stops at maxSectionLength.
+func formatProjects(projects []ProjectHealth, now time.Time) string {
+	var b strings.Builder
+	for i, h := range projects {
+		details := []string{fmt.Sprintf("%d%%", int(h.Progress*100+0.5))}
+		if h.Lead != nil {
+			details = append(details, "👤 "+assigneeName(h.Lead))
+		}
+		if target, ok := projectTargetDate(h.Project); ok {
+			due := "🎯 " + target.Add(-24*time.Hour).Format("Jan 2")
+			if h.Overdue {
+				due += fmt.Sprintf(" (**%s overdue**)", formatAge(now.Sub(target)))
+			}
+			details = append(details, due)
+		}
+		if h.Update != nil {
+			updated := fmt.Sprintf("updated %s ago", formatAge(now.Sub(h.Update.CreatedAt)))
+			if h.StaleUpdate {
+				updated = "💤 " + updated
+			}
+			details = append(details, updated)
+		} else {
+			details = append(details, "💤 never updated")
+		}
+
+		line := fmt.Sprintf("%s [**%s**](%s)\n%s\n", projectHealthEmoji(h.Update), truncate(h.Name, 60), h.URL, strings.Join(details, " · "))
+		if b.Len()+len(line) > maxSectionLength-30 {
+			fmt.Fprintf(&b, "*... and %d more*", len(projects)-i)
+			break
+		}
+		b.WriteString(line)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func projectHealthEmoji(update *ProjectUpdate) string {
+	if update == nil {
+		return "⚪"
+	}
+	switch update.Health {
+	case "onTrack":
+		return "🟢"
+	case "atRisk":
+		return "🟡"
+	case "offTrack":
+		return "🔴"
+	default:
+		return "⚪"
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// ============================================================================
+// WEEKLY RECAP
+// ============================================================================
+
+// recapPeriod is how far back the weekly recap looks.
+const recapPeriod = 7 * 24 * time.Hour
+
+// Throughput counts the issues one assignee or team closed in the period.
+type Throughput struct {
+	Name      string
+	Completed int
+	Canceled  int
+	Points    float64
+}
+
+// WeeklyRecap is the work closed between Since and Until.
+type WeeklyRecap struct {
+	Since      time.Time
+	Until      time.Time
+	Completed  []Issue
+	Canceled   []Issue
+	ByAssignee []Throughput
+	ByTeam     []Throughput
+	// CycleTime is the median time from started to completed, over the
+	// completed issues that have a start time.
+	CycleTime time.Duration
+	CycleN    int
+}
+
+// fetchClosedIssues returns the issues completed or canceled since the given
+// time, including ones archived since.
+func fetchClosedIssues(opts ReportOptions, since time.Time) ([]Issue, error) {
+	after := object("gte", since.UTC().Format(time.RFC3339))
+	closed := object("or", []interface{}{object("completedAt", after), object("canceledAt", after)})
+	return opts.linear().Issues(opts.context(), linear.IssuesOptions{
+		Filter:          issueFilterVariable(closed, opts.Filter),
+		IncludeArchived: true,
+	})
+}
+
+func buildWeeklyRecap(issues []Issue, since, until time.Time) *WeeklyRecap {
+	recap := &WeeklyRecap{Since: since, Until: until}
+	assignees := map[string]*Throughput{}
+	teams := map[string]*Throughput{}
+	var cycleTimes []time.Duration
+
+	count := func(m map[string]*Throughput, name string, issue Issue, completed bool) {
+		t := m[name]
+		if t == nil {
+			t = &Throughput{Name: name}
+			m[name] = t
+		}
+		if !completed {
+			t.Canceled++
+			return
+		}
+		t.Completed++
+		if issue.Estimate != nil {
+			t.Points += *issue.Estimate
+		}
+	}
+
+	for _, issue := range issues {
+		completed := issue.CompletedAt != nil && !issue.CompletedAt.Before(since)
+		if !completed && (issue.CanceledAt == nil || issue.CanceledAt.Before(since)) {
+			continue
+		}
+
+		if completed {
+			recap.Completed = append(recap.Completed, issue)
+			if issue.StartedAt != nil && issue.CompletedAt.After(*issue.StartedAt) {
+				cycleTimes = append(cycleTimes, issue.CompletedAt.Sub(*issue.StartedAt))
+			}
+		} else {
+			recap.Canceled = append(recap.Canceled, issue)
+		}
+
+		count(assignees, assigneeName(issue.Assignee), issue, completed)
+		count(teams, issue.Team.Name, issue, completed)
+	}
+
+	recap.ByAssignee = sortThroughput(assignees)
+	recap.ByTeam = sortThroughput(teams)
+
+	if len(cycleTimes) > 0 {
+		sort.Slice(cycleTimes, func(i, j int) bool { return cycleTimes[i] < cycleTimes[j] })
+		mid := len(cycleTimes) / 2
+		recap.CycleTime = cycleTimes[mid]
+		if len(cycleTimes)%2 == 0 {
+			recap.CycleTime = (cycleTimes[mid-1] + cycleTimes[mid]) / 2
+		}
+		recap.CycleN = len(cycleTimes)
+	}
+
+	return recap
+}
+
+// sortThroughput orders by completed issues, then points, then name.
+func sortThroughput(m map[string]*Throughput) []Throughput {
+	list := make([]Throughput, 0, len(m))
+	for _, t := range m {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Completed != list[j].Completed {
+			return list[i].Completed > list[j].Completed
+		}
+		if list[i].Points != list[j].Points {
+			return list[i].Points > list[j].Points
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// biggestShipped returns up to n completed issues with the largest estimate,
+// breaking ties by priority (urgent first; no priority last).
+func (r *WeeklyRecap) biggestShipped(n int) []Issue {
+	issues := append([]Issue(nil), r.Completed...)
+	estimate := func(issue Issue) float64 {
+		if issue.Estimate == nil {
+			return 0
+		}
+		return *issue.Estimate
+	}
+	rank := func(issue Issue) int {
+		if issue.Priority == 0 {
+			return 5
+		}
+		return issue.Priority
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if estimate(issues[i]) != estimate(issues[j]) {
+			return estimate(issues[i]) > estimate(issues[j])
+		}
+		return rank(issues[i]) < rank(issues[j])
+	})
+	if len(issues) > n {
+		issues = issues[:n]
+	}
+	return issues
+}
+
+func handleReportWeekly(w http.ResponseWriter, r *http.Request) {
+	opts, ok := reportOptions(w, r)
+	if !ok {
+		return
+	}
+	if err := generateWeeklyRecap(opts); err != nil {
+		slog.ErrorContext(opts.context(), "Error generating weekly recap", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if opts.dryRun() {
+		writePreview(w, r, opts.Preview)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "weekly_recap_sent"})
+}
+
+func generateWeeklyRecap(opts ReportOptions) error {
+	now := time.Now()
+	since := now.Add(-recapPeriod)
+
+	slog.InfoContext(opts.context(), "Fetching closed issues for weekly recap...")
+	issues, err := fetchClosedIssues(opts, since)
+	if err != nil {
+		return fmt.Errorf("failed to fetch issues: %w", err)
+	}
+
+	recap := buildWeeklyRecap(issues, since, now)
+	slog.InfoContext(opts.context(), "Weekly recap: Counted closed issues", "completed", len(recap.Completed), "canceled", len(recap.Canceled))
+
+	period := fmt.Sprintf("%s – %s", since.UTC().Format("Jan 2"), now.UTC().Format("Jan 2, 2006"))
+
+	if len(recap.Completed)+len(recap.Canceled) == 0 {
+		return opts.send(&DiscordWebhook{
+			Username:  "Linear Weekly Recap",
+			AvatarURL: linearAvatarURL,
+			Embeds: []DiscordEmbed{{
+				Title:       "📅 Weekly Recap - " + period,
+				Description: "No issues were completed or canceled this week.",
+				Color:       ColorGray,
+				Timestamp:   now.UTC().Format(time.RFC3339),
+			}},
+		})
+	}
+
+	description := fmt.Sprintf("**%d** completed | **%d** canceled", len(recap.Completed), len(recap.Canceled))
+	if recap.CycleN > 0 {
+		description += fmt.Sprintf("\n⏱️ Median cycle time: **%s** (over %d issues)", formatDuration(recap.CycleTime), recap.CycleN)
+	}
+
+	mainEmbed := DiscordEmbed{
+		Title:       "📅 Weekly Recap - " + period,
+		Description: description,
+		Color:       ColorGreen,
+		Timestamp:   now.UTC().Format(time.RFC3339),
+		Fields: []DiscordField{
+			{Name: "👥 By Assignee", Value: formatThroughput(recap.ByAssignee), Inline: true},
+			{Name: "🏢 By Team", Value: formatThroughput(recap.ByTeam), Inline: true},
+		},
+	}
+
+	embeds := []DiscordEmbed{mainEmbed}
+
+	if biggest := recap.biggestShipped(5); len(biggest) > 0 {
+		var lines []string
+		for _, issue := range biggest {
+			line := fmt.Sprintf("%s [%s](%s) - %s", getPriorityEmoji(issue.Priority), issue.Identifier, issue.URL, truncate(issue.Title, 50))
+			if issue.Estimate != nil {
+				line += fmt.Sprintf(" · %s pts", formatPoints(*issue.Estimate))
+			}
+			if issue.Assignee != nil {
+				line += " · " + assigneeName(issue.Assignee)
+			}
+			lines = append(lines, line)
+		}
+		embeds = append(embeds, DiscordEmbed{
+			Title:       "🚢 Biggest Shipped",
+			Description: strings.Join(lines, "\n"),
+			Color:       ColorBlue,
+		})
+	}
+
+	return opts.send(&DiscordWebhook{
+		Username:  "Linear Weekly Recap",
+		AvatarURL: linearAvatarURL,
+		Embeds:    embeds,
+	})
+}
+
+// formatThroughput renders one line per assignee or team, at most ten.
+func formatThroughput(list []Throughput) string {
+	const maxLines = 10
+	var lines []string
+	for i, t := range list {
+		if i == maxLines {
+			lines = append(lines, fmt.Sprintf("*... and %d more*", len(list)-maxLines))
+			break
+		}
+		line := fmt.Sprintf("• %s: **%d** done", t.Name, t.Completed)
+		if t.Points > 0 {
+			line += fmt.Sprintf(" (%s pts)", formatPoints(t.Points))
+		}
+		if t.Canceled > 0 {
+			line += fmt.Sprintf(", %d canceled", t.Canceled)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatPoints(p float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", p), ".0")
+}
+
+// formatDuration renders a cycle time as "5h", "2.5d" and so on.
+func formatDuration(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Round(time.Hour).Hours()))
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", d.Hours()/24), ".0") + "d"
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ============================================================================
+// RUN LEDGER
+// ============================================================================
+
+// Every scheduled run is identified by an idempotency key made of the job name
+// and its scheduled time. Replicas sharing STATE_DIR record their claim in the
+// ledger before sending, so each key is delivered at most once no matter how
+// many instances wake up for it.
+
+// RunRecord is one entry in the run ledger.
+type RunRecord struct {
+	Job         string    `json:"job"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	Status      string    `json:"status"` // running, done or failed
+	Holder      string    `json:"holder"`
+	StartedAt   time.Time `json:"started_at"`
+	LeaseUntil  time.Time `json:"lease_until"`
+	FinishedAt  time.Time `json:"finished_at"`
+	Error       string    `json:"error,omitempty"`
+}
+
+const (
+	runRunning = "running"
+	runDone    = "done"
+	runFailed  = "failed"
+
+	// runLease is how long a claim stays valid without finishing. A replica
+	// that dies mid-run gives up the key once its lease runs out.
+	runLease = 30 * time.Minute
+
+	// runRetention is how long ledger entries are kept.
+	runRetention = 30 * 24 * time.Hour
+)
+
+// instanceID identifies this process in the ledger and lock files.
+var instanceID = newInstanceID()
+
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+func runKey(job string, scheduledAt time.Time) string {
+	return job + "@" + scheduledAt.UTC().Format(time.RFC3339)
+}
+
+// claimRun records that this instance is about to execute the run. It returns
+// false with the current record when another instance already has it. A
+// failed run may be claimed again while it is at most retryWithin late, so
+// that catch-up retries a run that failed before it was sent.
+func claimRun(job string, scheduledAt time.Time, retryWithin time.Duration) (bool, *RunRecord, error) {
+	key := runKey(job, scheduledAt)
+	now := time.Now().UTC()
+
+	var claimed bool
+	var existing *RunRecord
+	ledger := map[string]*RunRecord{}
+
+	err := store.update("runs", &ledger, func() error {
+		for k, record := range ledger {
+			if now.Sub(record.ScheduledAt) > runRetention {
+				delete(ledger, k)
+			}
+		}
+
+		if record := ledger[key]; record != nil {
+			abandoned := record.Status == runRunning && now.After(record.LeaseUntil)
+			retry := record.Status == runFailed && now.Sub(scheduledAt) <= retryWithin
+			if !abandoned && !retry {
+				existing = record
+				return nil
+			}
+		}
+
+		ledger[key] = &RunRecord{
+			Job:         job,
+			ScheduledAt: scheduledAt.UTC(),
+			Status:      runRunning,
+			Holder:      instanceID,
+			StartedAt:   now,
+			LeaseUntil:  now.Add(runLease),
+		}
+		claimed = true
+		return nil
+	})
+
+	return claimed, existing, err
+}
+
+// finishRun marks a claimed run as done or failed.
+func finishRun(job string, scheduledAt time.Time, runErr error) error {
+	key := runKey(job, scheduledAt)
+	ledger := map[string]*RunRecord{}
+
+	return store.update("runs", &ledger, func() error {
+		record := ledger[key]
+		if record == nil || record.Holder != instanceID {
+			return fmt.Errorf("run %s is no longer held by this instance", key)
+		}
+		record.FinishedAt = time.Now().UTC()
+		record.LeaseUntil = time.Time{}
+		record.Status = runDone
+		if runErr != nil {
+			record.Status = runFailed
+			record.Error = runErr.Error()
+		}
+		return nil
+	})
+}
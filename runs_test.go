@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// setTestStore points the state store at a temp STATE_DIR for the duration
+// of a test.
+func setTestStore(t *testing.T) {
+	t.Helper()
+	prev := store
+	s, err := openStateStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("openStateStore: %v", err)
+	}
+	store = s
+	t.Cleanup(func() { store = prev })
+}
+
+func TestClaimRun(t *testing.T) {
+	now := time.Now().UTC()
+	scheduledAt := now.Add(-10 * time.Minute).Truncate(time.Minute)
+	other := "other-replica"
+
+	tests := []struct {
+		name        string
+		existing    *RunRecord
+		retryWithin time.Duration
+		wantClaimed bool
+	}{
+		{"first claim", nil, 0, true},
+		{"held by another replica", &RunRecord{Status: runRunning, Holder: other, LeaseUntil: now.Add(time.Minute)}, time.Hour, false},
+		{"held by this replica", &RunRecord{Status: runRunning, Holder: instanceID, LeaseUntil: now.Add(time.Minute)}, 0, false},
+		{"expired lease", &RunRecord{Status: runRunning, Holder: other, LeaseUntil: now.Add(-time.Minute)}, 0, true},
+		{"done", &RunRecord{Status: runDone, Holder: other}, time.Hour, false},
+		{"failed, not retried", &RunRecord{Status: runFailed, Holder: other}, 0, false},
+		{"failed, within grace", &RunRecord{Status: runFailed, Holder: other}, time.Hour, true},
+		{"failed, outside grace", &RunRecord{Status: runFailed, Holder: other}, 5 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestStore(t)
+			if tt.existing != nil {
+				tt.existing.Job = "digest"
+				tt.existing.ScheduledAt = scheduledAt
+				if err := store.save("runs", map[string]*RunRecord{runKey("digest", scheduledAt): tt.existing}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			claimed, existing, err := claimRun("digest", scheduledAt, tt.retryWithin)
+			if err != nil {
+				t.Fatalf("claimRun: %v", err)
+			}
+			if claimed != tt.wantClaimed {
+				t.Fatalf("claimed = %v, want %v", claimed, tt.wantClaimed)
+			}
+
+			ledger := map[string]*RunRecord{}
+			if err := store.load("runs", &ledger); err != nil {
+				t.Fatal(err)
+			}
+			record := ledger[runKey("digest", scheduledAt)]
+			if claimed {
+				if existing != nil {
+					t.Errorf("existing = %+v, want nil for a claimed run", existing)
+				}
+				if record.Holder != instanceID || record.Status != runRunning || !record.LeaseUntil.After(now) {
+					t.Errorf("record = %+v, want a running lease held by this instance", record)
+				}
+				return
+			}
+			if existing == nil || existing.Status != tt.existing.Status || existing.Holder != tt.existing.Holder {
+				t.Errorf("existing = %+v, want %+v", existing, tt.existing)
+			}
+			if record.Holder != tt.existing.Holder || record.Status != tt.existing.Status {
+				t.Errorf("record was changed to %+v", record)
+			}
+		})
+	}
+}
+
+func TestClaimRunOnce(t *testing.T) {
+	setTestStore(t)
+	scheduledAt := time.Now().Truncate(time.Minute)
+
+	claimed, _, err := claimRun("digest", scheduledAt, 0)
+	if err != nil || !claimed {
+		t.Fatalf("first claimRun = %v, %v", claimed, err)
+	}
+	claimed, existing, err := claimRun("digest", scheduledAt, time.Hour)
+	if err != nil || claimed {
+		t.Fatalf("second claimRun = %v, %v; want the run to be claimed once", claimed, err)
+	}
+	if existing.Status != runRunning {
+		t.Errorf("existing status = %q, want %q", existing.Status, runRunning)
+	}
+
+	// Another job, or the same job at another time, is a different key.
+	if claimed, _, _ := claimRun("stale", scheduledAt, 0); !claimed {
+		t.Error("claimRun of another job was refused")
+	}
+	if claimed, _, _ := claimRun("digest", scheduledAt.Add(time.Hour), 0); !claimed {
+		t.Error("claimRun of another scheduled time was refused")
+	}
+}
+
+func TestFinishRun(t *testing.T) {
+	setTestStore(t)
+	scheduledAt := time.Now().Truncate(time.Minute)
+	key := runKey("digest", scheduledAt)
+
+	if err := finishRun("digest", scheduledAt, nil); err == nil {
+		t.Error("finishRun of an unclaimed run succeeded")
+	}
+
+	if claimed, _, err := claimRun("digest", scheduledAt, 0); err != nil || !claimed {
+		t.Fatalf("claimRun = %v, %v", claimed, err)
+	}
+	if err := finishRun("digest", scheduledAt, errors.New("linear is down")); err != nil {
+		t.Fatalf("finishRun: %v", err)
+	}
+	ledger := map[string]*RunRecord{}
+	store.load("runs", &ledger)
+	if r := ledger[key]; r.Status != runFailed || r.Error != "linear is down" || r.FinishedAt.IsZero() || !r.LeaseUntil.IsZero() {
+		t.Errorf("record = %+v, want a finished failed run", r)
+	}
+
+	// The failed run is retried within the grace window and then succeeds.
+	if claimed, _, err := claimRun("digest", scheduledAt, time.Hour); err != nil || !claimed {
+		t.Fatalf("retry claimRun = %v, %v", claimed, err)
+	}
+	if err := finishRun("digest", scheduledAt, nil); err != nil {
+		t.Fatalf("finishRun: %v", err)
+	}
+	store.load("runs", &ledger)
+	if r := ledger[key]; r.Status != runDone || r.Error != "" {
+		t.Errorf("record = %+v, want a done run without error", r)
+	}
+	if claimed, _, _ := claimRun("digest", scheduledAt, time.Hour); claimed {
+		t.Error("a done run was claimed again")
+	}
+
+	// A run taken over by another replica after its lease ran out can't be
+	// finished by the one that lost it.
+	ledger[key] = &RunRecord{Job: "digest", ScheduledAt: scheduledAt, Status: runRunning, Holder: "other-replica"}
+	store.save("runs", ledger)
+	if err := finishRun("digest", scheduledAt, nil); err == nil {
+		t.Error("finishRun of a run held by another replica succeeded")
+	}
+}
@@ -0,0 +1,633 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// INTERNAL SCHEDULER
+// ============================================================================
+
+// JobConfig is one scheduled report from the "jobs" section of the config.
+type JobConfig struct {
+	Name        string       `json:"name"`
+	Kind        string       `json:"kind"`
+	Schedule    string       `json:"schedule"`
+	Timezone    string       `json:"timezone,omitempty"`
+	Destination string       `json:"destination,omitempty"`
+	Filter      *IssueFilter `json:"filter,omitempty"`
+	// Workspace runs the job against a workspace installed through OAuth
+	// instead of LINEAR_API_KEY.
+	Workspace string     `json:"workspace,omitempty"`
+	Skip      *SkipRules `json:"skip,omitempty"`
+
+	// CatchUpGrace overrides the config-wide catch_up_grace for this job.
+	CatchUpGrace string `json:"catch_up_grace,omitempty"`
+
+	schedule  *CronSchedule
+	skipDates *Calendar
+	grace     time.Duration
+}
+
+// jobKinds maps a job's "kind" to the report it runs.
+var jobKinds = map[string]func(opts ReportOptions) error{
+	"user_report":   generateUserTasksReport,
+	"digest":        generateAndSendReport,
+	"stale":         generateStaleReport,
+	"weekly_recap":  generateWeeklyRecap,
+	"cycle_checkin": generateCycleCheckin,
+	"cycle_summary": generateCycleSummary,
+	"projects":      generateProjectHealthReport,
+	"dm_digest":     generateDMDigests,
+	"sla_alerts":    generateSLAAlerts,
+}
+
+// defaultJobs keeps the original behaviour when the config defines no jobs:
+// the per-user report at 9 AM UTC, Monday to Friday.
+func defaultJobs() []*JobConfig {
+	return []*JobConfig{{
+		Name:     "daily-user-report",
+		Kind:     "user_report",
+		Schedule: "0 9 * * 1-5",
+		Timezone: "UTC",
+	}}
+}
+
+// defaultCatchUpGrace is how late a missed run may still be sent after a
+// restart when the config doesn't say otherwise.
+const defaultCatchUpGrace = time.Hour
+
+func (c *Config) validateJobs() error {
+	if len(c.Jobs) == 0 {
+		c.Jobs = defaultJobs()
+	}
+
+	grace := defaultCatchUpGrace
+	if c.CatchUpGrace != "" {
+		var err error
+		if grace, err = time.ParseDuration(c.CatchUpGrace); err != nil {
+			return fmt.Errorf("invalid catch_up_grace %q: %w", c.CatchUpGrace, err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, job := range c.Jobs {
+		if job.Name == "" {
+			job.Name = fmt.Sprintf("job-%d", i+1)
+		}
+		if seen[job.Name] {
+			return fmt.Errorf("duplicate job name %q", job.Name)
+		}
+		seen[job.Name] = true
+
+		if jobKinds[job.Kind] == nil {
+			return fmt.Errorf("job %q has unknown kind %q", job.Name, job.Kind)
+		}
+		if job.Workspace != "" && c.Workspaces[job.Workspace] == nil {
+			return fmt.Errorf("job %q references unknown workspace %q", job.Name, job.Workspace)
+		}
+		if c.workspaceDestination(job.Workspace, job.Destination) == nil {
+			if job.Workspace != "" {
+				return fmt.Errorf("job %q: workspace %q has no destination %q", job.Name, job.Workspace, job.Destination)
+			}
+			return fmt.Errorf("job %q references unknown destination %q", job.Name, job.Destination)
+		}
+
+		if err := job.Filter.validate(); err != nil {
+			return fmt.Errorf("job %q filter: %w", job.Name, err)
+		}
+
+		schedule, err := parseCron(job.Schedule, job.Timezone)
+		if err != nil {
+			return fmt.Errorf("job %q: %w", job.Name, err)
+		}
+		job.schedule = schedule
+
+		job.grace = grace
+		if job.CatchUpGrace != "" {
+			if job.grace, err = time.ParseDuration(job.CatchUpGrace); err != nil {
+				return fmt.Errorf("job %q: invalid catch_up_grace %q: %w", job.Name, job.CatchUpGrace, err)
+			}
+		}
+
+		job.skipDates = newCalendar()
+		if job.Skip != nil {
+			if err := job.skipDates.addDates(job.Skip.Dates, "skip date"); err != nil {
+				return fmt.Errorf("job %q skip: %w", job.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Scheduler runs each configured job on its own cron schedule and keeps the
+// run history shown on /admin/scheduler.
+type Scheduler struct {
+	mu       sync.Mutex
+	jobs     []*jobState
+	holidays *Calendar
+	state    schedulerState
+	missed   []MissedRun
+
+	// stop is closed on shutdown; running tracks the runs in progress.
+	stop    chan struct{}
+	stopped bool
+	running sync.WaitGroup
+}
+
+// schedulerState is the part of the scheduler that survives restarts.
+type schedulerState struct {
+	// Paused maps a job name ("*" for all jobs) to the date, YYYY-MM-DD in
+	// the job's time zone, on which it resumes.
+	Paused map[string]string `json:"paused,omitempty"`
+
+	// LastSuccess maps a job name to the scheduled time of its last
+	// successful run.
+	LastSuccess map[string]time.Time `json:"last_success,omitempty"`
+}
+
+// MissedRun is a scheduled run that did not happen on time because no
+// instance was up. CaughtUp is set when it was sent late within the grace
+// window.
+type MissedRun struct {
+	Job         string    `json:"job"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	CaughtUp    bool      `json:"caught_up"`
+}
+
+// missedLookback bounds how far back startup looks for missed runs, and
+// maxMissed how many are kept for /health.
+const (
+	missedLookback = 7 * 24 * time.Hour
+	maxMissed      = 50
+)
+
+type jobState struct {
+	config         *JobConfig
+	next           time.Time
+	lastRun        time.Time
+	lastDuration   time.Duration
+	lastErr        error
+	runs           int
+	lastSkip       time.Time
+	lastSkipReason string
+	skips          int
+}
+
+// JobStatus is the admin view of a scheduled job.
+type JobStatus struct {
+	Name         string `json:"name"`
+	Kind         string `json:"kind"`
+	Schedule     string `json:"schedule"`
+	Timezone     string `json:"timezone"`
+	Destination  string `json:"destination"`
+	NextRun      string `json:"next_run,omitempty"`
+	LastRun      string `json:"last_run,omitempty"`
+	LastSuccess  string `json:"last_success,omitempty"`
+	LastDuration string `json:"last_duration,omitempty"`
+	LastError    string `json:"last_error,omitempty"`
+	Runs         int    `json:"runs"`
+	PausedUntil  string `json:"paused_until,omitempty"`
+	LastSkip     string `json:"last_skip,omitempty"`
+	SkipReason   string `json:"last_skip_reason,omitempty"`
+	Skips        int    `json:"skips"`
+}
+
+const allJobs = "*"
+
+var scheduler = &Scheduler{stop: make(chan struct{})}
+
+func startScheduler(jobs []*JobConfig, holidays *Calendar) {
+	scheduler.holidays = holidays
+	if err := store.load("scheduler", &scheduler.state); err != nil {
+		slog.Warn("Scheduler: Could not restore state, starting fresh", "error", err)
+	}
+
+	if linearAPIKey == "" && oauthConfig == nil {
+		slog.Info("Scheduler: LINEAR_API_KEY not set, scheduled reports disabled")
+		return
+	}
+	if holidays.size() > 0 {
+		slog.Info("Scheduler: Loaded holiday dates", "count", holidays.size())
+	}
+
+	for _, job := range jobs {
+		if job.Workspace == "" && linearAPIKey == "" {
+			slog.Info("Scheduler: LINEAR_API_KEY not set, job disabled", "job", job.Name)
+			continue
+		}
+		state := &jobState{config: job}
+		scheduler.mu.Lock()
+		scheduler.jobs = append(scheduler.jobs, state)
+		scheduler.mu.Unlock()
+
+		slog.Info("Scheduler: Registered job", "job", job.Name, "kind", job.Kind, "schedule", job.schedule.String())
+		go scheduler.loop(state)
+	}
+}
+
+func (s *Scheduler) loop(job *jobState) {
+	s.catchUp(job, time.Now())
+
+	for {
+		now := time.Now()
+		next := job.config.schedule.Next(now)
+		if next.IsZero() {
+			slog.Warn("Scheduler: Job never fires, stopping", "job", job.config.Name)
+			return
+		}
+
+		s.mu.Lock()
+		job.next = next
+		s.mu.Unlock()
+
+		duration := next.Sub(now)
+		slog.Info("Scheduler: Next run", "job", job.config.Name, "at", next.Format(time.RFC3339), "in", duration.Round(time.Minute).String())
+
+		timer := time.NewTimer(duration)
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.refreshState()
+		if reason := s.skipReason(job, next); reason != "" {
+			s.skip(job, next, reason)
+			continue
+		}
+
+		s.run(job, next, 0)
+	}
+}
+
+// skipReason explains why the run scheduled at t should not happen, or returns
+// "" if it should.
+func (s *Scheduler) skipReason(job *jobState, t time.Time) string {
+	cfg := job.config
+	local := t.In(cfg.schedule.Location())
+	day := local.Format(dateLayout)
+
+	s.mu.Lock()
+	for _, key := range []string{cfg.Name, allJobs} {
+		if until := s.state.Paused[key]; until != "" && day < until {
+			s.mu.Unlock()
+			return fmt.Sprintf("paused until %s", until)
+		}
+	}
+	s.mu.Unlock()
+
+	if name, ok := cfg.skipDates.lookup(local); ok {
+		return name
+	}
+	if cfg.Skip == nil || !cfg.Skip.IgnoreHolidays {
+		if name, ok := s.holidays.lookup(local); ok {
+			return fmt.Sprintf("holiday: %s", name)
+		}
+	}
+	return ""
+}
+
+func (s *Scheduler) skip(job *jobState, t time.Time, reason string) {
+	slog.Info("Scheduler: Skipping run", "job", job.config.Name, "scheduled_at", t.Format(time.RFC3339), "reason", reason)
+
+	s.mu.Lock()
+	job.lastSkip = t
+	job.lastSkipReason = reason
+	job.skips++
+	s.mu.Unlock()
+}
+
+// catchUp looks for runs that were due while no instance was up, or that
+// failed. The most recent one is sent late if it is still within the job's
+// grace window; the rest are recorded as missed.
+func (s *Scheduler) catchUp(job *jobState, now time.Time) {
+	cfg := job.config
+
+	s.refreshState()
+	s.mu.Lock()
+	since := s.state.LastSuccess[cfg.Name]
+	s.mu.Unlock()
+
+	firstStart := since.IsZero()
+	if firstStart {
+		since = now.Add(-cfg.grace)
+	}
+	if since.Before(now.Add(-missedLookback)) {
+		since = now.Add(-missedLookback)
+	}
+
+	ledger := map[string]*RunRecord{}
+	if err := store.load("runs", &ledger); err != nil {
+		slog.Warn("Scheduler: Could not check missed runs", "job", cfg.Name, "error", err)
+		return
+	}
+
+	var missed []time.Time
+	for t := cfg.schedule.Next(since); !t.IsZero() && !t.After(now); t = cfg.schedule.Next(t) {
+		if record := ledger[runKey(cfg.Name, t)]; (record != nil && record.Status != runFailed) || s.skipReason(job, t) != "" {
+			continue
+		}
+		missed = append(missed, t)
+	}
+	if len(missed) == 0 {
+		return
+	}
+	if firstStart {
+		// Nothing to compare against yet, so only the latest run within
+		// the grace window counts.
+		missed = missed[len(missed)-1:]
+	}
+
+	latest := missed[len(missed)-1]
+	catchUp := now.Sub(latest) <= cfg.grace
+
+	s.mu.Lock()
+	for _, t := range missed {
+		s.missed = append(s.missed, MissedRun{Job: cfg.Name, ScheduledAt: t, CaughtUp: catchUp && t.Equal(latest)})
+	}
+	if len(s.missed) > maxMissed {
+		s.missed = s.missed[len(s.missed)-maxMissed:]
+	}
+	s.mu.Unlock()
+
+	if !catchUp {
+		slog.Warn("Scheduler: Missed runs outside grace window", "job", cfg.Name, "missed", len(missed),
+			"last", latest.Format(time.RFC3339), "grace", cfg.grace.String())
+		return
+	}
+
+	slog.Info("Scheduler: Catching up missed run", "job", cfg.Name, "scheduled_at", latest.Format(time.RFC3339), "late", now.Sub(latest).Round(time.Second).String())
+	s.run(job, latest, cfg.grace)
+}
+
+// missedRuns returns the runs found missing since startup.
+func (s *Scheduler) missedRuns() []MissedRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MissedRun(nil), s.missed...)
+}
+
+// refreshState reloads the persisted state, which other replicas sharing
+// STATE_DIR may have changed.
+func (s *Scheduler) refreshState() {
+	var state schedulerState
+	if err := store.load("scheduler", &state); err != nil {
+		slog.Warn("Scheduler: Could not reload state", "error", err)
+		return
+	}
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+}
+
+// pause suspends a job (or all jobs) until the given YYYY-MM-DD date.
+func (s *Scheduler) pause(jobName, until string) error {
+	return s.updateState(func(state *schedulerState) {
+		if state.Paused == nil {
+			state.Paused = map[string]string{}
+		}
+		state.Paused[jobName] = until
+		slog.Info("Scheduler: Paused", "job", jobName, "until", until)
+	})
+}
+
+func (s *Scheduler) resume(jobName string) error {
+	return s.updateState(func(state *schedulerState) {
+		delete(state.Paused, jobName)
+		slog.Info("Scheduler: Resumed", "job", jobName)
+	})
+}
+
+func (s *Scheduler) updateState(fn func(state *schedulerState)) error {
+	var state schedulerState
+	err := store.update("scheduler", &state, func() error {
+		fn(&state)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Scheduler) pausedUntil(jobName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Paused[jobName]
+}
+
+func (s *Scheduler) hasJob(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.config.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jobByName returns the configured job with the given name, or nil.
+func jobByName(name string) *JobConfig {
+	for _, job := range appConfig.Jobs {
+		if job.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// jobOptions returns the report options of a job: its destination and filter,
+// and its workspace if it has one.
+func jobOptions(ctx context.Context, job *JobConfig) (ReportOptions, error) {
+	opts := ReportOptions{
+		Destination: appConfig.workspaceDestination(job.Workspace, job.Destination),
+		Filter:      job.Filter,
+		ctx:         ctx,
+	}
+	if job.Workspace != "" {
+		if opts.Workspace = workspaces.byKey(job.Workspace); opts.Workspace == nil {
+			return opts, fmt.Errorf("workspace %q is not installed", job.Workspace)
+		}
+	}
+	return opts, nil
+}
+
+// begin registers a run, unless the scheduler is shutting down.
+func (s *Scheduler) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return false
+	}
+	s.running.Add(1)
+	return true
+}
+
+// shutdown stops starting runs and waits for those in progress until ctx is
+// done. Runs that never started are caught up after the restart.
+func (s *Scheduler) shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run executes the job for the given scheduled time, unless another replica
+// has already claimed that run. A run that failed is retried if it is at
+// most retryWithin late.
+func (s *Scheduler) run(job *jobState, scheduledAt time.Time, retryWithin time.Duration) {
+	cfg := job.config
+	ctx := withLogAttrs(context.Background(), "job", cfg.Name, "scheduled_at", scheduledAt.Format(time.RFC3339))
+	if !s.begin() {
+		slog.InfoContext(ctx, "Scheduler: Shutting down, run left for catch-up")
+		return
+	}
+	defer s.running.Done()
+
+	claimed, existing, err := claimRun(cfg.Name, scheduledAt, retryWithin)
+	if err != nil {
+		// Without the ledger we can't coordinate; a duplicate is better
+		// than a missing report.
+		slog.WarnContext(ctx, "Scheduler: Could not claim run, running anyway", "error", err)
+	} else if !claimed {
+		s.skip(job, scheduledAt, fmt.Sprintf("%s by %s", existing.Status, existing.Holder))
+		return
+	}
+
+	slog.InfoContext(ctx, "Scheduler: Triggering job")
+	ctx, span := startSpan(ctx, "job "+cfg.Name, spanKindInternal,
+		"relay.job.name", cfg.Name,
+		"relay.job.kind", cfg.Kind,
+		"relay.job.scheduled_at", scheduledAt.Format(time.RFC3339),
+	)
+
+	start := time.Now()
+	opts, err := jobOptions(ctx, cfg)
+	if err == nil {
+		span.set("relay.destination", opts.destinationName())
+		err = jobKinds[cfg.Kind](opts)
+	}
+	span.end(err)
+
+	if claimed {
+		if finishErr := finishRun(cfg.Name, scheduledAt, err); finishErr != nil {
+			slog.WarnContext(ctx, "Scheduler: Could not record run", "error", finishErr)
+		}
+	}
+
+	s.mu.Lock()
+	job.lastRun = start
+	job.lastDuration = time.Since(start)
+	job.lastErr = err
+	job.runs++
+	s.mu.Unlock()
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Scheduler: Error running job", "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "Scheduler: Job completed successfully", "duration", time.Since(start).Round(time.Millisecond).String())
+	updateErr := s.updateState(func(state *schedulerState) {
+		if state.LastSuccess == nil {
+			state.LastSuccess = map[string]time.Time{}
+		}
+		if scheduledAt.After(state.LastSuccess[cfg.Name]) {
+			state.LastSuccess[cfg.Name] = scheduledAt.UTC()
+		}
+	})
+	if updateErr != nil {
+		slog.WarnContext(ctx, "Scheduler: Could not record success", "error", updateErr)
+	}
+}
+
+func (s *Scheduler) status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		cfg := job.config
+		loc := cfg.schedule.Location()
+		status := JobStatus{
+			Name:        cfg.Name,
+			Kind:        cfg.Kind,
+			Schedule:    cfg.Schedule,
+			Timezone:    loc.String(),
+			Destination: appConfig.workspaceDestination(cfg.Workspace, cfg.Destination).Name,
+			Runs:        job.runs,
+			Skips:       job.skips,
+		}
+		if !job.next.IsZero() {
+			status.NextRun = job.next.In(loc).Format(time.RFC3339)
+		}
+		if !job.lastRun.IsZero() {
+			status.LastRun = job.lastRun.In(loc).Format(time.RFC3339)
+			status.LastDuration = job.lastDuration.Round(time.Millisecond).String()
+		}
+		if job.lastErr != nil {
+			status.LastError = job.lastErr.Error()
+		}
+		if last := s.state.LastSuccess[cfg.Name]; !last.IsZero() {
+			status.LastSuccess = last.In(loc).Format(time.RFC3339)
+		}
+		if until := s.state.Paused[cfg.Name]; until != "" {
+			status.PausedUntil = until
+		} else {
+			status.PausedUntil = s.state.Paused[allJobs]
+		}
+		if !job.lastSkip.IsZero() {
+			status.LastSkip = job.lastSkip.In(loc).Format(time.RFC3339)
+			status.SkipReason = job.lastSkipReason
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+// jobMetric holds the values of one job's series on /metrics.
+type jobMetric struct {
+	name         string
+	lastSuccess  time.Time
+	lastDuration time.Duration
+}
+
+// jobMetrics returns each job's last success and run duration for /metrics.
+func (s *Scheduler) jobMetrics() []jobMetric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	metrics := make([]jobMetric, len(s.jobs))
+	for i, job := range s.jobs {
+		metrics[i] = jobMetric{
+			name:         job.config.Name,
+			lastSuccess:  s.state.LastSuccess[job.config.Name],
+			lastDuration: job.lastDuration,
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCatchUp(t *testing.T) {
+	prevConfig := appConfig
+	appConfig = &Config{Destinations: map[string]*Destination{defaultDestination: {Name: defaultDestination}}}
+	t.Cleanup(func() { appConfig = prevConfig })
+
+	var runs []time.Time
+	var runErr error
+	jobKinds["test"] = func(opts ReportOptions) error {
+		runs = append(runs, time.Now())
+		return runErr
+	}
+	t.Cleanup(func() { delete(jobKinds, "test") })
+
+	hourly, err := parseCron("0 * * * *", "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	latest := now.Truncate(time.Hour)
+	if latest.Equal(now) {
+		latest = latest.Add(-time.Hour)
+	}
+
+	tests := []struct {
+		name        string
+		lastSuccess time.Time
+		ledger      *RunRecord // for the latest run
+		grace       time.Duration
+		runErr      error
+		wantRuns    int
+		wantMissed  int
+		wantCaught  bool
+		wantLedger  string
+	}{
+		{name: "first start", grace: time.Hour, wantRuns: 1, wantMissed: 1, wantCaught: true, wantLedger: runDone},
+		{name: "nothing missed", lastSuccess: latest, grace: time.Hour},
+		{name: "sent by another replica", ledger: &RunRecord{Status: runDone, Holder: "other"}, grace: time.Hour, wantLedger: runDone},
+		{name: "held by another replica", ledger: &RunRecord{Status: runRunning, Holder: "other", LeaseUntil: now.Add(time.Minute)}, grace: time.Hour, wantLedger: runRunning},
+		{name: "failed before a restart", ledger: &RunRecord{Status: runFailed, Holder: "other"}, grace: time.Hour, wantRuns: 1, wantMissed: 1, wantCaught: true, wantLedger: runDone},
+		{name: "failed again", ledger: &RunRecord{Status: runFailed, Holder: "other"}, grace: time.Hour, runErr: errors.New("linear is down"), wantRuns: 1, wantMissed: 1, wantCaught: true, wantLedger: runFailed},
+		{name: "several missed", lastSuccess: latest.Add(-3 * time.Hour), grace: time.Hour, wantRuns: 1, wantMissed: 3, wantCaught: true, wantLedger: runDone},
+		{name: "outside grace", lastSuccess: latest.Add(-3 * time.Hour), grace: 0, wantMissed: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestStore(t)
+			runs, runErr = nil, tt.runErr
+			if !tt.lastSuccess.IsZero() {
+				store.save("scheduler", schedulerState{LastSuccess: map[string]time.Time{"hourly": tt.lastSuccess}})
+			}
+			if tt.ledger != nil {
+				tt.ledger.Job, tt.ledger.ScheduledAt = "hourly", latest.UTC()
+				store.save("runs", map[string]*RunRecord{runKey("hourly", latest): tt.ledger})
+			}
+
+			s := &Scheduler{stop: make(chan struct{})}
+			job := &jobState{config: &JobConfig{Name: "hourly", Kind: "test", schedule: hourly, grace: tt.grace}}
+			s.catchUp(job, now)
+
+			if len(runs) != tt.wantRuns {
+				t.Errorf("ran %d times, want %d", len(runs), tt.wantRuns)
+			}
+			missed := s.missedRuns()
+			if len(missed) != tt.wantMissed {
+				t.Fatalf("missed = %+v, want %d runs", missed, tt.wantMissed)
+			}
+			if len(missed) > 0 {
+				last := missed[len(missed)-1]
+				if !last.ScheduledAt.Equal(latest) || last.CaughtUp != tt.wantCaught {
+					t.Errorf("last missed = %+v, want %s caught up %v", last, latest, tt.wantCaught)
+				}
+			}
+
+			ledger := map[string]*RunRecord{}
+			store.load("runs", &ledger)
+			status := ""
+			if r := ledger[runKey("hourly", latest)]; r != nil {
+				status = r.Status
+			}
+			if status != tt.wantLedger {
+				t.Errorf("ledger status = %q, want %q", status, tt.wantLedger)
+			}
+
+			var state schedulerState
+			store.load("scheduler", &state)
+			wantSuccess := tt.lastSuccess
+			if tt.wantRuns > 0 && tt.runErr == nil {
+				wantSuccess = latest
+			}
+			if got := state.LastSuccess["hourly"]; !got.Equal(wantSuccess) {
+				t.Errorf("last success = %s, want %s", got, wantSuccess)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// GRACEFUL SHUTDOWN
+// ============================================================================
+
+// defaultShutdownTimeout stays below the 30s Docker waits between SIGTERM and
+// SIGKILL.
+const defaultShutdownTimeout = 25 * time.Second
+
+// parseShutdownTimeout reads SHUTDOWN_TIMEOUT, a Go duration or seconds.
+func parseShutdownTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultShutdownTimeout, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid SHUTDOWN_TIMEOUT %q: want a duration like 25s", value)
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid SHUTDOWN_TIMEOUT %q: must be positive", value)
+	}
+	return d, nil
+}
+
+// shutdown stops accepting requests and starting scheduled runs, then waits up
+// to timeout for the requests and runs in progress. Discord deliveries still
+// going at 80% of the timeout are cancelled and spooled to the outbox, so
+// their reports finish in time and the messages are sent after the restart.
+func shutdown(server *http.Server, timeout time.Duration) {
+	slog.Info("Shutting down", "timeout", timeout.String())
+
+	spoolTimer := time.AfterFunc(timeout*4/5, func() {
+		slog.Warn("Shutdown: Drain deadline passed, spooling remaining deliveries")
+		stopDeliveries()
+	})
+	defer spoolTimer.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Shutdown: Requests still running", "error", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := scheduler.shutdown(ctx); err != nil {
+			slog.Error("Shutdown: Jobs still running", "error", err)
+		}
+	}()
+	wg.Wait()
+
+	// Spans of the last requests are worth a moment past the deadline.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelFlush()
+	tracer.flush(flushCtx)
+
+	slog.Info("Shutdown complete", "spooled", spooledMessages.Load())
+}
+
+// ----------------------------------------------------------------------------
+// Outbox (delivery spool)
+// ----------------------------------------------------------------------------
+
+// Spooled messages are dropped once they are too old to be useful or keep
+// failing, so that one bad message isn't retried on every startup forever.
+const (
+	outboxMaxAttempts = 5
+	outboxMaxAge      = 24 * time.Hour
+)
+
+// OutboxMessage is a Discord message that could not be sent before shutdown.
+// It names the destination rather than storing its webhook URL, which is a
+// secret and may have changed by the time it is replayed.
+type OutboxMessage struct {
+	Destination string          `json:"destination,omitempty"`
+	DiscordUser string          `json:"discord_user,omitempty"` // direct messages
+	Payload     *DiscordWebhook `json:"payload"`
+	Files       []DiscordFile   `json:"files,omitempty"`
+	Traceparent string          `json:"traceparent,omitempty"`
+	QueuedAt    time.Time       `json:"queued_at"`
+	Attempts    int             `json:"attempts,omitempty"` // failed replays
+}
+
+var (
+	// deliveries is cancelled when the shutdown drain deadline passes.
+	deliveries, stopDeliveries = context.WithCancel(context.Background())
+
+	// spooledMessages counts the messages waiting in the outbox.
+	spooledMessages atomic.Int64
+)
+
+// deliveryContext returns a copy of ctx that is also cancelled when the drain
+// deadline passes.
+func deliveryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(deliveries, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// spooling reports whether deliveries go to the outbox instead of Discord.
+func spooling() bool {
+	return deliveries.Err() != nil
+}
+
+// interrupted reports whether a failed delivery may succeed after a restart:
+// it was cancelled or never got a response. Errors Discord answered with,
+// such as a 403 from a user who closed their DMs, are final.
+func interrupted(err error) bool {
+	var urlErr *url.Error
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &urlErr)
+}
+
+// spool appends msg to the outbox.
+func spool(ctx context.Context, msg *OutboxMessage) error {
+	if store == nil {
+		return fmt.Errorf("no state store to spool the message to")
+	}
+	msg.Files = msg.Payload.Files
+	msg.QueuedAt = time.Now().UTC()
+	if span := spanFromContext(ctx); span != nil {
+		msg.Traceparent = fmt.Sprintf("00-%x-%x-01", span.traceID, span.spanID)
+	}
+
+	var outbox []*OutboxMessage
+	err := store.update("outbox", &outbox, func() error {
+		outbox = append(outbox, msg)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to spool discord message: %w", err)
+	}
+	spooledMessages.Store(int64(len(outbox)))
+	spanFromContext(ctx).set("relay.spooled", true)
+	slog.WarnContext(ctx, "Outbox: Spooled message for delivery after restart", "destination", msg.Destination, "queued", len(outbox))
+	return nil
+}
+
+// replayOutbox sends the messages spooled by the previous shutdown, in order.
+// It takes the whole outbox at once so that replicas sharing STATE_DIR don't
+// send a message twice, and puts back the ones that fail until they run out
+// of attempts or grow older than outboxMaxAge.
+func replayOutbox() {
+	var claimed, outbox []*OutboxMessage
+	err := store.update("outbox", &outbox, func() error {
+		claimed, outbox = outbox, nil
+		return nil
+	})
+	if err != nil {
+		slog.Error("Outbox: Could not load spooled messages", "error", err)
+		return
+	}
+	if len(claimed) == 0 {
+		return
+	}
+	slog.Info("Outbox: Replaying spooled messages", "count", len(claimed))
+
+	var failed []*OutboxMessage
+	for _, msg := range claimed {
+		if age := time.Since(msg.QueuedAt); age > outboxMaxAge {
+			slog.Warn("Outbox: Dropping expired message", "destination", msg.Destination, "user", msg.DiscordUser, "queued_at", msg.QueuedAt, "attempts", msg.Attempts)
+			continue
+		}
+		err := msg.send()
+		if err == nil {
+			continue
+		}
+		msg.Attempts++
+		if msg.Attempts >= outboxMaxAttempts {
+			slog.Warn("Outbox: Dropping message after repeated failures", "destination", msg.Destination, "user", msg.DiscordUser, "queued_at", msg.QueuedAt, "attempts", msg.Attempts, "error", err)
+			continue
+		}
+		slog.Error("Outbox: Could not replay message", "destination", msg.Destination, "user", msg.DiscordUser, "queued_at", msg.QueuedAt, "attempts", msg.Attempts, "error", err)
+		failed = append(failed, msg)
+	}
+	if len(failed) == 0 {
+		slog.Info("Outbox: Replay complete", "count", len(claimed))
+		return
+	}
+
+	err = store.update("outbox", &outbox, func() error {
+		outbox = append(failed, outbox...)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Outbox: Could not keep failed messages", "count", len(failed), "error", err)
+		return
+	}
+	spooledMessages.Store(int64(len(outbox)))
+}
+
+// send delivers a spooled message, continuing the trace that spooled it.
+func (msg *OutboxMessage) send() (err error) {
+	ctx, span := startSpan(remoteParent(context.Background(), msg.Traceparent), "outbox.replay", spanKindInternal,
+		"relay.destination", msg.Destination)
+	defer func() { span.end(err) }()
+
+	msg.Payload.Files = msg.Files
+	if msg.DiscordUser != "" {
+		return sendDirectMessage(ctx, msg.DiscordUser, msg.Payload)
+	}
+	dest := appConfig.Destinations[msg.Destination]
+	if dest == nil {
+		// Retrying won't help; the destination was removed from the config.
+		slog.WarnContext(ctx, "Outbox: Dropping message for unknown destination", "destination", msg.Destination)
+		return nil
+	}
+	return sendToWebhook(ctx, dest, msg.Payload)
+}
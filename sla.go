@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// ============================================================================
+// SLA AND DUE DATES
+// ============================================================================
+
+// SLAConfig is the "sla" section of the config.
+type SLAConfig struct {
+	// WarnHours sends the first alert this many hours before an issue's due
+	// date or SLA breach. Defaults to 24.
+	WarnHours int `json:"warn_hours,omitempty"`
+}
+
+const defaultSLAWarnHours = 24
+
+// slaAlertRetention is how long sent alerts are remembered after their
+// deadline. Deadlines further in the past are not alerted on.
+const slaAlertRetention = 30 * 24 * time.Hour
+
+// slaAlertBatch is the most embeds Discord accepts in one message.
+const slaAlertBatch = 10
+
+func (c *SLAConfig) warnBefore() time.Duration {
+	hours := defaultSLAWarnHours
+	if c != nil && c.WarnHours > 0 {
+		hours = c.WarnHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// Deadline is a time an open issue should be closed by: its due date or its
+// SLA breach.
+type Deadline struct {
+	Kind string // "due" or "sla"
+	At   time.Time
+}
+
+const (
+	deadlineDue = "due"
+	deadlineSLA = "sla"
+)
+
+// issueDueAt parses DueDate as the end of that day in UTC.
+func issueDueAt(issue Issue) (time.Time, bool) {
+	t, err := time.Parse(dateLayout, issue.DueDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.Add(24 * time.Hour), true
+}
+
+func issueDeadlines(issue Issue) []Deadline {
+	var deadlines []Deadline
+	if due, ok := issueDueAt(issue); ok {
+		deadlines = append(deadlines, Deadline{Kind: deadlineDue, At: due})
+	}
+	if issue.SLABreachesAt != nil {
+		deadlines = append(deadlines, Deadline{Kind: deadlineSLA, At: *issue.SLABreachesAt})
+	}
+	return deadlines
+}
+
+// isOverdue reports whether an open issue's due date has passed.
+func isOverdue(issue Issue, now time.Time) bool {
+	due, ok := issueDueAt(issue)
+	return ok && now.After(due)
+}
+
+// missedDeadline returns the earliest deadline of the issue that has passed.
+func missedDeadline(issue Issue, now time.Time) (Deadline, bool) {
+	var missed Deadline
+	found := false
+	for _, d := range issueDeadlines(issue) {
+		if now.After(d.At) && (!found || d.At.Before(missed.At)) {
+			missed, found = d, true
+		}
+	}
+	return missed, found
+}
+
+// SLAAlert is one alert about one deadline of an issue.
+type SLAAlert struct {
+	Issue    Issue
+	Deadline Deadline
+	Breached bool
+}
+
+// slaAlertRecord remembers the last alert sent for a deadline, so each
+// deadline gets at most one warning and one breach alert.
+type slaAlertRecord struct {
+	Deadline time.Time `json:"deadline"`
+	Breached bool      `json:"breached"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+func slaAlertKey(opts ReportOptions, issue Issue, d Deadline) string {
+	return fmt.Sprintf("%s:%s@%s", d.Kind, issue.ID, opts.destinationName())
+}
+
+// findSLAAlerts returns the alerts that are due and not sent yet, soonest
+// deadline first. A moved deadline starts over with a new warning.
+func findSLAAlerts(opts ReportOptions, issues []Issue, sent map[string]slaAlertRecord, warn time.Duration, now time.Time) []SLAAlert {
+	var alerts []SLAAlert
+	for _, issue := range issues {
+		for _, d := range issueDeadlines(issue) {
+			if now.Before(d.At.Add(-warn)) || now.Sub(d.At) > slaAlertRetention {
+				continue
+			}
+			breached := !now.Before(d.At)
+			if rec, ok := sent[slaAlertKey(opts, issue, d)]; ok && rec.Deadline.Equal(d.At) && (rec.Breached || !breached) {
+				continue
+			}
+			alerts = append(alerts, SLAAlert{Issue: issue, Deadline: d, Breached: breached})
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Deadline.At.Before(alerts[j].Deadline.At) })
+	return alerts
+}
+
+func loadSLAAlerts() (map[string]slaAlertRecord, error) {
+	sent := map[string]slaAlertRecord{}
+	if store == nil {
+		return sent, nil
+	}
+	err := store.load("sla-alerts", &sent)
+	return sent, err
+}
+
+// markSLAAlerts records sent alerts and drops records of deadlines that are
+// too old to alert on again.
+func markSLAAlerts(opts ReportOptions, alerts []SLAAlert, now time.Time) error {
+	if store == nil || opts.dryRun() {
+		return nil
+	}
+	sent := map[string]slaAlertRecord{}
+	return store.update("sla-alerts", &sent, func() error {
+		for _, a := range alerts {
+			sent[slaAlertKey(opts, a.Issue, a.Deadline)] = slaAlertRecord{Deadline: a.Deadline.At, Breached: a.Breached, SentAt: now.UTC()}
+		}
+		for key, rec := range sent {
+			if now.Sub(rec.Deadline) > slaAlertRetention {
+				delete(sent, key)
+			}
+		}
+		return nil
+	})
+}
+
+// fetchIssuesWithDeadlines returns the open issues that have a due date or an
+// SLA.
+func fetchIssuesWithDeadlines(opts ReportOptions) ([]Issue, error) {
+	notClosed := object("state", object("type", object("nin", []string{"completed", "canceled"})))
+	issues, err := opts.linear().Issues(opts.context(), linear.IssuesOptions{Filter: issueFilterVariable(notClosed, opts.Filter)})
+	if err != nil {
+		return nil, err
+	}
+
+	// Linear can't filter on the SLA, so issues without a deadline are
+	// dropped here.
+	var result []Issue
+	for _, issue := range issues {
+		if len(issueDeadlines(issue)) > 0 {
+			result = append(result, issue)
+		}
+	}
+	return result, nil
+}
+
+func handleReportSLA(w http.ResponseWriter, r *http.Request) {
+	opts, ok := reportOptions(w, r)
+	if !ok {
+		return
+	}
+	if err := generateSLAAlerts(opts); err != nil {
+		slog.ErrorContext(opts.context(), "Error checking SLAs", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if opts.dryRun() {
+		writePreview(w, r, opts.Preview)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "sla_checked"})
+}
+
+// generateSLAAlerts posts an alert when an issue comes within the warning
+// window of its due date or SLA breach, and another when the deadline passes.
+// Nothing is posted when no alert is due.
+func generateSLAAlerts(opts ReportOptions) error {
+	slog.InfoContext(opts.context(), "Fetching issues for SLA check...")
+	issues, err := fetchIssuesWithDeadlines(opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch issues: %w", err)
+	}
+
+	sent, err := loadSLAAlerts()
+	if err != nil {
+		return fmt.Errorf("failed to load sent SLA alerts: %w", err)
+	}
+
+	now := time.Now()
+	warn := appConfig.SLA.warnBefore()
+	alerts := findSLAAlerts(opts, issues, sent, warn, now)
+	slog.InfoContext(opts.context(), "SLA check: Found alerts", "issues", len(issues), "alerts", len(alerts))
+
+	for start := 0; start < len(alerts); start += slaAlertBatch {
+		end := start + slaAlertBatch
+		if end > len(alerts) {
+			end = len(alerts)
+		}
+		batch := alerts[start:end]
+		embeds := make([]DiscordEmbed, len(batch))
+		for i, a := range batch {
+			embeds[i] = slaAlertEmbed(a, warn, now)
+		}
+		if err := opts.send(&DiscordWebhook{
+			Username:  "Linear SLA",
+			AvatarURL: linearAvatarURL,
+			Embeds:    embeds,
+		}); err != nil {
+			return err
+		}
+		if err := markSLAAlerts(opts, batch, now); err != nil {
+			slog.WarnContext(opts.context(), "SLA check: Could not record sent alerts", "error", err)
+		}
+	}
+	return nil
+}
+
+// slaAlertEmbed colors alerts by urgency: yellow when the deadline is in the
+// warning window, orange in its last quarter, red once breached.
+func slaAlertEmbed(a SLAAlert, warn time.Duration, now time.Time) DiscordEmbed {
+	left := a.Deadline.At.Sub(now)
+
+	var headline string
+	color := ColorYellow
+	switch {
+	case a.Breached && a.Deadline.Kind == deadlineSLA:
+		headline = fmt.Sprintf("🚨 **SLA breached** %s ago", formatAge(-left))
+		color = ColorRed
+	case a.Breached:
+		headline = fmt.Sprintf("⏰ **Overdue** (was due %s)", a.Deadline.At.Add(-24*time.Hour).Format("Mon Jan 2"))
+		color = ColorRed
+	case a.Deadline.Kind == deadlineSLA:
+		headline = fmt.Sprintf("⏳ **SLA breaches in %s** (%s)", formatAge(left), a.Deadline.At.UTC().Format("Mon Jan 2 15:04 MST"))
+	default:
+		headline = fmt.Sprintf("⏳ **Due in %s** (%s)", formatAge(left), a.Deadline.At.Add(-24*time.Hour).Format("Mon Jan 2"))
+	}
+	if !a.Breached && left <= warn/4 {
+		color = ColorOrange
+	}
+
+	issue := a.Issue
+	details := []string{
+		fmt.Sprintf("%s %s", getPriorityEmoji(issue.Priority), issue.PriorityLabel),
+		fmt.Sprintf("%s %s", getStateEmoji(issue.State.Type), issue.State.Name),
+		"👤 " + assigneeName(issue.Assignee),
+		"👥 " + issue.Team.Name,
+	}
+
+	return DiscordEmbed{
+		Title:       truncate(fmt.Sprintf("%s - %s", issue.Identifier, issue.Title), 250),
+		URL:         issue.URL,
+		Description: headline + "\n" + strings.Join(details, " | "),
+		Color:       color,
+		Timestamp:   now.UTC().Format(time.RFC3339),
+	}
+}
+
+// formatOverdue lists the issues past their due date or SLA for the digest,
+// longest overdue first. It returns "" when there are none.
+func formatOverdue(issues []Issue, now time.Time) string {
+	type overdueIssue struct {
+		Issue
+		Deadline Deadline
+	}
+	var overdue []overdueIssue
+	for _, issue := range issues {
+		if d, ok := missedDeadline(issue, now); ok {
+			overdue = append(overdue, overdueIssue{issue, d})
+		}
+	}
+	sort.SliceStable(overdue, func(i, j int) bool { return overdue[i].Deadline.At.Before(overdue[j].Deadline.At) })
+
+	const maxLines = 10
+	var lines []string
+	for i, o := range overdue {
+		if i == maxLines {
+			lines = append(lines, fmt.Sprintf("*... and %d more*", len(overdue)-maxLines))
+			break
+		}
+		late := "due " + o.DueDate
+		if o.Deadline.Kind == deadlineSLA {
+			late = "SLA breached"
+		}
+		lines = append(lines, fmt.Sprintf("%s [**%s**](%s) - %s · %s, %s late (%s)", getPriorityEmoji(o.Priority), o.Identifier, o.URL,
+			truncate(o.Title, 40), late, formatAge(now.Sub(o.Deadline.At)), assigneeName(o.Assignee)))
+	}
+	return strings.Join(lines, "\n")
+}
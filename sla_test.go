@@ -0,0 +1,115 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIssueDeadlines(t *testing.T) {
+	now := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	sla := func(d time.Duration) *time.Time { at := now.Add(d); return &at }
+
+	tests := []struct {
+		name        string
+		issue       Issue
+		wantOverdue bool
+		wantMissed  string // kind of the earliest missed deadline, or ""
+	}{
+		{"no deadlines", Issue{}, false, ""},
+		{"invalid due date", Issue{DueDate: "soon"}, false, ""},
+		{"due today", Issue{DueDate: "2024-06-03"}, false, ""},
+		{"due yesterday", Issue{DueDate: "2024-06-02"}, true, deadlineDue},
+		{"SLA ahead", Issue{SLABreachesAt: sla(time.Hour)}, false, ""},
+		{"SLA breached", Issue{SLABreachesAt: sla(-time.Hour)}, false, deadlineSLA},
+		{"SLA breached before due date", Issue{DueDate: "2024-06-01", SLABreachesAt: sla(-72 * time.Hour)}, true, deadlineSLA},
+		{"due date before SLA breach", Issue{DueDate: "2024-06-01", SLABreachesAt: sla(-time.Hour)}, true, deadlineDue},
+	}
+	for _, tt := range tests {
+		if got := isOverdue(tt.issue, now); got != tt.wantOverdue {
+			t.Errorf("%s: isOverdue = %v, want %v", tt.name, got, tt.wantOverdue)
+		}
+		d, ok := missedDeadline(tt.issue, now)
+		if ok != (tt.wantMissed != "") || d.Kind != tt.wantMissed {
+			t.Errorf("%s: missedDeadline = %+v, %v; want %q", tt.name, d, ok, tt.wantMissed)
+		}
+	}
+
+	// A due date is the end of that day in UTC.
+	if at, _ := issueDueAt(Issue{DueDate: "2024-06-03"}); !at.Equal(time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("issueDueAt = %s", at)
+	}
+}
+
+func TestFindSLAAlerts(t *testing.T) {
+	now := time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)
+	warn := 24 * time.Hour
+	opts := ReportOptions{}
+	at := func(d time.Duration) *time.Time { t := now.Add(d); return &t }
+
+	soon := Issue{ID: "soon", SLABreachesAt: at(2 * time.Hour)}
+	later := Issue{ID: "later", SLABreachesAt: at(48 * time.Hour)}
+	breached := Issue{ID: "breached", SLABreachesAt: at(-time.Hour)}
+	ancient := Issue{ID: "ancient", SLABreachesAt: at(-slaAlertRetention - time.Hour)}
+	dueTomorrow := Issue{ID: "due", DueDate: "2024-06-03"} // due at midnight, in 12h
+
+	key := func(issue Issue) string { return slaAlertKey(opts, issue, issueDeadlines(issue)[0]) }
+
+	tests := []struct {
+		name string
+		sent map[string]slaAlertRecord
+		want []string // "<id>:<kind>[!]", ! for breached
+	}{
+		{
+			"nothing sent",
+			nil,
+			[]string{"breached:sla!", "soon:sla", "due:due"},
+		},
+		{
+			"warnings sent",
+			map[string]slaAlertRecord{
+				key(soon):        {Deadline: *soon.SLABreachesAt},
+				key(dueTomorrow): {Deadline: time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC)},
+			},
+			[]string{"breached:sla!"},
+		},
+		{
+			"warning sent before breach",
+			map[string]slaAlertRecord{key(breached): {Deadline: *breached.SLABreachesAt}},
+			[]string{"breached:sla!", "soon:sla", "due:due"},
+		},
+		{
+			"breach sent",
+			map[string]slaAlertRecord{key(breached): {Deadline: *breached.SLABreachesAt, Breached: true}},
+			[]string{"soon:sla", "due:due"},
+		},
+		{
+			"deadline moved",
+			map[string]slaAlertRecord{key(soon): {Deadline: now.Add(-time.Hour), Breached: true}},
+			[]string{"breached:sla!", "soon:sla", "due:due"},
+		},
+	}
+	for _, tt := range tests {
+		alerts := findSLAAlerts(opts, []Issue{later, dueTomorrow, soon, breached, ancient}, tt.sent, warn, now)
+		var got []string
+		for _, a := range alerts {
+			s := a.Issue.ID + ":" + a.Deadline.Kind
+			if a.Breached {
+				s += "!"
+			}
+			got = append(got, s)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: alerts = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSLAWarnBefore(t *testing.T) {
+	if got := (*SLAConfig)(nil).warnBefore(); got != 24*time.Hour {
+		t.Errorf("default warnBefore = %s", got)
+	}
+	if got := (&SLAConfig{WarnHours: 4}).warnBefore(); got != 4*time.Hour {
+		t.Errorf("warnBefore = %s, want 4h", got)
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// ============================================================================
+// SNAPSHOTS & DELTAS
+// ============================================================================
+
+// Snapshot is the set of open issues a digest was built from. Snapshots are
+// stored per scope (the filter that produced them) under
+// STATE_DIR/snapshots/<scope>/ and compared to produce day-over-day deltas.
+type Snapshot struct {
+	Scope   string          `json:"scope"`
+	TakenAt time.Time       `json:"taken_at"`
+	Issues  []SnapshotIssue `json:"issues"`
+}
+
+// SnapshotIssue is the part of an Issue that deltas are computed from.
+type SnapshotIssue struct {
+	ID            string    `json:"id"`
+	Identifier    string    `json:"identifier"`
+	Title         string    `json:"title"`
+	URL           string    `json:"url"`
+	State         string    `json:"state"`
+	StateType     string    `json:"state_type"`
+	Assignee      string    `json:"assignee"`
+	Priority      int       `json:"priority"`
+	PriorityLabel string    `json:"priority_label"`
+	Team          string    `json:"team"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+const (
+	// snapshotRetention is how long snapshots are kept.
+	snapshotRetention = 90 * 24 * time.Hour
+
+	// snapshotBaselineAge is how old a snapshot must be to serve as the
+	// baseline when a report has no record of its previous run.
+	snapshotBaselineAge = 20 * time.Hour
+
+	snapshotNameLayout = "20060102T150405.000Z"
+)
+
+// snapshotScope names the set of issues a filter selects, so that reports
+// over different teams never compare against each other.
+func snapshotScope(filter *IssueFilter) string {
+	if filter == nil {
+		return "all"
+	}
+	data, _ := json.Marshal(filter)
+	if string(data) == "{}" {
+		return "all"
+	}
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// snapshotScope is the snapshot scope of the report's filter, prefixed with
+// the workspace for workspaces installed through OAuth.
+func (o ReportOptions) snapshotScope() string {
+	scope := snapshotScope(o.Filter)
+	if o.Workspace != nil {
+		scope = o.Workspace.Key + "-" + scope
+	}
+	return scope
+}
+
+func newSnapshot(scope string, issues []Issue, takenAt time.Time) *Snapshot {
+	snap := &Snapshot{Scope: scope, TakenAt: takenAt.UTC(), Issues: make([]SnapshotIssue, len(issues))}
+	for i, issue := range issues {
+		snap.Issues[i] = SnapshotIssue{
+			ID:            issue.ID,
+			Identifier:    issue.Identifier,
+			Title:         issue.Title,
+			URL:           issue.URL,
+			State:         issue.State.Name,
+			StateType:     issue.State.Type,
+			Assignee:      assigneeName(issue.Assignee),
+			Priority:      issue.Priority,
+			PriorityLabel: issue.PriorityLabel,
+			Team:          issue.Team.Key,
+			CreatedAt:     issue.CreatedAt,
+		}
+	}
+	return snap
+}
+
+// saveSnapshot stores the issues as a new snapshot and prunes expired ones.
+func saveSnapshot(opts ReportOptions, issues []Issue) error {
+	if store == nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	scope := opts.snapshotScope()
+	snap := newSnapshot(scope, issues, now)
+
+	if err := store.save(snapshotName(scope, now), snap); err != nil {
+		return err
+	}
+
+	names, err := store.list("snapshots/" + scope)
+	if err != nil {
+		return err
+	}
+	cutoff := now.Add(-snapshotRetention).Format(snapshotNameLayout)
+	for _, name := range names {
+		if name[strings.LastIndex(name, "/")+1:] >= cutoff {
+			break
+		}
+		if err := store.remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func snapshotName(scope string, t time.Time) string {
+	return fmt.Sprintf("snapshots/%s/%s", scope, t.UTC().Format(snapshotNameLayout))
+}
+
+func loadSnapshot(name string) (*Snapshot, error) {
+	var snap Snapshot
+	if err := store.load(name, &snap); err != nil {
+		return nil, err
+	}
+	if snap.TakenAt.IsZero() {
+		return nil, nil
+	}
+	return &snap, nil
+}
+
+// latestSnapshotName returns the newest snapshot of the scope taken at or
+// before t, or "" if there is none.
+func latestSnapshotName(scope string, t time.Time) (string, error) {
+	names, err := store.list("snapshots/" + scope)
+	if err != nil {
+		return "", err
+	}
+	limit := snapshotName(scope, t)
+	for i := len(names) - 1; i >= 0; i-- {
+		if names[i] <= limit {
+			return names[i], nil
+		}
+	}
+	return "", nil
+}
+
+// dailySnapshots returns the last snapshot of each UTC day since the given
+// time, oldest first.
+func dailySnapshots(scope string, since time.Time) ([]*Snapshot, error) {
+	names, err := store.list("snapshots/" + scope)
+	if err != nil {
+		return nil, err
+	}
+
+	first := snapshotName(scope, since)
+	var daily []string
+	for i, name := range names {
+		if name < first {
+			continue
+		}
+		// Names sort by time, so the last of a day is the one followed by
+		// another day's (or nothing).
+		day := name[strings.LastIndex(name, "/")+1:][:8]
+		if i+1 < len(names) && strings.HasPrefix(names[i+1][strings.LastIndex(names[i+1], "/")+1:], day) {
+			continue
+		}
+		daily = append(daily, name)
+	}
+
+	var snaps []*Snapshot
+	for _, name := range daily {
+		snap, err := loadSnapshot(name)
+		if err != nil {
+			return nil, err
+		}
+		if snap != nil {
+			snaps = append(snaps, snap)
+		}
+	}
+	return snaps, nil
+}
+
+// SnapshotDiff describes what changed between two snapshots.
+type SnapshotDiff struct {
+	Since time.Time
+
+	// Count deltas keyed by status name, assignee and priority label.
+	Status   map[string]int
+	Assignee map[string]int
+	Priority map[string]int
+
+	// State types and priority labels of the baseline, to show the groups
+	// that have no issues left.
+	StateTypes     map[string]string
+	PriorityLabels map[int]string
+
+	Opened     []SnapshotIssue // new in scope and created after Since
+	Completed  []SnapshotIssue
+	Canceled   []SnapshotIssue
+	Removed    []SnapshotIssue // left the scope for another reason (moved, deleted)
+	Reassigned []Reassignment
+}
+
+type Reassignment struct {
+	Issue SnapshotIssue
+	From  string
+	To    string
+}
+
+func diffSnapshots(prev, cur *Snapshot) *SnapshotDiff {
+	diff := &SnapshotDiff{
+		Since:    prev.TakenAt,
+		Status:   map[string]int{},
+		Assignee: map[string]int{},
+		Priority: map[string]int{},
+
+		StateTypes:     map[string]string{},
+		PriorityLabels: map[int]string{},
+	}
+
+	before := make(map[string]SnapshotIssue, len(prev.Issues))
+	for _, issue := range prev.Issues {
+		before[issue.ID] = issue
+		diff.Status[issue.State]--
+		diff.Assignee[issue.Assignee]--
+		diff.Priority[issue.PriorityLabel]--
+		diff.StateTypes[issue.State] = issue.StateType
+		diff.PriorityLabels[issue.Priority] = issue.PriorityLabel
+	}
+
+	for _, issue := range cur.Issues {
+		diff.Status[issue.State]++
+		diff.Assignee[issue.Assignee]++
+		diff.Priority[issue.PriorityLabel]++
+
+		old, existed := before[issue.ID]
+		delete(before, issue.ID)
+		switch {
+		case !existed && issue.CreatedAt.After(prev.TakenAt):
+			diff.Opened = append(diff.Opened, issue)
+		case existed && old.Assignee != issue.Assignee:
+			diff.Reassigned = append(diff.Reassigned, Reassignment{Issue: issue, From: old.Assignee, To: issue.Assignee})
+		}
+	}
+
+	// What is left in before is no longer open in this scope.
+	for _, issue := range before {
+		diff.Removed = append(diff.Removed, issue)
+	}
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Identifier < diff.Removed[j].Identifier })
+
+	return diff
+}
+
+// resolveClosed looks up the issues that left the scope and sorts them into
+// completed, canceled and removed.
+func (d *SnapshotDiff) resolveClosed(ctx context.Context, client *linear.Client) error {
+	if len(d.Removed) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(d.Removed))
+	for i, issue := range d.Removed {
+		ids[i] = issue.ID
+	}
+	states, err := fetchIssueStateTypes(ctx, client, ids)
+	if err != nil {
+		return err
+	}
+
+	var removed []SnapshotIssue
+	for _, issue := range d.Removed {
+		switch states[issue.ID] {
+		case "completed":
+			d.Completed = append(d.Completed, issue)
+		case "canceled":
+			d.Canceled = append(d.Canceled, issue)
+		default:
+			removed = append(removed, issue)
+		}
+	}
+	d.Removed = removed
+	return nil
+}
+
+// fetchIssueStateTypes returns the current state type of each issue by ID.
+// Issues that no longer exist are missing from the result.
+func fetchIssueStateTypes(ctx context.Context, client *linear.Client, ids []string) (map[string]string, error) {
+	result := make(map[string]string, len(ids))
+	for start := 0; start < len(ids); start += 100 {
+		end := start + 100
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		issues, err := client.Issues(ctx, linear.IssuesOptions{
+			Filter:          object("id", object("in", ids[start:end])),
+			IncludeArchived: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			result[issue.ID] = issue.State.Type
+		}
+	}
+	return result, nil
+}
+
+// digestMarkKey identifies a recurring report: the same scope posted to two
+// channels keeps two baselines.
+func digestMarkKey(opts ReportOptions) string {
+	return opts.snapshotScope() + "@" + opts.destinationName()
+}
+
+// digestBaseline returns the snapshot the previous digest for these options
+// was built from, or failing that the newest one at least a day old.
+func digestBaseline(opts ReportOptions, now time.Time) (*Snapshot, error) {
+	marks := map[string]string{}
+	if err := store.load("digest-marks", &marks); err != nil {
+		return nil, err
+	}
+
+	name := marks[digestMarkKey(opts)]
+	if name != "" {
+		snap, err := loadSnapshot(name)
+		if err != nil || snap != nil {
+			return snap, err
+		}
+		// The marked snapshot has been pruned; fall through.
+	}
+
+	name, err := latestSnapshotName(opts.snapshotScope(), now.Add(-snapshotBaselineAge))
+	if err != nil || name == "" {
+		return nil, err
+	}
+	return loadSnapshot(name)
+}
+
+// markDigest records the snapshot taken at or before now as the baseline for
+// the next digest with these options.
+func markDigest(opts ReportOptions, now time.Time) error {
+	name, err := latestSnapshotName(opts.snapshotScope(), now)
+	if err != nil || name == "" {
+		return err
+	}
+	marks := map[string]string{}
+	return store.update("digest-marks", &marks, func() error {
+		marks[digestMarkKey(opts)] = name
+		return nil
+	})
+}
+
+// digestDiff compares the current issues with the previous digest's. It
+// returns nil when there is nothing to compare against; failures are logged
+// so the digest is still sent without deltas.
+func digestDiff(opts ReportOptions, issues []Issue, now time.Time) *SnapshotDiff {
+	if store == nil {
+		return nil
+	}
+
+	prev, err := digestBaseline(opts, now)
+	if err != nil {
+		slog.WarnContext(opts.context(), "Snapshots: Could not load baseline", "error", err)
+		return nil
+	}
+	if prev == nil {
+		slog.InfoContext(opts.context(), "Snapshots: No previous snapshot, digest will have no deltas")
+		return nil
+	}
+
+	diff := diffSnapshots(prev, newSnapshot(prev.Scope, issues, now))
+	if err := diff.resolveClosed(opts.context(), opts.linear()); err != nil {
+		slog.WarnContext(opts.context(), "Snapshots: Could not resolve closed issues", "error", err)
+	}
+	return diff
+}
+
+// droppedToZero returns the keys of deltas that lost all their issues: those
+// that went down and are not among the groups shown, sorted.
+func droppedToZero(deltas map[string]int, shown map[string]bool) []string {
+	var keys []string
+	for key, n := range deltas {
+		if n < 0 && !shown[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatDelta renders a count change as " (+3)", " (−2)" or "".
+func formatDelta(n int) string {
+	switch {
+	case n > 0:
+		return fmt.Sprintf(" (+%d)", n)
+	case n < 0:
+		return fmt.Sprintf(" (−%d)", -n)
+	default:
+		return ""
+	}
+}
+
+// formatSnapshotChanges lists the issues opened, closed and reassigned since
+// the baseline, five per section.
+func formatSnapshotChanges(d *SnapshotDiff) string {
+	if d == nil {
+		return ""
+	}
+
+	const perSection = 5
+	var sections []string
+
+	list := func(title string, issues []SnapshotIssue) {
+		if len(issues) == 0 {
+			return
+		}
+		lines := []string{fmt.Sprintf("**%s (%d)**", title, len(issues))}
+		for i, issue := range issues {
+			if i == perSection {
+				lines = append(lines, fmt.Sprintf("*... and %d more*", len(issues)-perSection))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("• [%s](%s) - %s", issue.Identifier, issue.URL, truncate(issue.Title, 50)))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+
+	list("✨ Opened", d.Opened)
+	list("✅ Completed", d.Completed)
+	list("❌ Canceled", d.Canceled)
+
+	if len(d.Reassigned) > 0 {
+		lines := []string{fmt.Sprintf("**🔁 Reassigned (%d)**", len(d.Reassigned))}
+		for i, r := range d.Reassigned {
+			if i == perSection {
+				lines = append(lines, fmt.Sprintf("*... and %d more*", len(d.Reassigned)-perSection))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("• [%s](%s) %s → %s", r.Issue.Identifier, r.Issue.URL, r.From, r.To))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(sections, "\n\n")
+}
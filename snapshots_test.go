@@ -0,0 +1,190 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	yesterday := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	issue := func(id, state, stateType, assignee string, priority int, label string) SnapshotIssue {
+		return SnapshotIssue{ID: id, Identifier: "ENG-" + id, State: state, StateType: stateType, Assignee: assignee,
+			Priority: priority, PriorityLabel: label, CreatedAt: yesterday.Add(-48 * time.Hour)}
+	}
+	prev := &Snapshot{TakenAt: yesterday, Issues: []SnapshotIssue{
+		issue("1", "Todo", "unstarted", "jane", 2, "High"),
+		issue("2", "In Progress", "started", "jane", 2, "High"),
+		issue("3", "In Review", "started", "bob", 1, "Urgent"),
+		issue("4", "Todo", "unstarted", "bob", 3, "Medium"),
+	}}
+
+	opened := issue("5", "Todo", "unstarted", "jane", 3, "Medium")
+	opened.CreatedAt = yesterday.Add(time.Hour)
+	movedIn := issue("6", "Todo", "unstarted", "", 3, "Medium") // older, moved into scope
+	reassigned := issue("1", "In Progress", "started", "bob", 2, "High")
+	cur := &Snapshot{TakenAt: yesterday.Add(24 * time.Hour), Issues: []SnapshotIssue{
+		reassigned,
+		issue("2", "In Progress", "started", "jane", 2, "High"),
+		opened,
+		movedIn,
+	}}
+
+	d := diffSnapshots(prev, cur)
+
+	if !d.Since.Equal(yesterday) {
+		t.Errorf("Since = %s, want %s", d.Since, yesterday)
+	}
+	wantStatus := map[string]int{"Todo": 0, "In Progress": 1, "In Review": -1}
+	if !reflect.DeepEqual(d.Status, wantStatus) {
+		t.Errorf("Status = %v, want %v", d.Status, wantStatus)
+	}
+	wantAssignee := map[string]int{"jane": 0, "bob": -1, "": 1}
+	if !reflect.DeepEqual(d.Assignee, wantAssignee) {
+		t.Errorf("Assignee = %v, want %v", d.Assignee, wantAssignee)
+	}
+	wantPriority := map[string]int{"High": 0, "Urgent": -1, "Medium": 1}
+	if !reflect.DeepEqual(d.Priority, wantPriority) {
+		t.Errorf("Priority = %v, want %v", d.Priority, wantPriority)
+	}
+	if d.StateTypes["In Review"] != "started" || d.PriorityLabels[1] != "Urgent" {
+		t.Errorf("baseline groups = %v, %v", d.StateTypes, d.PriorityLabels)
+	}
+
+	if !reflect.DeepEqual(d.Opened, []SnapshotIssue{opened}) {
+		t.Errorf("Opened = %v, want only ENG-5 (ENG-6 is older than the baseline)", d.Opened)
+	}
+	if want := []Reassignment{{Issue: reassigned, From: "jane", To: "bob"}}; !reflect.DeepEqual(d.Reassigned, want) {
+		t.Errorf("Reassigned = %v, want %v", d.Reassigned, want)
+	}
+	if len(d.Removed) != 2 || d.Removed[0].Identifier != "ENG-3" || d.Removed[1].Identifier != "ENG-4" {
+		t.Errorf("Removed = %v, want ENG-3 and ENG-4 in order", d.Removed)
+	}
+	if len(d.Completed)+len(d.Canceled) != 0 {
+		t.Error("issues were sorted into completed or canceled before resolveClosed")
+	}
+}
+
+func TestDiffSnapshotsUnchanged(t *testing.T) {
+	snap := newSnapshot("all", []Issue{
+		{ID: "1", Identifier: "ENG-1", State: State{Name: "Todo", Type: "unstarted"}, Assignee: &User{DisplayName: "jane"}},
+	}, time.Now())
+	d := diffSnapshots(snap, snap)
+	if len(d.Opened)+len(d.Removed)+len(d.Reassigned) != 0 {
+		t.Errorf("diff of a snapshot with itself = %+v", d)
+	}
+	for group, n := range d.Status {
+		if n != 0 {
+			t.Errorf("Status[%q] = %d, want 0", group, n)
+		}
+	}
+	if formatSnapshotChanges(d) != "" {
+		t.Errorf("formatSnapshotChanges = %q, want \"\"", formatSnapshotChanges(d))
+	}
+}
+
+func TestDroppedToZero(t *testing.T) {
+	deltas := map[string]int{"In Review": -2, "Todo": -1, "In Progress": 3, "Backlog": 0, "Blocked": -4}
+	shown := map[string]bool{"Todo": true, "In Progress": true}
+	if got, want := droppedToZero(deltas, shown), []string{"Blocked", "In Review"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("droppedToZero = %v, want %v", got, want)
+	}
+}
+
+func TestFormatDelta(t *testing.T) {
+	for n, want := range map[int]string{3: " (+3)", -2: " (−2)", 0: ""} {
+		if got := formatDelta(n); got != want {
+			t.Errorf("formatDelta(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestSnapshotScope(t *testing.T) {
+	if got := snapshotScope(nil); got != "all" {
+		t.Errorf("snapshotScope(nil) = %q", got)
+	}
+	if got := snapshotScope(&IssueFilter{}); got != "all" {
+		t.Errorf("snapshotScope(empty) = %q", got)
+	}
+	eng, ops := snapshotScope(&IssueFilter{Teams: []string{"ENG"}}), snapshotScope(&IssueFilter{Teams: []string{"OPS"}})
+	if eng == ops || eng == "all" || eng != snapshotScope(&IssueFilter{Teams: []string{"ENG"}}) {
+		t.Errorf("scopes of different filters: %q, %q", eng, ops)
+	}
+	ws := ReportOptions{Workspace: &Workspace{Key: "acme"}}
+	if got := ws.snapshotScope(); got != "acme-all" {
+		t.Errorf("workspace snapshotScope = %q, want acme-all", got)
+	}
+}
+
+func TestDigestBaseline(t *testing.T) {
+	setTestStore(t)
+	day := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	opts := ReportOptions{}
+	other := ReportOptions{Destination: &Destination{Name: "ops"}}
+
+	save := func(at time.Time) {
+		t.Helper()
+		if err := store.save(snapshotName("all", at), newSnapshot("all", nil, at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if snap, err := digestBaseline(opts, day); err != nil || snap != nil {
+		t.Fatalf("digestBaseline without snapshots = %v, %v", snap, err)
+	}
+
+	// Without a mark, the newest snapshot at least snapshotBaselineAge old
+	// is the baseline.
+	save(day.Add(-48 * time.Hour))
+	save(day.Add(-24 * time.Hour))
+	save(day.Add(-time.Hour))
+	if snap, _ := digestBaseline(opts, day); snap == nil || !snap.TakenAt.Equal(day.Add(-24*time.Hour)) {
+		t.Errorf("unmarked baseline = %v, want the one from a day earlier", snap)
+	}
+
+	// A marked digest compares against the snapshot it was built from, even
+	// if it is younger, and only for the same destination.
+	if err := markDigest(opts, day.Add(-30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if snap, _ := digestBaseline(opts, day); snap == nil || !snap.TakenAt.Equal(day.Add(-time.Hour)) {
+		t.Errorf("marked baseline = %v, want the marked snapshot", snap)
+	}
+	if snap, _ := digestBaseline(other, day); snap == nil || !snap.TakenAt.Equal(day.Add(-24*time.Hour)) {
+		t.Errorf("baseline of another destination = %v, want the unmarked one", snap)
+	}
+
+	// A pruned mark falls back to the age rule.
+	if err := store.remove(snapshotName("all", day.Add(-time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	if snap, _ := digestBaseline(opts, day); snap == nil || !snap.TakenAt.Equal(day.Add(-24*time.Hour)) {
+		t.Errorf("baseline after pruning = %v, want the one from a day earlier", snap)
+	}
+}
+
+func TestDailySnapshots(t *testing.T) {
+	setTestStore(t)
+	day := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{
+		day.Add(-24 * time.Hour).Add(23 * time.Hour), // before since
+		day.Add(8 * time.Hour),
+		day.Add(17 * time.Hour),
+		day.Add(24*time.Hour + 9*time.Hour),
+	} {
+		store.save(snapshotName("all", at), newSnapshot("all", nil, at))
+	}
+
+	snaps, err := dailySnapshots("all", day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []time.Time
+	for _, s := range snaps {
+		got = append(got, s.TakenAt)
+	}
+	want := []time.Time{day.Add(17 * time.Hour), day.Add(33 * time.Hour)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dailySnapshots = %v, want the last of each day since %s: %v", got, day, want)
+	}
+}
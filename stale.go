@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// STALE ISSUE REPORT
+// ============================================================================
+
+// StaleThresholds decide when an issue counts as stale. Zero values fall back
+// to the workspace-wide setting, then to the built-in default.
+type StaleThresholds struct {
+	// StartedDays flags started issues that haven't changed state for this
+	// many days.
+	StartedDays int `json:"started_days,omitempty"`
+	// UnassignedHours flags urgent and high priority issues that are still
+	// unassigned this many hours after creation.
+	UnassignedHours int `json:"unassigned_hours,omitempty"`
+	// BacklogWeeks flags backlog issues older than this many weeks.
+	BacklogWeeks int `json:"backlog_weeks,omitempty"`
+}
+
+// StaleConfig is the "stale" section of the config: workspace-wide thresholds
+// plus per-team overrides keyed by team key.
+type StaleConfig struct {
+	StaleThresholds
+	Teams map[string]StaleThresholds `json:"teams,omitempty"`
+}
+
+var defaultStaleThresholds = StaleThresholds{
+	StartedDays:     7,
+	UnassignedHours: 24,
+	BacklogWeeks:    12,
+}
+
+// thresholds returns the effective thresholds for a team.
+func (c *StaleConfig) thresholds(teamKey string) StaleThresholds {
+	t := defaultStaleThresholds
+	if c == nil {
+		return t
+	}
+	t = t.merge(c.StaleThresholds)
+	for key, override := range c.Teams {
+		if strings.EqualFold(key, teamKey) {
+			t = t.merge(override)
+		}
+	}
+	return t
+}
+
+func (t StaleThresholds) merge(o StaleThresholds) StaleThresholds {
+	if o.StartedDays > 0 {
+		t.StartedDays = o.StartedDays
+	}
+	if o.UnassignedHours > 0 {
+		t.UnassignedHours = o.UnassignedHours
+	}
+	if o.BacklogWeeks > 0 {
+		t.BacklogWeeks = o.BacklogWeeks
+	}
+	return t
+}
+
+// StaleReport holds the three sections of the report.
+type StaleReport struct {
+	Stuck      []Issue // started, no state change for StartedDays
+	Unassigned []Issue // urgent/high, unassigned after UnassignedHours
+	OldBacklog []Issue // backlog, older than BacklogWeeks
+}
+
+// findStaleIssues sorts issues into the report. stateChanges holds the last
+// state change of started issues by ID, as returned by LastStateChanges.
+func findStaleIssues(issues []Issue, stateChanges map[string]time.Time, cfg *StaleConfig, now time.Time) StaleReport {
+	var report StaleReport
+	lastMoved := func(issue Issue) time.Time { return lastStateChange(issue, stateChanges) }
+
+	for _, issue := range issues {
+		t := cfg.thresholds(issue.Team.Key)
+
+		switch issue.State.Type {
+		case "started":
+			if now.Sub(lastMoved(issue)) >= time.Duration(t.StartedDays)*24*time.Hour {
+				report.Stuck = append(report.Stuck, issue)
+			}
+		case "backlog":
+			if now.Sub(issue.CreatedAt) >= time.Duration(t.BacklogWeeks)*7*24*time.Hour {
+				report.OldBacklog = append(report.OldBacklog, issue)
+			}
+		}
+
+		if issue.Assignee == nil && (issue.Priority == 1 || issue.Priority == 2) &&
+			now.Sub(issue.CreatedAt) >= time.Duration(t.UnassignedHours)*time.Hour {
+			report.Unassigned = append(report.Unassigned, issue)
+		}
+	}
+
+	sortOldestFirst(report.Stuck, lastMoved)
+	sortOldestFirst(report.Unassigned, func(i Issue) time.Time { return i.CreatedAt })
+	sortOldestFirst(report.OldBacklog, func(i Issue) time.Time { return i.CreatedAt })
+
+	return report
+}
+
+// lastStateChange is when the issue last moved between workflow states, so
+// that a move from "In Progress" to "In Review" counts but edits, comments and
+// label changes don't. Without history it falls back to when the issue was
+// started, then to its last update.
+func lastStateChange(issue Issue, stateChanges map[string]time.Time) time.Time {
+	if at, ok := stateChanges[issue.ID]; ok {
+		return at
+	}
+	if issue.StartedAt != nil {
+		return *issue.StartedAt
+	}
+	return issue.UpdatedAt
+}
+
+// fetchStateChanges returns the last state change of the started issues. The
+// report still goes out without it, judged by startedAt instead.
+func fetchStateChanges(opts ReportOptions, issues []Issue) map[string]time.Time {
+	var ids []string
+	for _, issue := range issues {
+		if issue.State.Type == "started" {
+			ids = append(ids, issue.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	changes, err := opts.linear().LastStateChanges(opts.context(), ids)
+	if err != nil {
+		slog.WarnContext(opts.context(), "Stale report: Could not fetch issue history", "error", err)
+		return nil
+	}
+	return changes
+}
+
+func sortOldestFirst(issues []Issue, at func(Issue) time.Time) {
+	sort.SliceStable(issues, func(i, j int) bool { return at(issues[i]).Before(at(issues[j])) })
+}
+
+func handleReportStale(w http.ResponseWriter, r *http.Request) {
+	opts, ok := reportOptions(w, r)
+	if !ok {
+		return
+	}
+	if err := generateStaleReport(opts); err != nil {
+		slog.ErrorContext(opts.context(), "Error generating stale report", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if opts.dryRun() {
+		writePreview(w, r, opts.Preview)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "stale_report_sent"})
+}
+
+func generateStaleReport(opts ReportOptions) error {
+	slog.InfoContext(opts.context(), "Fetching issues for stale report...")
+	issues, err := fetchAllOpenIssues(opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch issues: %w", err)
+	}
+
+	now := time.Now()
+	stateChanges := fetchStateChanges(opts, issues)
+	report := findStaleIssues(issues, stateChanges, appConfig.Stale, now)
+	slog.InfoContext(opts.context(), "Stale report: Found stale issues",
+		"stuck", len(report.Stuck), "unassigned", len(report.Unassigned), "old_backlog", len(report.OldBacklog))
+
+	if len(report.Stuck)+len(report.Unassigned)+len(report.OldBacklog) == 0 {
+		return opts.send(&DiscordWebhook{
+			Username:  "Linear Stale Issues",
+			AvatarURL: linearAvatarURL,
+			Embeds: []DiscordEmbed{{
+				Title:       "🧊 Stale Issues",
+				Description: "Nothing is stuck. Every issue has moved recently! 🎉",
+				Color:       ColorGreen,
+				Timestamp:   now.UTC().Format(time.RFC3339),
+			}},
+		})
+	}
+
+	embeds := []DiscordEmbed{{
+		Title: "🧊 Stale Issues",
+		Description: fmt.Sprintf("**%d** stuck in progress | **%d** urgent/high unassigned | **%d** old backlog",
+			len(report.Stuck), len(report.Unassigned), len(report.OldBacklog)),
+		Color:     ColorGray,
+		Timestamp: now.UTC().Format(time.RFC3339),
+	}}
+
+	if len(report.Stuck) > 0 {
+		embeds = append(embeds, DiscordEmbed{
+			Title: "🐌 In Progress, Not Moving",
+			Description: formatIssuesByAssignee(report.Stuck, func(issue Issue) string {
+				return fmt.Sprintf("%s in %s", formatAge(now.Sub(lastStateChange(issue, stateChanges))), issue.State.Name)
+			}),
+			Color: ColorYellow,
+		})
+	}
+
+	if len(report.Unassigned) > 0 {
+		embeds = append(embeds, DiscordEmbed{
+			Title: "🚨 Urgent & High Priority, Unassigned",
+			Description: formatIssuesByAssignee(report.Unassigned, func(issue Issue) string {
+				return fmt.Sprintf("open %s", formatAge(now.Sub(issue.CreatedAt)))
+			}),
+			Color: ColorRed,
+		})
+	}
+
+	if len(report.OldBacklog) > 0 {
+		embeds = append(embeds, DiscordEmbed{
+			Title: "🗄️ Old Backlog",
+			Description: formatIssuesByAssignee(report.OldBacklog, func(issue Issue) string {
+				return fmt.Sprintf("created %s ago", formatAge(now.Sub(issue.CreatedAt)))
+			}),
+			Color: ColorGray,
+		})
+	}
+
+	return opts.send(&DiscordWebhook{
+		Username:  "Linear Stale Issues",
+		AvatarURL: linearAvatarURL,
+		Embeds:    embeds,
+	})
+}
+
+// maxSectionLength keeps a report section short enough that several of them
+// fit in one message (Discord allows 6000 characters across all embeds).
+const maxSectionLength = 1800
+
+// formatIssuesByAssignee lists issues under a heading per assignee, with a
+// note from detail after each issue, and stops at maxSectionLength.
+func formatIssuesByAssignee(issues []Issue, detail func(Issue) string) string {
+	var b strings.Builder
+	remaining := len(issues)
+
+	for _, group := range groupByAssignee(issues) {
+		emoji := "👤"
+		if group.Name == "Unassigned" {
+			emoji = "❓"
+		}
+		heading := fmt.Sprintf("%s **%s** (%d)\n", emoji, group.Name, len(group.Issues))
+
+		for i, issue := range group.Issues {
+			line := fmt.Sprintf("%s [%s](%s) - %s · %s\n",
+				getPriorityEmoji(issue.Priority), issue.Identifier, issue.URL, truncate(issue.Title, 50), detail(issue))
+			if i == 0 {
+				line = heading + line
+			}
+			if b.Len()+len(line) > maxSectionLength-30 {
+				fmt.Fprintf(&b, "*... and %d more*", remaining)
+				return b.String()
+			}
+			b.WriteString(line)
+			remaining--
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// formatAge renders a duration as a short age such as "3h", "5d" or "6w".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 14*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	default:
+		return fmt.Sprintf("%dw", int(d.Hours()/(24*7)))
+	}
+}
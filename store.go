@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ============================================================================
+// STATE STORE
+// ============================================================================
+
+// stateStore persists JSON documents under STATE_DIR. Names may contain "/" to
+// group documents in subdirectories. Writes go through
+// a temp file and rename so a crash never leaves a half-written document. When
+// STATE_DIR is a volume shared between replicas, update serialises
+// read-modify-write cycles across processes with flock(2) on a lock file.
+type stateStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+var store *stateStore
+
+func openStateStore(dir string) (*stateStore, error) {
+	if dir == "" {
+		dir = "data"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create state dir: %w", err)
+	}
+	return &stateStore{dir: dir}, nil
+}
+
+// load decodes the named document into v. A missing document leaves v as is.
+func (s *stateStore) load(name string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(s.dir, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *stateStore) save(name string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+
+	path := filepath.Join(s.dir, name+".json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// list returns the names of the documents directly under dir, sorted.
+func (s *stateStore) list(dir string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		names = append(names, dir+"/"+strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *stateStore) remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(filepath.Join(s.dir, name+".json"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", name, err)
+	}
+	return nil
+}
+
+// lockTimeout bounds how long update waits for another process.
+const lockTimeout = 30 * time.Second
+
+// update loads the named document into v under a cross-process lock, calls fn
+// to modify it and saves the result. Nothing is saved if fn returns an error.
+func (s *stateStore) update(name string, v interface{}, fn func() error) error {
+	unlock, err := s.lock(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.load(name, v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return s.save(name, v)
+}
+
+// lock takes an exclusive flock(2) on the document's lock file, polling
+// until lockTimeout. The kernel releases the lock when its holder exits, so a
+// crashed replica never leaves it behind and no lock is ever taken over.
+func (s *stateStore) lock(name string) (func(), error) {
+	path := filepath.Join(s.dir, name+".lock")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", name, err)
+	}
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			// The holder is only recorded to help debugging.
+			f.Truncate(0)
+			f.WriteAt([]byte(fmt.Sprintf("%s %s\n", instanceID, time.Now().UTC().Format(time.RFC3339))), 0)
+			return func() {
+				syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+				f.Close()
+			}, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", name, err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out waiting for %s lock", name)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
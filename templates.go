@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ============================================================================
+// MESSAGE TEMPLATES
+// ============================================================================
+
+// EventTemplate describes one Discord embed. Every field is a Go text/template
+// rendered against a TemplateContext. Fields that render to an empty string are
+// left out, and an event whose title and description are both empty is dropped.
+type EventTemplate struct {
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	URL         string          `json:"url,omitempty"`
+	Color       string          `json:"color,omitempty"`
+	Author      string          `json:"author,omitempty"`
+	Footer      string          `json:"footer,omitempty"`
+	Fields      []FieldTemplate `json:"fields,omitempty"`
+}
+
+type FieldTemplate struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// TemplateContext is the data available to templates. Issue, Comment and
+// Project are set for their respective event types; Data always holds the raw
+// payload so templates can be written for any Linear event type.
+type TemplateContext struct {
+	Type        string
+	Action      string
+	Actor       *User
+	URL         string
+	CreatedAt   string
+	Destination string
+
+	Issue   *LinearWebhookIssue
+	Comment *LinearWebhookComment
+	Project *LinearWebhookProject
+	Data    map[string]interface{}
+	Diff    []FieldChange
+}
+
+// FieldChange is one entry of the webhook's updatedFrom, paired with the new
+// value from the payload.
+type FieldChange struct {
+	Field string
+	From  interface{}
+	To    interface{}
+}
+
+// Changed reports whether field is part of this update.
+func (c *TemplateContext) Changed(field string) bool {
+	for _, change := range c.Diff {
+		if change.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// TemplateSet is a compiled layer of templates. Lookups fall through to the
+// parent layer: destination → config file → built-in defaults.
+type TemplateSet struct {
+	templates map[string]*compiledTemplate
+	parent    *TemplateSet
+}
+
+type compiledTemplate struct {
+	key         string
+	title       *template.Template
+	description *template.Template
+	url         *template.Template
+	color       *template.Template
+	author      *template.Template
+	footer      *template.Template
+	fields      []compiledField
+}
+
+type compiledField struct {
+	name   *template.Template
+	value  *template.Template
+	inline bool
+}
+
+var templateFuncs = template.FuncMap{
+	"priorityEmoji": getPriorityEmoji,
+	"stateEmoji":    getStateEmoji,
+	"truncate":      func(maxLen int, s string) string { return truncate(s, maxLen) },
+	"title":         strings.Title,
+	"lower":         strings.ToLower,
+	"upper":         strings.ToUpper,
+	"join":          strings.Join,
+	"labels": func(labels []Label) string {
+		names := make([]string, len(labels))
+		for i, label := range labels {
+			names[i] = fmt.Sprintf("`%s`", label.Name)
+		}
+		return strings.Join(names, " ")
+	},
+}
+
+// compileTemplates parses overrides on top of parent and validates each one by
+// rendering it against sample payloads.
+func compileTemplates(overrides map[string]*EventTemplate, parent *TemplateSet) (*TemplateSet, error) {
+	set := &TemplateSet{templates: map[string]*compiledTemplate{}, parent: parent}
+
+	for key, tmpl := range overrides {
+		eventType, action, ok := strings.Cut(key, ".")
+		if !ok || eventType == "" || action == "" {
+			return nil, fmt.Errorf("invalid template key %q (want <Type>.<action> or <Type>.*)", key)
+		}
+
+		compiled, err := compileTemplate(key, tmpl)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, sample := range sampleWebhooks(eventType, action) {
+			ctx, err := newTemplateContext(sample)
+			if err != nil {
+				return nil, fmt.Errorf("%s: building sample: %w", key, err)
+			}
+			if _, err := compiled.render(ctx); err != nil {
+				return nil, fmt.Errorf("%s: sample %s.%s: %w", key, sample.Type, sample.Action, err)
+			}
+		}
+
+		set.templates[key] = compiled
+	}
+
+	return set, nil
+}
+
+func compileTemplate(key string, tmpl *EventTemplate) (*compiledTemplate, error) {
+	if tmpl == nil {
+		return nil, fmt.Errorf("%s: template is empty", key)
+	}
+
+	var firstErr error
+	parse := func(part, text string) *template.Template {
+		t, err := template.New(key + "." + part).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return t
+	}
+
+	compiled := &compiledTemplate{
+		key:         key,
+		title:       parse("title", tmpl.Title),
+		description: parse("description", tmpl.Description),
+		url:         parse("url", tmpl.URL),
+		color:       parse("color", tmpl.Color),
+		author:      parse("author", tmpl.Author),
+		footer:      parse("footer", tmpl.Footer),
+	}
+	for i, field := range tmpl.Fields {
+		compiled.fields = append(compiled.fields, compiledField{
+			name:   parse(fmt.Sprintf("fields[%d].name", i), field.Name),
+			value:  parse(fmt.Sprintf("fields[%d].value", i), field.Value),
+			inline: field.Inline,
+		})
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return compiled, nil
+}
+
+func (s *TemplateSet) lookup(eventType, action string) *compiledTemplate {
+	for set := s; set != nil; set = set.parent {
+		if t := set.templates[eventType+"."+action]; t != nil {
+			return t
+		}
+		if t := set.templates[eventType+".*"]; t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// render executes the template and returns nil if the event should be dropped.
+func (t *compiledTemplate) render(ctx *TemplateContext) (*DiscordEmbed, error) {
+	var firstErr error
+	exec := func(tmpl *template.Template) string {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return strings.TrimSpace(buf.String())
+	}
+
+	embed := DiscordEmbed{
+		Title:       exec(t.title),
+		Description: exec(t.description),
+		URL:         exec(t.url),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	color, err := parseColor(exec(t.color))
+	if err != nil && firstErr == nil {
+		firstErr = fmt.Errorf("%s.color: %w", t.key, err)
+	}
+	embed.Color = color
+
+	if author := exec(t.author); author != "" {
+		embed.Author = &DiscordAuthor{Name: author}
+	}
+	if footer := exec(t.footer); footer != "" {
+		embed.Footer = &DiscordFooter{Text: footer}
+	}
+
+	for _, field := range t.fields {
+		name, value := exec(field.name), exec(field.value)
+		if name == "" || value == "" {
+			continue
+		}
+		embed.Fields = append(embed.Fields, DiscordField{Name: name, Value: value, Inline: field.inline})
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if embed.Title == "" && embed.Description == "" {
+		return nil, nil
+	}
+	return &embed, nil
+}
+
+var namedColors = map[string]int{
+	"blue":   ColorBlue,
+	"green":  ColorGreen,
+	"yellow": ColorYellow,
+	"red":    ColorRed,
+	"gray":   ColorGray,
+	"purple": ColorPurple,
+}
+
+// parseColor accepts a color name, "#RRGGBB", "0xRRGGBB" or a decimal value.
+func parseColor(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return ColorBlue, nil
+	}
+	if c, ok := namedColors[s]; ok {
+		return c, nil
+	}
+	var v int64
+	var err error
+	switch {
+	case strings.HasPrefix(s, "#"):
+		v, err = strconv.ParseInt(s[1:], 16, 32)
+	case strings.HasPrefix(s, "0x"):
+		v, err = strconv.ParseInt(s[2:], 16, 32)
+	default:
+		v, err = strconv.ParseInt(s, 10, 32)
+	}
+	if err != nil || v < 0 || v > 0xFFFFFF {
+		return 0, fmt.Errorf("invalid color %q", s)
+	}
+	return int(v), nil
+}
+
+// newTemplateContext decodes the webhook payload into the typed fields that
+// match its type.
+func newTemplateContext(webhook LinearWebhook) (*TemplateContext, error) {
+	ctx := &TemplateContext{
+		Type:      webhook.Type,
+		Action:    webhook.Action,
+		Actor:     webhook.Actor,
+		URL:       webhook.URL,
+		CreatedAt: webhook.CreatedAt,
+	}
+
+	if len(webhook.Data) > 0 {
+		if err := json.Unmarshal(webhook.Data, &ctx.Data); err != nil {
+			return nil, fmt.Errorf("failed to parse webhook data: %w", err)
+		}
+	}
+
+	switch webhook.Type {
+	case "Issue":
+		ctx.Issue = &LinearWebhookIssue{}
+		if err := json.Unmarshal(webhook.Data, ctx.Issue); err != nil {
+			return nil, fmt.Errorf("failed to parse issue data: %w", err)
+		}
+	case "Comment":
+		ctx.Comment = &LinearWebhookComment{}
+		if err := json.Unmarshal(webhook.Data, ctx.Comment); err != nil {
+			return nil, fmt.Errorf("failed to parse comment data: %w", err)
+		}
+	case "Project":
+		ctx.Project = &LinearWebhookProject{}
+		if err := json.Unmarshal(webhook.Data, ctx.Project); err != nil {
+			return nil, fmt.Errorf("failed to parse project data: %w", err)
+		}
+	}
+
+	if len(webhook.UpdatedFrom) > 0 && string(webhook.UpdatedFrom) != "null" {
+		var from map[string]interface{}
+		if err := json.Unmarshal(webhook.UpdatedFrom, &from); err != nil {
+			return nil, fmt.Errorf("failed to parse updatedFrom: %w", err)
+		}
+		for field, old := range from {
+			ctx.Diff = append(ctx.Diff, FieldChange{Field: field, From: old, To: ctx.Data[field]})
+		}
+		sort.Slice(ctx.Diff, func(i, j int) bool { return ctx.Diff[i].Field < ctx.Diff[j].Field })
+	}
+
+	return ctx, nil
+}
+
+// sampleWebhooks returns payloads used to validate templates at load time: a
+// fully populated one and a sparse one (no actor, assignee, state, ...) for
+// each action the template key covers.
+func sampleWebhooks(eventType, action string) []LinearWebhook {
+	actions := []string{action}
+	if action == "*" {
+		actions = []string{"create", "update", "remove"}
+	}
+
+	var full, sparse string
+	switch eventType {
+	case "Issue":
+		full = `{"id":"1","identifier":"ENG-1","title":"Sample issue","description":"Details","priority":2,"priorityLabel":"High",` +
+			`"state":{"id":"s","name":"In Progress","color":"#f2c94c","type":"started"},` +
+			`"assignee":{"id":"u","name":"Jane","displayName":"jane","email":"jane@example.com"},` +
+			`"team":{"id":"t","name":"Engineering","key":"ENG"},"labels":[{"id":"l","name":"bug","color":"#eb5757"}],` +
+			`"url":"https://linear.app/example/issue/ENG-1"}`
+		sparse = `{"id":"1","identifier":"ENG-1","title":"Sample issue","priority":0}`
+	case "Comment":
+		full = `{"id":"1","body":"Looks good","issue":{"id":"1","identifier":"ENG-1","title":"Sample issue","url":"https://linear.app/example/issue/ENG-1"},` +
+			`"user":{"id":"u","name":"Jane"},"url":"https://linear.app/example/issue/ENG-1#comment-1"}`
+		sparse = `{"id":"1","body":""}`
+	case "Project":
+		full = `{"id":"1","name":"Launch","description":"Ship it","state":"started","url":"https://linear.app/example/project/launch"}`
+		sparse = `{"id":"1","name":"Launch"}`
+	default:
+		full = `{"id":"1","name":"Sample"}`
+		sparse = `{"id":"1"}`
+	}
+
+	var samples []LinearWebhook
+	for _, a := range actions {
+		samples = append(samples,
+			LinearWebhook{
+				Type:        eventType,
+				Action:      a,
+				Actor:       &User{ID: "u", Name: "Jane"},
+				URL:         "https://linear.app/example",
+				CreatedAt:   "2024-01-01T00:00:00.000Z",
+				Data:        json.RawMessage(full),
+				UpdatedFrom: json.RawMessage(`{"priority":3,"updatedAt":"2024-01-01T00:00:00.000Z"}`),
+			},
+			LinearWebhook{Type: eventType, Action: a, Data: json.RawMessage(sparse)},
+		)
+	}
+	return samples
+}
+
+// defaultTemplates reproduces the original hard-coded message layout.
+func defaultTemplates() map[string]*EventTemplate {
+	issue := func(title, color string) *EventTemplate {
+		return &EventTemplate{
+			Title:       title,
+			Description: "**[{{.Issue.Identifier}}]({{.Issue.URL}})** - {{.Issue.Title}}\n\n{{or (truncate 300 .Issue.Description) \"*No description*\"}}",
+			URL:         "{{.Issue.URL}}",
+			Color:       color,
+			Footer:      "{{with .Actor}}by {{.Name}}{{end}}",
+			Fields: []FieldTemplate{
+				{Name: "Status", Value: "{{with .Issue.State}}{{stateEmoji .Type}} {{.Name}}{{end}}", Inline: true},
+				{Name: "Priority", Value: "{{if .Issue.PriorityLabel}}{{priorityEmoji .Issue.Priority}} {{.Issue.PriorityLabel}}{{end}}", Inline: true},
+				{Name: "Assignee", Value: "{{with .Issue.Assignee}}👤 {{.Name}}{{end}}", Inline: true},
+				{Name: "Team", Value: "{{with .Issue.Team}}👥 {{.Name}}{{end}}", Inline: true},
+				{Name: "Labels", Value: "{{labels .Issue.Labels}}"},
+			},
+		}
+	}
+
+	comment := func(title string) *EventTemplate {
+		return &EventTemplate{
+			Title:       title,
+			Description: "{{with .Comment.Issue}}**[{{.Identifier}}]({{.URL}})** - {{.Title}}{{end}}\n\n>>> {{truncate 500 .Comment.Body}}",
+			URL:         "{{.Comment.URL}}",
+			Color:       "purple",
+			Author:      "{{with .Comment.User}}{{.Name}}{{end}}",
+			Footer:      "{{with .Actor}}by {{.Name}}{{end}}",
+		}
+	}
+
+	project := func(title, color string) *EventTemplate {
+		return &EventTemplate{
+			Title:       title,
+			Description: "**{{.Project.Name}}**\n\n{{or (truncate 300 .Project.Description) \"*No description*\"}}",
+			URL:         "{{.Project.URL}}",
+			Color:       color,
+			Footer:      "{{with .Actor}}by {{.Name}}{{end}}",
+			Fields: []FieldTemplate{
+				{Name: "State", Value: "{{.Project.State}}", Inline: true},
+			},
+		}
+	}
+
+	return map[string]*EventTemplate{
+		"Issue.create": issue("🎯 New Issue Created", "blue"),
+		"Issue.update": issue("📝 Issue Updated", "yellow"),
+		"Issue.remove": issue("🗑️ Issue Removed", "red"),
+		"Issue.*":      issue("📋 Issue {{title .Action}}", "blue"),
+
+		"Comment.create": comment("💬 New Comment"),
+		"Comment.update": comment("✏️ Comment Updated"),
+		"Comment.remove": comment("🗑️ Comment Removed"),
+		"Comment.*":      comment("💬 Comment {{title .Action}}"),
+
+		"Project.create": project("🚀 New Project Created", "green"),
+		"Project.update": project("📊 Project Updated", "yellow"),
+		"Project.remove": project("🗑️ Project Removed", "red"),
+		"Project.*":      project("📁 Project {{title .Action}}", "blue"),
+	}
+}
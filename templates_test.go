@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateLayers(t *testing.T) {
+	cfg, err := loadTestConfig(t, `{
+		"templates": {
+			"Issue.*":      {"title": "config {{.Issue.Identifier}}"},
+			"Issue.remove": {"title": "config removed {{.Issue.Identifier}}"},
+			"Comment.*":    {"title": "", "description": ""}
+		},
+		"destinations": {
+			"ops": {
+				"webhook_url": "https://discord.test/ops",
+				"templates": {
+					"Issue.create": {"title": "{{.Destination}} created {{.Issue.Identifier}}", "color": "red"},
+					"Project.*":    {"title": "{{.Destination}} project {{.Project.Name}}"}
+				}
+			},
+			"plain": {"webhook_url": "https://discord.test/plain"}
+		},
+		"relay_to": ["ops", "plain"]
+	}`)
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	issue := json.RawMessage(`{"id":"1","identifier":"ENG-1","title":"Crash"}`)
+	tests := []struct {
+		name      string
+		dest      string
+		webhook   LinearWebhook
+		wantTitle string // "" when the event is dropped
+		wantColor int
+	}{
+		{"destination exact", "ops", LinearWebhook{Type: "Issue", Action: "create", Data: issue}, "ops created ENG-1", ColorRed},
+		{"config wildcard under destination", "ops", LinearWebhook{Type: "Issue", Action: "update", Data: issue}, "config ENG-1", ColorBlue},
+		{"config exact over config wildcard", "ops", LinearWebhook{Type: "Issue", Action: "remove", Data: issue}, "config removed ENG-1", ColorBlue},
+		{"destination wildcard", "ops", LinearWebhook{Type: "Project", Action: "update", Data: json.RawMessage(`{"id":"p","name":"Launch"}`)}, "ops project Launch", ColorBlue},
+		{"config without destination templates", "plain", LinearWebhook{Type: "Issue", Action: "create", Data: issue}, "config ENG-1", ColorBlue},
+		{"built-in default", "plain", LinearWebhook{Type: "Project", Action: "create", Data: json.RawMessage(`{"id":"p","name":"Launch"}`)}, "🚀 New Project Created", ColorGreen},
+		{"empty template drops the event", "ops", LinearWebhook{Type: "Comment", Action: "create", Data: json.RawMessage(`{"id":"c","body":"hi"}`)}, "", 0},
+		{"unknown type", "plain", LinearWebhook{Type: "Reaction", Action: "create", Data: json.RawMessage(`{}`)}, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := transformWebhookToDiscord(context.Background(), tt.webhook, cfg.destination(tt.dest), newMetadataCache(nil, time.Hour))
+			if err != nil {
+				t.Fatalf("transformWebhookToDiscord: %v", err)
+			}
+			if tt.wantTitle == "" {
+				if payload != nil {
+					t.Errorf("payload = %+v, want the event dropped", payload.Embeds)
+				}
+				return
+			}
+			if payload == nil || len(payload.Embeds) != 1 {
+				t.Fatalf("payload = %+v, want one embed", payload)
+			}
+			embed := payload.Embeds[0]
+			if embed.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", embed.Title, tt.wantTitle)
+			}
+			if embed.Color != tt.wantColor {
+				t.Errorf("color = %#x, want %#x", embed.Color, tt.wantColor)
+			}
+		})
+	}
+}
+
+func TestTemplateErrorsFailConfigLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			"parse error",
+			`{"templates": {"Issue.create": {"title": "{{.Issue.Title"}}}`,
+			"templates: template: Issue.create.title",
+		},
+		{
+			"invalid key",
+			`{"templates": {"Issue": {"title": "x"}}}`,
+			`invalid template key "Issue"`,
+		},
+		{
+			"unknown function",
+			`{"templates": {"Issue.*": {"title": "{{shout .Issue.Title}}"}}}`,
+			`function "shout" not defined`,
+		},
+		{
+			"missing field",
+			`{"templates": {"Issue.update": {"title": "{{.Issue.Titel}}"}}}`,
+			"Issue.update: sample Issue.update",
+		},
+		{
+			"nil in sparse sample",
+			`{"templates": {"Issue.create": {"title": "{{.Issue.Assignee.Name}}"}}}`,
+			"Issue.create: sample Issue.create",
+		},
+		{
+			"invalid color",
+			`{"templates": {"Issue.create": {"title": "x", "color": "#zzzzzz"}}}`,
+			"Issue.create.color: invalid color",
+		},
+		{
+			"empty template",
+			`{"templates": {"Issue.create": null}}`,
+			"Issue.create: template is empty",
+		},
+		{
+			"destination template",
+			`{"destinations": {"ops": {"webhook_url": "https://discord.test/ops", "templates": {"Comment.create": {"description": "{{end}}"}}}}}`,
+			`destination "ops" templates:`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(t, tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfig error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"", ColorBlue, false},
+		{"Red", ColorRed, false},
+		{"#ff8800", 0xff8800, false},
+		{"0x00FF00", 0x00ff00, false},
+		{"255", 255, false},
+		{"#1000000", 0, true},
+		{"-1", 0, true},
+		{"teal", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseColor(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseColor(%q) = %#x, %v; want %#x, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// TRACING (OTLP/HTTP JSON)
+// ============================================================================
+
+// A small stdlib tracer: spans are propagated through the context, continue
+// the trace of incoming W3C traceparent headers, and are exported in batches
+// to an OpenTelemetry collector with the OTLP/HTTP JSON encoding. Tracing is off
+// unless an OTLP endpoint is configured; spans are then nil and free.
+
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+
+	spanBatchSize     = 512
+	spanQueueSize     = 4096
+	spanFlushInterval = 5 * time.Second
+)
+
+// tracer exports finished spans; nil when tracing is disabled.
+var tracer *spanExporter
+
+// Span is one timed operation of a trace.
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mu     sync.Mutex
+	attrs  []otlpKeyValue
+	errMsg string
+	failed bool
+}
+
+type spanKey struct{}
+
+// spanFromContext returns the active span, or nil.
+func spanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// startSpan starts a child of the span in ctx, or a new trace. attrs are
+// key, value pairs. With tracing disabled it returns ctx and a nil span.
+func startSpan(ctx context.Context, name string, kind int, attrs ...interface{}) (context.Context, *Span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+	s := &Span{name: name, kind: kind, start: time.Now()}
+	if parent := spanFromContext(ctx); parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	s.set(attrs...)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// set adds key, value attributes.
+func (s *Span) set(attrs ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(attrs); i += 2 {
+		key, _ := attrs[i].(string)
+		s.attrs = append(s.attrs, otlpKeyValue{Key: key, Value: otlpValue(attrs[i+1])})
+	}
+}
+
+// end finishes the span, marking it failed if err is set, and queues it for
+// export.
+func (s *Span) end(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if err != nil {
+		s.failed = true
+		s.errMsg = redactString(err.Error())
+	}
+	s.mu.Unlock()
+	tracer.export(s, time.Now())
+}
+
+// remoteParent returns a context whose next span continues the trace of an
+// incoming traceparent header, if it has a valid one.
+func remoteParent(ctx context.Context, header string) context.Context {
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	var s Span
+	if _, err := hex.Decode(s.traceID[:], []byte(parts[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(s.spanID[:], []byte(parts[2])); err != nil {
+		return ctx
+	}
+	if s.traceID == [16]byte{} || s.spanID == [8]byte{} {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, &s)
+}
+
+// traceLinearRequest wraps each Linear GraphQL request attempt in a client
+// span.
+func traceLinearRequest(ctx context.Context, operation string, attempt int) (context.Context, func(error)) {
+	ctx, span := startSpan(ctx, "linear.graphql "+operation, spanKindClient,
+		"graphql.operation.name", operation,
+		"relay.attempt", attempt,
+	)
+	return ctx, span.end
+}
+
+// ----------------------------------------------------------------------------
+// HTTP server spans
+// ----------------------------------------------------------------------------
+
+// untracedPaths are polled too often to be worth a span.
+var untracedPaths = map[string]bool{"/health": true, "/health/live": true, "/health/ready": true, "/metrics": true}
+
+// traceRequests wraps every request in a server span that continues the
+// caller's trace when it sends a traceparent header. Spans are named after
+// the mux pattern that matched, so that paths with IDs or unknown paths don't
+// each get a name of their own; the path is in http.target.
+func traceRequests(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tracer == nil || untracedPaths[r.URL.Path] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		name := r.Method
+		attrs := []interface{}{"http.request.method", r.Method, "http.target", r.URL.Path}
+		if _, pattern := mux.Handler(r); pattern != "" {
+			name += " " + pattern
+			attrs = append(attrs, "http.route", pattern)
+		}
+		ctx := remoteParent(r.Context(), r.Header.Get("traceparent"))
+		ctx, span := startSpan(ctx, name, spanKindServer, attrs...)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.set("http.response.status_code", rec.status)
+		var err error
+		if rec.status >= 500 {
+			err = fmt.Errorf("status %d", rec.status)
+		}
+		span.end(err)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps streamed responses, e.g. exports, streaming through the span.
+func (r *statusRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// ----------------------------------------------------------------------------
+// Exporter
+// ----------------------------------------------------------------------------
+
+type spanExporter struct {
+	endpoint string
+	headers  map[string]string
+	resource []otlpKeyValue
+	client   *http.Client
+
+	queue   chan otlpSpan
+	flushes chan chan struct{}
+	dropped atomic.Int64
+}
+
+// loadTracing enables tracing when an OTLP endpoint is set, with the standard
+// OpenTelemetry variables: OTEL_EXPORTER_OTLP_TRACES_ENDPOINT (the full URL)
+// or OTEL_EXPORTER_OTLP_ENDPOINT (the collector's base URL),
+// OTEL_EXPORTER_OTLP_HEADERS and OTEL_SERVICE_NAME.
+func loadTracing(getenv func(string) string) error {
+	endpoint := getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" || getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return nil
+	}
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return fmt.Errorf("OTLP endpoint %q must be an http or https URL", endpoint)
+	}
+
+	headers := map[string]string{}
+	for _, pair := range strings.Split(getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS entry %q: want key=value", pair)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	service := getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "linear-discord-relay"
+	}
+
+	tracer = &spanExporter{
+		endpoint: endpoint,
+		headers:  headers,
+		resource: []otlpKeyValue{{Key: "service.name", Value: otlpValue(service)}},
+		client:   &http.Client{Timeout: 10 * time.Second},
+		queue:    make(chan otlpSpan, spanQueueSize),
+		flushes:  make(chan chan struct{}),
+	}
+	go tracer.loop()
+	slog.Info("Tracing: Exporting spans", "endpoint", endpoint, "service", service)
+	return nil
+}
+
+// export queues a finished span, dropping it if the queue is full.
+func (e *spanExporter) export(s *Span, end time.Time) {
+	s.mu.Lock()
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        s.attrs,
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if s.failed {
+		span.Status = &otlpStatus{Code: 2, Message: s.errMsg}
+	}
+	s.mu.Unlock()
+
+	select {
+	case e.queue <- span:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+func (e *spanExporter) loop() {
+	ticker := time.NewTicker(spanFlushInterval)
+	defer ticker.Stop()
+
+	var batch []otlpSpan
+	send := func() {
+		if n := e.dropped.Swap(0); n > 0 {
+			slog.Warn("Tracing: Dropped spans, export queue is full", "spans", n)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			slog.Warn("Tracing: Could not export spans", "spans", len(batch), "error", err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= spanBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-e.flushes:
+			for len(e.queue) > 0 {
+				batch = append(batch, <-e.queue)
+			}
+			send()
+			close(done)
+		}
+	}
+}
+
+// flush exports the queued spans, waiting until they are sent or ctx is done.
+func (e *spanExporter) flush(ctx context.Context) {
+	if e == nil {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case e.flushes <- done:
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func (e *spanExporter) send(spans []otlpSpan) error {
+	body, err := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: e.resource},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "linear-discord-relay"}, Spans: spans}},
+	}}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal spans: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned status %d: %s", resp.StatusCode, string(data))
+	}
+	return nil
+}
+
+// ----------------------------------------------------------------------------
+// OTLP JSON encoding
+// ----------------------------------------------------------------------------
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+// otlpSpan uses hex IDs and decimal string timestamps, as the OTLP JSON
+// encoding requires.
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 2 = error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func otlpValue(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTraceRequestsSpanNames(t *testing.T) {
+	prev := tracer
+	tracer = &spanExporter{queue: make(chan otlpSpan, 10)}
+	t.Cleanup(func() { tracer = prev })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/admin/", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/", http.NotFound)
+	handler := traceRequests(mux)
+
+	tests := []struct {
+		method, path string
+		wantName     string
+		wantRoute    string
+	}{
+		{http.MethodPost, "/webhook", "POST /webhook", "/webhook"},
+		{http.MethodGet, "/admin/workspaces/org-123", "GET /admin/", "/admin/"},
+		{http.MethodGet, "/admin/workspaces/org-456", "GET /admin/", "/admin/"},
+		{http.MethodGet, "/wp-login.php", "GET /", "/"},
+	}
+	for _, tt := range tests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		span := <-tracer.queue
+		if span.Name != tt.wantName {
+			t.Errorf("%s %s: span name = %q, want %q", tt.method, tt.path, span.Name, tt.wantName)
+		}
+		attrs := map[string]interface{}{}
+		for _, kv := range span.Attributes {
+			attrs[kv.Key] = kv.Value
+		}
+		if !reflect.DeepEqual(attrs["http.route"], otlpValue(tt.wantRoute)) {
+			t.Errorf("%s %s: http.route = %v, want %q", tt.method, tt.path, attrs["http.route"], tt.wantRoute)
+		}
+		if !reflect.DeepEqual(attrs["http.target"], otlpValue(tt.path)) {
+			t.Errorf("%s %s: http.target = %v, want the path", tt.method, tt.path, attrs["http.target"])
+		}
+	}
+
+	// Health checks and metrics scrapes are not traced.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if len(tracer.queue) != 0 {
+		t.Errorf("/metrics was traced as %q", (<-tracer.queue).Name)
+	}
+}
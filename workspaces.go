@@ -0,0 +1,683 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// ============================================================================
+// WORKSPACES (Linear OAuth installs)
+// ============================================================================
+
+// WorkspaceConfig routes a workspace installed through OAuth, keyed in
+// CONFIG_FILE by its URL key (linear.app/<key>). Jobs opt in with "workspace".
+type WorkspaceConfig struct {
+	// Destinations are the only ones this workspace's relay_to, jobs and
+	// reports post to; global destinations are never used for its data.
+	Destinations map[string]*Destination `json:"destinations,omitempty"`
+
+	// RelayTo lists the destinations that receive this workspace's webhook
+	// events. Events from an installed workspace without relay_to are
+	// dropped rather than sent to the global relay_to.
+	RelayTo []string `json:"relay_to,omitempty"`
+}
+
+// workspaceDestination returns the named destination as seen by a workspace,
+// which only sees its own destinations, or a global destination when
+// workspace is "".
+func (c *Config) workspaceDestination(workspace, name string) *Destination {
+	if workspace == "" {
+		return c.destination(name)
+	}
+	if name == "" {
+		name = defaultDestination
+	}
+	return c.Destinations[workspace+"/"+name]
+}
+
+// loadWorkspaceDestinations adds each workspace's destinations to the global
+// set as "<workspace>/<name>", so that they are compiled and validated like
+// the others, and checks the workspace routes.
+func (c *Config) loadWorkspaceDestinations() error {
+	for name := range c.Destinations {
+		if strings.Contains(name, "/") {
+			return fmt.Errorf("invalid destination name %q", name)
+		}
+	}
+	for key, ws := range c.Workspaces {
+		if ws == nil || key == "" || strings.Contains(key, "/") {
+			return fmt.Errorf("invalid workspace %q", key)
+		}
+		for name, dest := range ws.Destinations {
+			if strings.Contains(name, "/") {
+				return fmt.Errorf("workspace %q: invalid destination name %q", key, name)
+			}
+			c.Destinations[key+"/"+name] = dest
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateWorkspaceRoutes() error {
+	for key, ws := range c.Workspaces {
+		for _, name := range ws.RelayTo {
+			if c.workspaceDestination(key, name) == nil {
+				return fmt.Errorf("workspace %q relay_to references unknown destination %q", key, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Workspace is an installed Linear workspace with its own API client and
+// metadata cache.
+type Workspace struct {
+	ID   string // Linear organization ID
+	Key  string // URL key
+	Name string
+
+	client   *linear.Client
+	metadata *MetadataCache
+
+	// The decrypted access token, kept until it is due for a refresh so that
+	// requests don't read and decrypt STATE_DIR each time. A zero
+	// tokenExpiry means it is never refreshed.
+	tokenMu     sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
+}
+
+// cachedToken returns the access token in memory, unless it is due for a
+// refresh.
+func (ws *Workspace) cachedToken() (string, bool) {
+	ws.tokenMu.Lock()
+	defer ws.tokenMu.Unlock()
+	if ws.accessToken == "" || expiring(ws.tokenExpiry) {
+		return "", false
+	}
+	return ws.accessToken, true
+}
+
+func (ws *Workspace) cacheToken(token string, expiry time.Time) {
+	ws.tokenMu.Lock()
+	defer ws.tokenMu.Unlock()
+	ws.accessToken, ws.tokenExpiry = token, expiry
+}
+
+// workspaceRecord is a workspace as stored in STATE_DIR. Tokens are sealed
+// with TOKEN_ENCRYPTION_KEY.
+type workspaceRecord struct {
+	ID           string    `json:"id"`
+	Key          string    `json:"url_key"`
+	Name         string    `json:"name"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+	Scope        string    `json:"scope"`
+	InstalledAt  time.Time `json:"installed_at"`
+}
+
+// tokenRefreshMargin is how long before it expires an access token is
+// refreshed.
+const tokenRefreshMargin = 5 * time.Minute
+
+// tokenRefreshTimeout bounds the refresh request, which runs under the store
+// lock so that no two replicas spend the same rotating refresh token. It is
+// well below lockTimeout, so replicas waiting for the lock don't give up.
+const tokenRefreshTimeout = 10 * time.Second
+
+// oauthStateTTL is how long an install link stays valid.
+const oauthStateTTL = 24 * time.Hour
+
+var (
+	oauthConfig *linear.OAuthConfig
+	tokenKey    []byte
+	workspaces  = &workspaceRegistry{byID: map[string]*Workspace{}}
+)
+
+// loadOAuth configures the Linear OAuth app. It is optional, but once a
+// client ID is set the other settings are required.
+func loadOAuth(clientID, clientSecret, redirectURL, encryptionKey string) error {
+	if clientID == "" {
+		return nil
+	}
+	if clientSecret == "" || redirectURL == "" {
+		return errors.New("LINEAR_CLIENT_SECRET and LINEAR_OAUTH_REDIRECT_URL are required with LINEAR_CLIENT_ID")
+	}
+
+	key, err := parseTokenKey(encryptionKey)
+	if err != nil {
+		return err
+	}
+	tokenKey = key
+	oauthConfig = &linear.OAuthConfig{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"read"},
+		Actor:        "app",
+	}
+	return nil
+}
+
+// parseTokenKey decodes a 32-byte AES-256 key given as hex or base64.
+func parseTokenKey(s string) ([]byte, error) {
+	if s == "" {
+		return nil, errors.New("TOKEN_ENCRYPTION_KEY is required to store OAuth tokens")
+	}
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("TOKEN_ENCRYPTION_KEY must be 32 bytes, hex or base64 encoded")
+	}
+	return key, nil
+}
+
+// sealToken encrypts a token with AES-GCM; the nonce is prepended.
+func sealToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	block, err := aes.NewCipher(tokenKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(token), nil)), nil
+}
+
+func openToken(sealed string) (string, error) {
+	if sealed == "" {
+		return "", nil
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+	block, err := aes.NewCipher(tokenKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("failed to decrypt token: too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt token: wrong TOKEN_ENCRYPTION_KEY?")
+	}
+	return string(plain), nil
+}
+
+// workspaceRegistry holds the installed workspaces by organization ID.
+type workspaceRegistry struct {
+	mu   sync.RWMutex
+	byID map[string]*Workspace
+
+	// refresh serializes token refreshes; Linear rotates refresh tokens.
+	refresh sync.Mutex
+
+	lastSync time.Time
+}
+
+// workspaceSyncInterval is how often a lookup miss checks STATE_DIR for
+// workspaces installed through another replica.
+const workspaceSyncInterval = time.Minute
+
+// loadWorkspaces restores the installed workspaces from STATE_DIR.
+func loadWorkspaces() error {
+	if oauthConfig == nil {
+		return nil
+	}
+	records := map[string]*workspaceRecord{}
+	if err := store.load("workspaces", &records); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		workspaces.add(rec)
+	}
+	workspaces.lastSync = time.Now()
+	if len(records) > 0 {
+		slog.Info("Workspaces: Loaded installed workspaces", "count", len(records))
+	}
+	return nil
+}
+
+func (reg *workspaceRegistry) add(rec *workspaceRecord) *Workspace {
+	ws := &Workspace{ID: rec.ID, Key: rec.Key, Name: rec.Name}
+	ws.client = linear.NewClient("", append(linearOptions(), linear.WithTokenSource(func(ctx context.Context) (string, error) {
+		return reg.token(ctx, ws.ID)
+	}))...)
+	ws.metadata = newMetadataCache(ws.client, appConfig.metadataTTL)
+
+	reg.mu.Lock()
+	reg.byID[ws.ID] = ws
+	reg.mu.Unlock()
+	ws.metadata.warm()
+	return ws
+}
+
+func (reg *workspaceRegistry) get(id string) *Workspace {
+	if id == "" {
+		return nil
+	}
+	return reg.find(func(ws *Workspace) bool { return ws.ID == id })
+}
+
+func (reg *workspaceRegistry) byKey(key string) *Workspace {
+	return reg.find(func(ws *Workspace) bool { return ws.Key == key })
+}
+
+func (reg *workspaceRegistry) find(match func(ws *Workspace) bool) *Workspace {
+	lookup := func() *Workspace {
+		reg.mu.RLock()
+		defer reg.mu.RUnlock()
+		for _, ws := range reg.byID {
+			if match(ws) {
+				return ws
+			}
+		}
+		return nil
+	}
+	if ws := lookup(); ws != nil {
+		return ws
+	}
+	if reg.sync() {
+		return lookup()
+	}
+	return nil
+}
+
+// sync adds workspaces installed through other replicas sharing STATE_DIR,
+// at most once per workspaceSyncInterval. It reports whether any were added.
+func (reg *workspaceRegistry) sync() bool {
+	if oauthConfig == nil || store == nil {
+		return false
+	}
+	reg.mu.Lock()
+	if time.Since(reg.lastSync) < workspaceSyncInterval {
+		reg.mu.Unlock()
+		return false
+	}
+	reg.lastSync = time.Now()
+	reg.mu.Unlock()
+
+	records := map[string]*workspaceRecord{}
+	if err := store.load("workspaces", &records); err != nil {
+		slog.Warn("Workspaces: Could not reload installed workspaces", "error", err)
+		return false
+	}
+
+	added := false
+	for id, rec := range records {
+		reg.mu.RLock()
+		known := reg.byID[id] != nil
+		reg.mu.RUnlock()
+		if !known {
+			reg.add(rec)
+			added = true
+		}
+	}
+	return added
+}
+
+func (reg *workspaceRegistry) list() []*Workspace {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	list := make([]*Workspace, 0, len(reg.byID))
+	for _, ws := range reg.byID {
+		list = append(list, ws)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// token returns a valid access token for the workspace from memory, or
+// loads it from STATE_DIR when it is due for a refresh.
+func (reg *workspaceRegistry) token(ctx context.Context, id string) (string, error) {
+	reg.mu.RLock()
+	ws := reg.byID[id]
+	reg.mu.RUnlock()
+	if ws != nil {
+		if token, ok := ws.cachedToken(); ok {
+			return token, nil
+		}
+	}
+
+	token, expiry, err := reg.loadToken(ctx, id)
+	if err == nil && ws != nil {
+		ws.cacheToken(token, expiry)
+	}
+	return token, err
+}
+
+// loadToken reads and decrypts the workspace's access token, refreshing it
+// first if it is about to expire, and returns it with its expiry. The stored
+// record is re-read under the store lock so that replicas sharing STATE_DIR
+// don't refresh the same token twice; the refresh request is bounded by
+// tokenRefreshTimeout to keep that lock short.
+func (reg *workspaceRegistry) loadToken(ctx context.Context, id string) (string, time.Time, error) {
+	records := map[string]*workspaceRecord{}
+	if err := store.load("workspaces", &records); err != nil {
+		return "", time.Time{}, err
+	}
+	rec := records[id]
+	if rec == nil {
+		return "", time.Time{}, fmt.Errorf("workspace %s is not installed", id)
+	}
+	if !expiring(rec.tokenExpiry()) {
+		token, err := openToken(rec.AccessToken)
+		return token, rec.tokenExpiry(), err
+	}
+
+	reg.refresh.Lock()
+	defer reg.refresh.Unlock()
+
+	var token string
+	var expiry time.Time
+	err := store.update("workspaces", &records, func() error {
+		rec := records[id]
+		if rec == nil {
+			return fmt.Errorf("workspace %s is not installed", id)
+		}
+		expiry = rec.tokenExpiry()
+		if !expiring(expiry) {
+			var err error
+			token, err = openToken(rec.AccessToken)
+			return err
+		}
+
+		refreshToken, err := openToken(rec.RefreshToken)
+		if err != nil {
+			return err
+		}
+		refreshCtx, cancel := context.WithTimeout(ctx, tokenRefreshTimeout)
+		defer cancel()
+		t, err := oauthConfig.Refresh(refreshCtx, refreshToken)
+		if err != nil {
+			return fmt.Errorf("failed to refresh token of %s: %w", rec.Key, err)
+		}
+		if t.RefreshToken == "" {
+			t.RefreshToken = refreshToken
+		}
+		if err := rec.setToken(t); err != nil {
+			return err
+		}
+		token, expiry = t.AccessToken, rec.tokenExpiry()
+		slog.InfoContext(ctx, "Workspaces: Refreshed access token", "workspace", rec.Key)
+		return nil
+	})
+	return token, expiry, err
+}
+
+// tokenExpiry returns when the access token has to be refreshed by, or zero
+// if it can't be refreshed.
+func (rec *workspaceRecord) tokenExpiry() time.Time {
+	if rec.RefreshToken == "" {
+		return time.Time{}
+	}
+	return rec.ExpiresAt
+}
+
+func expiring(expiry time.Time) bool {
+	return !expiry.IsZero() && time.Until(expiry) < tokenRefreshMargin
+}
+
+func (rec *workspaceRecord) setToken(t *linear.Token) error {
+	var err error
+	if rec.AccessToken, err = sealToken(t.AccessToken); err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	if rec.RefreshToken, err = sealToken(t.RefreshToken); err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	rec.ExpiresAt = t.ExpiresAt
+	rec.Scope = t.Scope
+	return nil
+}
+
+// signOAuthState returns an install state that expires after oauthStateTTL:
+// "<expiry>.<nonce>.<hmac>", signed with the token key so no server-side
+// session is needed.
+func signOAuthState(now time.Time) string {
+	nonce := make([]byte, 12)
+	rand.Read(nonce)
+	payload := strconv.FormatInt(now.Add(oauthStateTTL).Unix(), 10) + "." + hex.EncodeToString(nonce)
+	return payload + "." + oauthStateMAC(payload)
+}
+
+func verifyOAuthState(state string, now time.Time) bool {
+	i := strings.LastIndex(state, ".")
+	if i < 0 {
+		return false
+	}
+	payload, mac := state[:i], state[i+1:]
+	if !hmac.Equal([]byte(mac), []byte(oauthStateMAC(payload))) {
+		return false
+	}
+	expiry, _, _ := strings.Cut(payload, ".")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	return err == nil && now.Unix() < unix
+}
+
+func oauthStateMAC(payload string) string {
+	mac := hmac.New(sha256.New, tokenKey)
+	mac.Write([]byte("oauth-state:" + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// handleOAuthInstall returns a link that installs the relay in a Linear
+// workspace. It is valid for a day, so it can be handed to a client's admin.
+func handleOAuthInstall(w http.ResponseWriter, r *http.Request) {
+	if oauthConfig == nil {
+		http.Error(w, "LINEAR_CLIENT_ID not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"install_url": oauthConfig.AuthCodeURL(signOAuthState(time.Now())),
+		"expires_in":  oauthStateTTL.String(),
+	})
+}
+
+// handleOAuthCallback completes an install: it exchanges the code for a
+// token, looks up the workspace it belongs to and stores it.
+func handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
+	if oauthConfig == nil {
+		http.Error(w, "LINEAR_CLIENT_ID not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	if msg := q.Get("error"); msg != "" {
+		http.Error(w, "Installation was not approved: "+msg, http.StatusBadRequest)
+		return
+	}
+	if !verifyOAuthState(q.Get("state"), time.Now()) {
+		http.Error(w, "Invalid or expired install link", http.StatusBadRequest)
+		return
+	}
+	code := q.Get("code")
+	if code == "" {
+		http.Error(w, "Missing code", http.StatusBadRequest)
+		return
+	}
+
+	ws, err := installWorkspace(r.Context(), code)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Workspaces: Install failed", "error", err)
+		http.Error(w, "Installation failed", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Installed in %s (%s).\n", ws.Name, ws.Key)
+	if appConfig.Workspaces[ws.Key] == nil {
+		fmt.Fprintf(w, "Add \"%s\" to \"workspaces\" in the relay config to route its events.\n", ws.Key)
+	}
+}
+
+func installWorkspace(ctx context.Context, code string) (*Workspace, error) {
+	t, err := oauthConfig.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	client := linear.NewClient("", append(linearOptions(), linear.WithTokenSource(func(context.Context) (string, error) {
+		return t.AccessToken, nil
+	}))...)
+	org, err := client.Organization(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch organization: %w", err)
+	}
+	if org == nil || org.ID == "" {
+		return nil, errors.New("token has no organization")
+	}
+
+	rec := &workspaceRecord{ID: org.ID, Key: org.URLKey, Name: org.Name, InstalledAt: time.Now().UTC()}
+	if err := rec.setToken(t); err != nil {
+		return nil, err
+	}
+	records := map[string]*workspaceRecord{}
+	if err := store.update("workspaces", &records, func() error {
+		records[rec.ID] = rec
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Workspaces: Installed", "workspace", rec.Key, "name", rec.Name)
+	return workspaces.add(rec), nil
+}
+
+// handleWorkspaces lists the installed workspaces and whether the config
+// routes them.
+func handleWorkspaces(w http.ResponseWriter, r *http.Request) {
+	type workspaceStatus struct {
+		ID         string   `json:"id"`
+		Key        string   `json:"url_key"`
+		Name       string   `json:"name"`
+		Configured bool     `json:"configured"`
+		RelayTo    []string `json:"relay_to,omitempty"`
+		Jobs       []string `json:"jobs,omitempty"`
+	}
+
+	list := []workspaceStatus{}
+	for _, ws := range workspaces.list() {
+		status := workspaceStatus{ID: ws.ID, Key: ws.Key, Name: ws.Name}
+		if cfg := appConfig.Workspaces[ws.Key]; cfg != nil {
+			status.Configured = true
+			status.RelayTo = cfg.RelayTo
+		}
+		for _, job := range appConfig.Jobs {
+			if job.Workspace == ws.Key {
+				status.Jobs = append(status.Jobs, job.Name)
+			}
+		}
+		list = append(list, status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"workspaces": list})
+}
+
+// hostOrganizationTimeout bounds the lookup of the LINEAR_API_KEY workspace.
+const hostOrganizationTimeout = 10 * time.Second
+
+// hostOrganization is the workspace LINEAR_API_KEY belongs to. With OAuth on,
+// it is the only workspace besides the installed ones whose webhooks are
+// relayed, since every install shares LINEAR_WEBHOOK_SECRET.
+type hostOrganization struct {
+	mu          sync.Mutex
+	id          string
+	lastAttempt time.Time
+}
+
+var hostOrg = &hostOrganization{}
+
+// resolve looks up the workspace of LINEAR_API_KEY and returns its ID, or ""
+// if it is unknown. A failed lookup is retried at most once per
+// workspaceSyncInterval.
+func (h *hostOrganization) resolve(ctx context.Context) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.id != "" || linearAPIKey == "" || time.Since(h.lastAttempt) < workspaceSyncInterval {
+		return h.id
+	}
+	h.lastAttempt = time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, hostOrganizationTimeout)
+	defer cancel()
+	org, err := linearClient.Organization(ctx)
+	if err != nil || org == nil || org.ID == "" {
+		slog.WarnContext(ctx, "Workspaces: Could not look up the LINEAR_API_KEY workspace", "error", err)
+		return ""
+	}
+	h.id = org.ID
+	slog.InfoContext(ctx, "Workspaces: Resolved the LINEAR_API_KEY workspace", "workspace", org.URLKey)
+	return h.id
+}
+
+func (h *hostOrganization) is(ctx context.Context, id string) bool {
+	return id != "" && h.resolve(ctx) == id
+}
+
+// relayTargets returns the metadata cache and destinations for a webhook:
+// the workspace's relay_to for installed workspaces, otherwise the global
+// one. With OAuth on, the global one only serves the LINEAR_API_KEY
+// workspace; events from any other workspace return no cache and are
+// dropped.
+func relayTargets(ctx context.Context, webhook LinearWebhook) (cache *MetadataCache, targets []*Destination) {
+	ws := workspaces.get(webhook.OrganizationID)
+	if ws == nil {
+		if oauthConfig != nil && !hostOrg.is(ctx, webhook.OrganizationID) {
+			slog.WarnContext(ctx, "Workspaces: Workspace is not installed, dropping event", "organization_id", webhook.OrganizationID)
+			return nil, nil
+		}
+		for _, name := range appConfig.RelayTo {
+			targets = append(targets, appConfig.destination(name))
+		}
+		return metadata, targets
+	}
+
+	cfg := appConfig.Workspaces[ws.Key]
+	if cfg == nil {
+		slog.WarnContext(ctx, "Workspaces: Workspace is not configured, dropping event", "workspace", ws.Key)
+		return ws.metadata, nil
+	}
+	for _, name := range cfg.RelayTo {
+		targets = append(targets, appConfig.workspaceDestination(ws.Key, name))
+	}
+	return ws.metadata, targets
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scenextras/linear-daily-digest/linear"
+)
+
+// setTokenKey sets TOKEN_ENCRYPTION_KEY for the duration of a test.
+func setTokenKey(t *testing.T, key string) {
+	t.Helper()
+	prev := tokenKey
+	k, err := parseTokenKey(key)
+	if err != nil {
+		t.Fatalf("parseTokenKey: %v", err)
+	}
+	tokenKey = k
+	t.Cleanup(func() { tokenKey = prev })
+}
+
+const (
+	testTokenKey  = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	otherTokenKey = "ffeeddccbbaa99887766554433221100ffeeddccbbaa99887766554433221100"
+)
+
+func TestParseTokenKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{testTokenKey, false},
+		{"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", false}, // base64
+		{"", true},
+		{"00010203", true},            // too short
+		{"not a key at all!", true},   // neither hex nor base64
+		{testTokenKey + "2021", true}, // too long
+	}
+	for _, tt := range tests {
+		_, err := parseTokenKey(tt.key)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTokenKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSealToken(t *testing.T) {
+	setTokenKey(t, testTokenKey)
+
+	sealed, err := sealToken("lin_oauth_secret")
+	if err != nil {
+		t.Fatalf("sealToken: %v", err)
+	}
+	if strings.Contains(sealed, "lin_oauth_secret") {
+		t.Fatalf("sealed token contains the plaintext: %s", sealed)
+	}
+	again, _ := sealToken("lin_oauth_secret")
+	if again == sealed {
+		t.Error("sealing twice gave the same output; the nonce is not random")
+	}
+
+	plain, err := openToken(sealed)
+	if err != nil || plain != "lin_oauth_secret" {
+		t.Errorf("openToken = %q, %v; want the original token", plain, err)
+	}
+
+	// An empty token stays empty both ways.
+	if s, err := sealToken(""); s != "" || err != nil {
+		t.Errorf("sealToken(\"\") = %q, %v", s, err)
+	}
+	if s, err := openToken(""); s != "" || err != nil {
+		t.Errorf("openToken(\"\") = %q, %v", s, err)
+	}
+}
+
+func TestOpenTokenErrors(t *testing.T) {
+	setTokenKey(t, testTokenKey)
+	sealed, err := sealToken("lin_oauth_secret")
+	if err != nil {
+		t.Fatalf("sealToken: %v", err)
+	}
+	data, _ := base64.StdEncoding.DecodeString(sealed)
+	data[len(data)-1] ^= 1
+	tampered := base64.StdEncoding.EncodeToString(data)
+
+	tests := []struct {
+		name   string
+		sealed string
+		key    string
+	}{
+		{"not base64", "%%%", testTokenKey},
+		{"too short", "AAEC", testTokenKey},
+		{"tampered", tampered, testTokenKey},
+		{"wrong key", sealed, otherTokenKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTokenKey(t, tt.key)
+			if plain, err := openToken(tt.sealed); err == nil {
+				t.Errorf("openToken = %q, want an error", plain)
+			}
+		})
+	}
+}
+
+func TestOAuthState(t *testing.T) {
+	setTokenKey(t, testTokenKey)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	state := signOAuthState(now)
+
+	if signOAuthState(now) == state {
+		t.Error("two states signed at the same time are equal; the nonce is not random")
+	}
+
+	payload := state[:strings.LastIndex(state, ".")]
+	expiry := strings.SplitN(payload, ".", 2)[0]
+	forged := fmt.Sprint(now.Add(365*24*time.Hour).Unix()) + strings.TrimPrefix(state, expiry)
+
+	tests := []struct {
+		name  string
+		state string
+		now   time.Time
+		want  bool
+	}{
+		{"fresh", state, now, true},
+		{"just before expiry", state, now.Add(oauthStateTTL - time.Second), true},
+		{"expired", state, now.Add(oauthStateTTL), false},
+		{"extended expiry", forged, now, false},
+		{"bad mac", payload + "." + strings.Repeat("0", 64), now, false},
+		{"no separator", "garbage", now, false},
+		{"empty", "", now, false},
+	}
+	for _, tt := range tests {
+		if got := verifyOAuthState(tt.state, tt.now); got != tt.want {
+			t.Errorf("%s: verifyOAuthState(%q) = %v, want %v", tt.name, tt.state, got, tt.want)
+		}
+	}
+
+	// A state signed with another key is rejected.
+	setTokenKey(t, otherTokenKey)
+	if verifyOAuthState(state, now) {
+		t.Error("verifyOAuthState accepted a state signed with another key")
+	}
+}
+
+func TestWorkspaceTokenCache(t *testing.T) {
+	ws := &Workspace{}
+	if _, ok := ws.cachedToken(); ok {
+		t.Error("empty cache returned a token")
+	}
+
+	ws.cacheToken("no-expiry", time.Time{})
+	if tok, ok := ws.cachedToken(); !ok || tok != "no-expiry" {
+		t.Errorf("cachedToken = %q, %v; want a token without expiry to be kept", tok, ok)
+	}
+
+	ws.cacheToken("fresh", time.Now().Add(tokenRefreshMargin+time.Hour))
+	if tok, ok := ws.cachedToken(); !ok || tok != "fresh" {
+		t.Errorf("cachedToken = %q, %v; want the fresh token", tok, ok)
+	}
+
+	ws.cacheToken("expiring", time.Now().Add(tokenRefreshMargin/2))
+	if _, ok := ws.cachedToken(); ok {
+		t.Error("cachedToken returned a token that is due for a refresh")
+	}
+}
+
+func TestVerifyLinearWebhook(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	body := func(ts time.Time) []byte {
+		return []byte(fmt.Sprintf(`{"action":"create","type":"Issue","webhookTimestamp":%d}`, ts.UnixMilli()))
+	}
+	sign := func(secret string, body []byte) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	fresh := body(now.Add(-10 * time.Second))
+	stale := body(now.Add(-2 * time.Minute))
+	future := body(now.Add(2 * time.Minute))
+	noTimestamp := []byte(`{"action":"create","type":"Issue"}`)
+
+	tests := []struct {
+		name      string
+		secrets   []string
+		signature string
+		body      []byte
+		wantErr   bool
+	}{
+		{"valid", []string{"s1"}, sign("s1", fresh), fresh, false},
+		{"rotated secret", []string{"old", "new"}, sign("new", fresh), fresh, false},
+		{"wrong secret", []string{"s1"}, sign("s2", fresh), fresh, true},
+		{"tampered body", []string{"s1"}, sign("s1", fresh), stale, true},
+		{"missing signature", []string{"s1"}, "", fresh, true},
+		{"malformed signature", []string{"s1"}, "zz", fresh, true},
+		{"replayed", []string{"s1"}, sign("s1", stale), stale, true},
+		{"from the future", []string{"s1"}, sign("s1", future), future, true},
+		{"no timestamp", []string{"s1"}, sign("s1", noTimestamp), noTimestamp, true},
+	}
+	for _, tt := range tests {
+		err := verifyLinearWebhook(tt.secrets, tt.signature, tt.body, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: verifyLinearWebhook error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRelayTargets(t *testing.T) {
+	prevConfig, prevOAuth, prevHost, prevRegistry := appConfig, oauthConfig, hostOrg, workspaces
+	t.Cleanup(func() { appConfig, oauthConfig, hostOrg, workspaces = prevConfig, prevOAuth, prevHost, prevRegistry })
+
+	global := &Destination{Name: "default", WebhookURL: "https://discord.test/global"}
+	acme := &Destination{Name: "acme/default", WebhookURL: "https://discord.test/acme"}
+	appConfig = &Config{
+		Destinations: map[string]*Destination{"default": global},
+		RelayTo:      []string{"default"},
+		Workspaces: map[string]*WorkspaceConfig{
+			"acme": {Destinations: map[string]*Destination{"default": acme}, RelayTo: []string{"default"}},
+		},
+	}
+	if err := appConfig.loadWorkspaceDestinations(); err != nil {
+		t.Fatalf("loadWorkspaceDestinations: %v", err)
+	}
+	acmeWorkspace := &Workspace{ID: "org-acme", Key: "acme", metadata: newMetadataCache(nil, time.Hour)}
+	unconfigured := &Workspace{ID: "org-other", Key: "other", metadata: newMetadataCache(nil, time.Hour)}
+	workspaces = &workspaceRegistry{
+		byID:     map[string]*Workspace{acmeWorkspace.ID: acmeWorkspace, unconfigured.ID: unconfigured},
+		lastSync: time.Now(),
+	}
+	hostOrg = &hostOrganization{id: "org-host"}
+
+	tests := []struct {
+		name      string
+		oauth     bool
+		org       string
+		wantCache *MetadataCache
+		want      []*Destination
+	}{
+		{"host workspace", true, "org-host", metadata, []*Destination{global}},
+		{"installed workspace", true, "org-acme", acmeWorkspace.metadata, []*Destination{acme}},
+		{"installed but not configured", true, "org-other", unconfigured.metadata, nil},
+		{"unknown workspace", true, "org-stranger", nil, nil},
+		{"no organization", true, "", nil, nil},
+		{"unknown workspace without OAuth", false, "org-stranger", metadata, []*Destination{global}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oauthConfig = nil
+			if tt.oauth {
+				oauthConfig = &linear.OAuthConfig{ClientID: "client"}
+			}
+			cache, targets := relayTargets(context.Background(), LinearWebhook{Type: "Issue", OrganizationID: tt.org})
+			if cache != tt.wantCache {
+				t.Errorf("cache = %p, want %p", cache, tt.wantCache)
+			}
+			if !reflect.DeepEqual(targets, tt.want) {
+				t.Errorf("targets = %v, want %v", targets, tt.want)
+			}
+		})
+	}
+}
+
+// loadTestConfig loads config from a temp CONFIG_FILE, with
+// DISCORD_WEBHOOK_URL set.
+func loadTestConfig(t *testing.T, config string) (*Config, error) {
+	t.Helper()
+	prev := discordWebhookURL
+	discordWebhookURL = "https://discord.test/default"
+	t.Cleanup(func() { discordWebhookURL = prev })
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return loadConfig(path)
+}
+
+func TestWorkspaceDestinationsAreNotGlobal(t *testing.T) {
+	const workspaces = `"workspaces": {"acme": {"destinations": {"default": {"webhook_url": "https://discord.test/acme"}}}}`
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			"workspace job",
+			`{` + workspaces + `, "jobs": [{"name": "acme", "kind": "digest", "schedule": "0 9 * * *", "workspace": "acme"}]}`,
+			"",
+		},
+		{
+			"global relay_to",
+			`{` + workspaces + `, "relay_to": ["acme/default"]}`,
+			`relay_to references unknown destination "acme/default"`,
+		},
+		{
+			"global job",
+			`{` + workspaces + `, "jobs": [{"name": "leak", "kind": "digest", "schedule": "0 9 * * *", "destination": "acme/default"}]}`,
+			`job "leak" references unknown destination "acme/default"`,
+		},
+		{
+			"global destination name",
+			`{"destinations": {"acme/default": {"webhook_url": "https://discord.test/x"}}}`,
+			`invalid destination name "acme/default"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadTestConfig(t, tt.config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("loadConfig: %v", err)
+				}
+				if cfg.destination("acme/default") != nil {
+					t.Error("destination resolved a workspace destination")
+				}
+				if cfg.workspaceDestination("acme", "") == nil {
+					t.Error("workspaceDestination did not resolve the workspace's default")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfig error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}